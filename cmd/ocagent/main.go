@@ -39,6 +39,7 @@ import (
 	"github.com/census-instrumentation/opencensus-service/internal/version"
 	"github.com/census-instrumentation/opencensus-service/observability"
 	"github.com/census-instrumentation/opencensus-service/processor"
+	"github.com/census-instrumentation/opencensus-service/receiver/httpplanreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/jaegerreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/postgresreceiver"
@@ -180,6 +181,14 @@ func runOCAgent() {
 		closeFns = append(closeFns, postgresDoneFn)
 	}
 
+	if agentConfig.HTTPPlanReceiverEnabled() {
+		httpPlanDoneFn, err := runHTTPPlanReceiver(agentConfig.HTTPPlanReceiverConfig(), commonSpanSink)
+		if err != nil {
+			log.Fatal(err)
+		}
+		closeFns = append(closeFns, httpPlanDoneFn)
+	}
+
 	// Always cleanup finally
 	defer func() {
 		for _, closeFn := range closeFns {
@@ -353,6 +362,9 @@ func runPostgresReceiver(config *postgresreceiver.Config, next processor.TraceDa
 	if err != nil {
 		return nil, err
 	}
+	if err := view.Register(postgresreceiver.MetricViews()...); err != nil {
+		return nil, fmt.Errorf("failed to register PostgreSQL receiver views: %v", err)
+	}
 	if err = pgr.StartTraceReception(context.Background(), next); err != nil {
 		return nil, err
 	}
@@ -362,3 +374,18 @@ func runPostgresReceiver(config *postgresreceiver.Config, next processor.TraceDa
 	log.Print("Running PostgreSQL receiver")
 	return doneFn, nil
 }
+
+func runHTTPPlanReceiver(config *httpplanreceiver.Config, next processor.TraceDataProcessor) (doneFn func() error, err error) {
+	hpr, err := httpplanreceiver.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the HTTP plan receiver: %v", err)
+	}
+	if err := hpr.StartTraceReception(context.Background(), next); err != nil {
+		return nil, fmt.Errorf("cannot start HTTP plan receiver with address %q: %v", hpr.Address(), err)
+	}
+	doneFn = func() error {
+		return hpr.StopTraceReception(context.Background())
+	}
+	log.Printf("Running HTTP plan receiver with address %q", hpr.Address())
+	return doneFn, nil
+}
@@ -16,6 +16,11 @@ package exportertest
 
 import (
 	"encoding/json"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
 )
 
 // ToJSON marshals a generic interface to JSON to enable easy comparisons.
@@ -23,3 +28,13 @@ func ToJSON(v interface{}) []byte {
 	b, _ := json.MarshalIndent(v, "", "  ")
 	return b
 }
+
+// DumpTraceData renders the node, resource and spans of td as a single
+// indented JSON document, for debugging.
+func DumpTraceData(td data.TraceData) []byte {
+	return ToJSON(struct {
+		Node     *commonpb.Node       `json:"node,omitempty"`
+		Resource *resourcepb.Resource `json:"resource,omitempty"`
+		Spans    []*tracepb.Span      `json:"spans"`
+	}{td.Node, td.Resource, td.Spans})
+}
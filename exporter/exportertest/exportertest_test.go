@@ -0,0 +1,60 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exportertest
+
+import (
+	"encoding/json"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestDumpTraceData(t *testing.T) {
+	td := data.TraceData{
+		Node: &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "orders"}},
+		Spans: []*tracepb.Span{
+			{Name: &tracepb.TruncatableString{Value: "CloudSQLQuery"}},
+			{Name: &tracepb.TruncatableString{Value: "Seq Scan"}},
+		},
+	}
+
+	var got struct {
+		Node struct {
+			ServiceInfo struct {
+				Name string `json:"name"`
+			} `json:"service_info"`
+		} `json:"node"`
+		Resource *json.RawMessage `json:"resource"`
+		Spans    []struct {
+			Name struct {
+				Value string `json:"value"`
+			} `json:"name"`
+		} `json:"spans"`
+	}
+	if err := json.Unmarshal(DumpTraceData(td), &got); err != nil {
+		t.Fatalf("Failed to unmarshal the dump: %v", err)
+	}
+	if got.Node.ServiceInfo.Name != "orders" {
+		t.Errorf("Got service name %q, want %q", got.Node.ServiceInfo.Name, "orders")
+	}
+	if got.Resource != nil {
+		t.Errorf("Got resource %s, want it omitted", *got.Resource)
+	}
+	if len(got.Spans) != 2 || got.Spans[0].Name.Value != "CloudSQLQuery" || got.Spans[1].Name.Value != "Seq Scan" {
+		t.Errorf("Got spans %+v, want the 2 spans in order", got.Spans)
+	}
+}
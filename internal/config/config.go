@@ -36,6 +36,7 @@ import (
 	"github.com/census-instrumentation/opencensus-service/exporter/stackdriverexporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/zipkinexporter"
 	"github.com/census-instrumentation/opencensus-service/processor"
+	"github.com/census-instrumentation/opencensus-service/receiver/httpplanreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/postgresreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/prometheusreceiver"
@@ -103,6 +104,7 @@ type Receivers struct {
 	Jaeger     *ReceiverConfig          `mapstructure:"jaeger"`
 	Scribe     *ScribeReceiverConfig    `mapstructure:"zipkin-scribe"`
 	Postgres   *postgresreceiver.Config `mapstructure:"postgres"`
+	HTTPPlan   *httpplanreceiver.Config `mapstructure:"http_plan"`
 
 	// Prometheus contains the Prometheus configurations.
 	// Such as:
@@ -295,6 +297,17 @@ func (c *Config) PostgresReceiverConfig() *postgresreceiver.Config {
 	return c.Receivers.Postgres
 }
 
+func (c *Config) HTTPPlanReceiverEnabled() bool {
+	return c != nil && c.Receivers != nil && c.Receivers.HTTPPlan != nil
+}
+
+func (c *Config) HTTPPlanReceiverConfig() *httpplanreceiver.Config {
+	if c == nil || c.Receivers == nil {
+		return nil
+	}
+	return c.Receivers.HTTPPlan
+}
+
 // ZipkinReceiverAddress is a helper to safely retrieve the address
 // that the Zipkin receiver will run on.
 // If Config is nil or the Zipkin receiver's configuration is nil, it
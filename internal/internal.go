@@ -34,6 +34,15 @@ func TimeToTimestamp(t time.Time) *timestamp.Timestamp {
 	}
 }
 
+// TimestampToTime converts a timestamp.Timestamp pointer to a time.Time.
+// A nil timestamp is converted to the zero time.Time.
+func TimestampToTime(ts *timestamp.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos))
+}
+
 // CombineErrors converts a list of errors into one error.
 func CombineErrors(errs []error) error {
 	numErrors := len(errs)
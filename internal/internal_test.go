@@ -18,6 +18,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/ptypes/timestamp"
+
 	"github.com/census-instrumentation/opencensus-service/internal"
 )
 
@@ -38,3 +40,14 @@ func TestTimeConverters(t *testing.T) {
 		t.Errorf("Convertedback time does not match original time\nGot: %d\nWant:%d", g, w)
 	}
 }
+
+func TestTimestampToTime(t *testing.T) {
+	t1 := time.Date(2018, 10, 31, 19, 43, 35, 789, time.UTC)
+	ts := &timestamp.Timestamp{Seconds: 1541015015, Nanos: 789}
+	if g, w := internal.TimestampToTime(ts), t1; !g.Equal(w) {
+		t.Errorf("Converted time does not match\nGot: %v\nWant:%v", g, w)
+	}
+	if !internal.TimestampToTime(nil).IsZero() {
+		t.Errorf("Nil timestamp was not converted to the zero time")
+	}
+}
@@ -36,6 +36,11 @@ var (
 
 	mExporterReceivedSpans = stats.Int64("oc.io/exporter/received_spans", "Counts the number of spans received by the exporter", "1")
 	mExporterDroppedSpans  = stats.Int64("oc.io/exporter/dropped_spans", "Counts the number of spans received by the exporter", "1")
+
+	mProcessorDroppedSpans  = stats.Int64("oc.io/processor/dropped_spans", "Counts the number of spans dropped by the processor", "1")
+	mProcessorDelayedSpans  = stats.Int64("oc.io/processor/delayed_spans", "Counts the number of spans delayed by the processor", "1")
+	mProcessorModifiedSpans = stats.Int64("oc.io/processor/modified_spans", "Counts the number of spans modified by the processor", "1")
+	mProcessorBufferedBytes = stats.Int64("oc.io/processor/buffered_bytes", "The estimated size of the spans buffered by the processor", "By")
 )
 
 // TagKeyReceiver defines tag key for Receiver.
@@ -44,6 +49,9 @@ var TagKeyReceiver, _ = tag.NewKey("oc_receiver")
 // TagKeyExporter defines tag key for Exporter.
 var TagKeyExporter, _ = tag.NewKey("oc_exporter")
 
+// TagKeyProcessor defines tag key for Processor.
+var TagKeyProcessor, _ = tag.NewKey("oc_processor")
+
 // ViewReceiverReceivedSpans defines the view for the receiver received spans metric.
 var ViewReceiverReceivedSpans = &view.View{
 	Name:        mReceiverReceivedSpans.Name(),
@@ -80,12 +88,52 @@ var ViewExporterDroppedSpans = &view.View{
 	TagKeys:     []tag.Key{TagKeyReceiver, TagKeyExporter},
 }
 
+// ViewProcessorDroppedSpans defines the view for the processor dropped spans metric.
+var ViewProcessorDroppedSpans = &view.View{
+	Name:        mProcessorDroppedSpans.Name(),
+	Description: mProcessorDroppedSpans.Description(),
+	Measure:     mProcessorDroppedSpans,
+	Aggregation: view.Sum(),
+	TagKeys:     []tag.Key{TagKeyReceiver, TagKeyProcessor},
+}
+
+// ViewProcessorDelayedSpans defines the view for the processor delayed spans metric.
+var ViewProcessorDelayedSpans = &view.View{
+	Name:        mProcessorDelayedSpans.Name(),
+	Description: mProcessorDelayedSpans.Description(),
+	Measure:     mProcessorDelayedSpans,
+	Aggregation: view.Sum(),
+	TagKeys:     []tag.Key{TagKeyReceiver, TagKeyProcessor},
+}
+
+// ViewProcessorModifiedSpans defines the view for the processor modified spans metric.
+var ViewProcessorModifiedSpans = &view.View{
+	Name:        mProcessorModifiedSpans.Name(),
+	Description: mProcessorModifiedSpans.Description(),
+	Measure:     mProcessorModifiedSpans,
+	Aggregation: view.Sum(),
+	TagKeys:     []tag.Key{TagKeyReceiver, TagKeyProcessor},
+}
+
+// ViewProcessorBufferedBytes defines the view for the processor buffered bytes metric.
+var ViewProcessorBufferedBytes = &view.View{
+	Name:        mProcessorBufferedBytes.Name(),
+	Description: mProcessorBufferedBytes.Description(),
+	Measure:     mProcessorBufferedBytes,
+	Aggregation: view.LastValue(),
+	TagKeys:     []tag.Key{TagKeyReceiver, TagKeyProcessor},
+}
+
 // AllViews has the views for the metrics provided by the agent.
 var AllViews = []*view.View{
 	ViewReceiverReceivedSpans,
 	ViewReceiverDroppedSpans,
 	ViewExporterReceivedSpans,
 	ViewExporterDroppedSpans,
+	ViewProcessorDroppedSpans,
+	ViewProcessorDelayedSpans,
+	ViewProcessorModifiedSpans,
+	ViewProcessorBufferedBytes,
 }
 
 // ContextWithReceiverName adds the tag "oc_receiver" and the name of the receiver as the value,
@@ -116,6 +164,37 @@ func RecordTraceExporterMetrics(ctx context.Context, receivedSpans int, droppedS
 	stats.Record(ctx, mExporterReceivedSpans.M(int64(receivedSpans)), mExporterDroppedSpans.M(int64(droppedSpans)))
 }
 
+// ContextWithProcessorName adds the tag "oc_processor" and the name of the processor as the value,
+// and returns the newly created context.
+func ContextWithProcessorName(ctx context.Context, processorName string) context.Context {
+	ctx, _ = tag.New(ctx, tag.Upsert(TagKeyProcessor, processorName))
+	return ctx
+}
+
+// RecordTraceProcessorMetrics records the number of the spans dropped by the processor.
+// Use it with a context.Context generated using ContextWithProcessorName().
+func RecordTraceProcessorMetrics(ctx context.Context, droppedSpans int) {
+	stats.Record(ctx, mProcessorDroppedSpans.M(int64(droppedSpans)))
+}
+
+// RecordTraceProcessorDelayedSpans records the number of the spans delayed by the processor.
+// Use it with a context.Context generated using ContextWithProcessorName().
+func RecordTraceProcessorDelayedSpans(ctx context.Context, delayedSpans int) {
+	stats.Record(ctx, mProcessorDelayedSpans.M(int64(delayedSpans)))
+}
+
+// RecordTraceProcessorModifiedSpans records the number of the spans modified by the processor.
+// Use it with a context.Context generated using ContextWithProcessorName().
+func RecordTraceProcessorModifiedSpans(ctx context.Context, modifiedSpans int) {
+	stats.Record(ctx, mProcessorModifiedSpans.M(int64(modifiedSpans)))
+}
+
+// RecordTraceProcessorBufferedBytes records the current size of the spans buffered by the processor.
+// Use it with a context.Context generated using ContextWithProcessorName().
+func RecordTraceProcessorBufferedBytes(ctx context.Context, bufferedBytes int) {
+	stats.Record(ctx, mProcessorBufferedBytes.M(int64(bufferedBytes)))
+}
+
 // GRPCServerWithObservabilityEnabled creates a gRPC server that at a bare minimum has
 // the OpenCensus ocgrpc server stats handler enabled for tracing and stats.
 // Use it instead of invoking grpc.NewServer directly.
@@ -29,8 +29,9 @@ import (
 )
 
 const (
-	receiverName = "fake_receiver"
-	exporterName = "fake_exporter"
+	receiverName  = "fake_receiver"
+	exporterName  = "fake_exporter"
+	processorName = "fake_processor"
 )
 
 func TestTracePieplineRecordedMetrics(t *testing.T) {
@@ -45,3 +46,18 @@ func TestTracePieplineRecordedMetrics(t *testing.T) {
 	observabilitytest.CheckValueViewExporterReceivedSpans(t, receiverName, exporterName, 27)
 	observabilitytest.CheckValueViewExporterDroppedSpans(t, receiverName, exporterName, 23)
 }
+
+func TestTraceProcessorRecordedMetrics(t *testing.T) {
+	defer observabilitytest.SetupRecordedMetricsTest(t)()
+
+	receiverCtx := observability.ContextWithReceiverName(context.Background(), receiverName)
+	processorCtx := observability.ContextWithProcessorName(receiverCtx, processorName)
+	observability.RecordTraceProcessorMetrics(processorCtx, 11)
+	observabilitytest.CheckValueViewProcessorDroppedSpans(t, receiverName, processorName, 11)
+	observability.RecordTraceProcessorDelayedSpans(processorCtx, 5)
+	observabilitytest.CheckValueViewProcessorDelayedSpans(t, receiverName, processorName, 5)
+	observability.RecordTraceProcessorModifiedSpans(processorCtx, 3)
+	observabilitytest.CheckValueViewProcessorModifiedSpans(t, receiverName, processorName, 3)
+	observability.RecordTraceProcessorBufferedBytes(processorCtx, 2048)
+	observabilitytest.CheckValueViewProcessorBufferedBytes(t, receiverName, processorName, 2048)
+}
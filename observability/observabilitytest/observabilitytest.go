@@ -144,6 +144,38 @@ func CheckValueViewReceiverDroppedSpans(t *testing.T, receiverName string, value
 		wantsTagsForReceiverView(receiverName), value)
 }
 
+// CheckValueViewProcessorDroppedSpans checks that for the current exported value in the ViewProcessorDroppedSpans
+// for {TagKeyReceiver: receiverName, TagKeyProcessor: processorName} is equal to "value".
+// In tests that this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckValueViewProcessorDroppedSpans(t *testing.T, receiverName string, processorName string, value int64) {
+	checkValueForView(t, observability.ViewProcessorDroppedSpans.Name,
+		wantsTagsForProcessorView(receiverName, processorName), value)
+}
+
+// CheckValueViewProcessorDelayedSpans checks that for the current exported value in the ViewProcessorDelayedSpans
+// for {TagKeyReceiver: receiverName, TagKeyProcessor: processorName} is equal to "value".
+// In tests that this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckValueViewProcessorDelayedSpans(t *testing.T, receiverName string, processorName string, value int64) {
+	checkValueForView(t, observability.ViewProcessorDelayedSpans.Name,
+		wantsTagsForProcessorView(receiverName, processorName), value)
+}
+
+// CheckValueViewProcessorModifiedSpans checks that for the current exported value in the ViewProcessorModifiedSpans
+// for {TagKeyReceiver: receiverName, TagKeyProcessor: processorName} is equal to "value".
+// In tests that this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckValueViewProcessorModifiedSpans(t *testing.T, receiverName string, processorName string, value int64) {
+	checkValueForView(t, observability.ViewProcessorModifiedSpans.Name,
+		wantsTagsForProcessorView(receiverName, processorName), value)
+}
+
+// CheckValueViewProcessorBufferedBytes checks that for the current exported value in the ViewProcessorBufferedBytes
+// for {TagKeyReceiver: receiverName, TagKeyProcessor: processorName} is equal to "value".
+// In tests that this function is called it is required to also call SetupRecordedMetricsTest as first thing.
+func CheckValueViewProcessorBufferedBytes(t *testing.T, receiverName string, processorName string, value int64) {
+	checkValueForView(t, observability.ViewProcessorBufferedBytes.Name,
+		wantsTagsForProcessorView(receiverName, processorName), value)
+}
+
 func checkValueForView(t *testing.T, vName string, wantTags []tag.Tag, value int64) {
 	// Make sure the tags slice is sorted by tag keys.
 	sortTags(wantTags)
@@ -157,9 +189,17 @@ func checkValueForView(t *testing.T, vName string, wantTags []tag.Tag, value int
 		// Make sure the tags slice is sorted by tag keys.
 		sortTags(row.Tags)
 		if reflect.DeepEqual(wantTags, row.Tags) {
-			sum := row.Data.(*view.SumData)
-			if float64(value) != sum.Value {
-				t.Fatalf("Want %v got %v", float64(value), sum.Value)
+			var got float64
+			switch data := row.Data.(type) {
+			case *view.SumData:
+				got = data.Value
+			case *view.LastValueData:
+				got = data.Value
+			default:
+				t.Fatalf("Unexpected data %T for view Name %s", row.Data, vName)
+			}
+			if float64(value) != got {
+				t.Fatalf("Want %v got %v", float64(value), got)
 			}
 			// We found the result
 			return
@@ -175,6 +215,13 @@ func wantsTagsForExporterView(receiverName string, exporterTagName string) []tag
 	}
 }
 
+func wantsTagsForProcessorView(receiverName string, processorName string) []tag.Tag {
+	return []tag.Tag{
+		{Key: observability.TagKeyReceiver, Value: receiverName},
+		{Key: observability.TagKeyProcessor, Value: processorName},
+	}
+}
+
 func wantsTagsForReceiverView(receiverName string) []tag.Tag {
 	return []tag.Tag{
 		{Key: observability.TagKeyReceiver, Value: receiverName},
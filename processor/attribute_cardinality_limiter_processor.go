@@ -0,0 +1,238 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const (
+	attributeCardinalityLimiterName = "attribute_cardinality_limiter"
+
+	// OtherAttributeValue replaces the attribute values over the budget of
+	// an attribute cardinality limiter.
+	OtherAttributeValue = "other"
+)
+
+// ErrInvalidMaxValues occurs when the number of distinct values kept by an
+// attribute cardinality limiter is less than 1.
+var ErrInvalidMaxValues = errors.New("invalid maximum number of distinct values, it must be greater than zero")
+
+// DefaultCardinalityAttributes returns the attributes limited by
+// NewAttributeCardinalityLimiter unless WithCardinalityAttributes is used,
+// the attributes of the PostgreSQL receiver naming tenants.
+func DefaultCardinalityAttributes() []string {
+	return []string{"database_name", "username"}
+}
+
+// valueCounts counts the values of an attribute over a sliding window, as the
+// counts of the current window and of the previous one.
+type valueCounts struct {
+	current, previous map[string]int64
+}
+
+func (vc *valueCounts) frequency(value string) int64 {
+	return vc.current[value] + vc.previous[value]
+}
+
+type attributeCardinalityLimiter struct {
+	next      TraceDataProcessor
+	maxValues int
+	window    time.Duration
+	keys      []string
+	// allowlist holds the values kept by attribute, the other values being
+	// replaced. It is nil when the values are kept on their frequency.
+	allowlist map[string]map[string]bool
+	now       func() time.Time
+
+	mu      sync.Mutex
+	counts  map[string]*valueCounts
+	rotated time.Time
+}
+
+var _ TraceDataProcessor = (*attributeCardinalityLimiter)(nil)
+
+// AttributeCardinalityOption is an option to NewAttributeCardinalityLimiter.
+type AttributeCardinalityOption func(*attributeCardinalityLimiter)
+
+// WithCardinalityAttributes sets the attributes whose values are limited,
+// DefaultCardinalityAttributes by default.
+func WithCardinalityAttributes(keys ...string) AttributeCardinalityOption {
+	return func(acl *attributeCardinalityLimiter) {
+		acl.keys = keys
+	}
+}
+
+// WithCardinalityAllowlist makes the limiter keep the values of allowlist, by
+// attribute, and replace all the others, instead of keeping the most frequent
+// values.
+func WithCardinalityAllowlist(allowlist map[string][]string) AttributeCardinalityOption {
+	return func(acl *attributeCardinalityLimiter) {
+		acl.allowlist = make(map[string]map[string]bool, len(allowlist))
+		for key, values := range allowlist {
+			acl.allowlist[key] = make(map[string]bool, len(values))
+			for _, value := range values {
+				acl.allowlist[key][value] = true
+			}
+		}
+	}
+}
+
+// NewAttributeCardinalityLimiter creates a TraceDataProcessor that bounds the
+// number of distinct values of the attributes naming tenants, such as the
+// database_name and username attributes of multi-tenant databases, which
+// would otherwise explode the cardinality of the labels of the backends. For
+// every attribute, the maxValues most frequent values over the last window,
+// up to two windows, are kept and the other values are replaced with
+// OtherAttributeValue, the first values in alphabetical order being kept
+// among equally frequent ones. With WithCardinalityAllowlist, the allowed
+// values are kept instead. The spans with a replaced value are counted in the
+// modified spans metric.
+func NewAttributeCardinalityLimiter(next TraceDataProcessor, maxValues int, window time.Duration, opts ...AttributeCardinalityOption) (TraceDataProcessor, error) {
+	if maxValues < 1 {
+		return nil, ErrInvalidMaxValues
+	}
+	acl := &attributeCardinalityLimiter{
+		next:      next,
+		maxValues: maxValues,
+		window:    window,
+		keys:      DefaultCardinalityAttributes(),
+		now:       time.Now,
+		counts:    make(map[string]*valueCounts),
+	}
+	for _, opt := range opts {
+		opt(acl)
+	}
+	return acl, nil
+}
+
+func (acl *attributeCardinalityLimiter) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	kept := acl.keptValues(td.Spans)
+	modified := 0
+	for _, span := range td.Spans {
+		replaced := false
+		for _, key := range acl.keys {
+			value, ok := stringAttribute(span, key)
+			if ok && !kept[key][value] {
+				setSpanAttribute(span, key, stringAttributeValue(OtherAttributeValue))
+				replaced = true
+			}
+		}
+		if replaced {
+			modified++
+		}
+	}
+	if modified > 0 {
+		observability.RecordTraceProcessorModifiedSpans(
+			observability.ContextWithProcessorName(ctx, attributeCardinalityLimiterName), modified)
+	}
+	return acl.next.ProcessTraceData(ctx, td)
+}
+
+// keptValues counts the values of the limited attributes of spans and returns
+// the values kept, by attribute.
+func (acl *attributeCardinalityLimiter) keptValues(spans []*tracepb.Span) map[string]map[string]bool {
+	if acl.allowlist != nil {
+		return acl.allowlist
+	}
+
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+
+	acl.rotate(acl.now())
+	kept := make(map[string]map[string]bool, len(acl.keys))
+	for _, key := range acl.keys {
+		counts := acl.counts[key]
+		if counts == nil {
+			counts = &valueCounts{current: make(map[string]int64), previous: make(map[string]int64)}
+			acl.counts[key] = counts
+		}
+		for _, span := range spans {
+			if value, ok := stringAttribute(span, key); ok {
+				counts.current[value]++
+			}
+		}
+		kept[key] = topValues(counts, acl.maxValues)
+	}
+	return kept
+}
+
+// rotate starts a new window once the current one is over. It must be called
+// with the lock held.
+func (acl *attributeCardinalityLimiter) rotate(now time.Time) {
+	if acl.rotated.IsZero() {
+		acl.rotated = now
+		return
+	}
+	elapsed := now.Sub(acl.rotated)
+	if elapsed < acl.window {
+		return
+	}
+	for _, counts := range acl.counts {
+		if elapsed < 2*acl.window {
+			counts.previous = counts.current
+		} else {
+			// The current window is too old to count.
+			counts.previous = make(map[string]int64)
+		}
+		counts.current = make(map[string]int64)
+	}
+	acl.rotated = now
+}
+
+// topValues returns the maxValues most frequent values of counts.
+func topValues(counts *valueCounts, maxValues int) map[string]bool {
+	values := make([]string, 0, len(counts.current)+len(counts.previous))
+	for value := range counts.current {
+		values = append(values, value)
+	}
+	for value := range counts.previous {
+		if _, ok := counts.current[value]; !ok {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		fi, fj := counts.frequency(values[i]), counts.frequency(values[j])
+		if fi != fj {
+			return fi > fj
+		}
+		return values[i] < values[j]
+	})
+	if len(values) > maxValues {
+		values = values[:maxValues]
+	}
+	top := make(map[string]bool, len(values))
+	for _, value := range values {
+		top[value] = true
+	}
+	return top
+}
+
+// stringAttribute returns the value of the string attribute key of span.
+func stringAttribute(span *tracepb.Span, key string) (string, bool) {
+	value, ok := spanAttribute(span, key).GetValue().(*tracepb.AttributeValue_StringValue)
+	if !ok {
+		return "", false
+	}
+	return value.StringValue.GetValue(), true
+}
@@ -0,0 +1,114 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func newTenantSpans(databaseNames ...string) []*tracepb.Span {
+	spans := make([]*tracepb.Span, 0, len(databaseNames))
+	for i, databaseName := range databaseNames {
+		span := newQuerySpan(byte(i+1), "select 1")
+		setSpanAttribute(span, "database_name", stringAttributeValue(databaseName))
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+func databaseNames(spans []*tracepb.Span) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		name, _ := stringAttribute(span, "database_name")
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestAttributeCardinalityLimiter(t *testing.T) {
+	next := &batchRecorder{}
+	p, err := NewAttributeCardinalityLimiter(next, 2, time.Minute)
+	if err != nil {
+		t.Fatalf("NewAttributeCardinalityLimiter() error = %v", err)
+	}
+	acl := p.(*attributeCardinalityLimiter)
+	now := time.Unix(1546300800, 0)
+	acl.now = func() time.Time { return now }
+	process := func(names ...string) []string {
+		spans := newTenantSpans(names...)
+		if err := acl.ProcessTraceData(context.Background(), data.TraceData{Spans: append(spans, nil)}); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+		return databaseNames(spans)
+	}
+
+	tests := []struct {
+		elapsed time.Duration
+		names   []string
+		want    []string
+	}{
+		// The two most frequent databases are kept.
+		{0, []string{"a", "b", "a", "c", "b", "a"}, []string{"a", "b", "a", "other", "b", "a"}},
+		// c is as frequent as b, which comes first.
+		{time.Second, []string{"c"}, []string{"other"}},
+		// The previous window still counts: d is the most frequent, and b is
+		// as frequent as a, which comes first.
+		{time.Minute, []string{"d", "d", "d", "d", "b"}, []string{"d", "d", "d", "d", "other"}},
+		// The first window no longer counts.
+		{time.Minute, []string{"b", "b", "a"}, []string{"b", "b", "other"}},
+		// After two windows without spans, nothing counts.
+		{3 * time.Minute, []string{"c", "e", "a"}, []string{"c", "other", "a"}},
+	}
+	for i, tt := range tests {
+		now = now.Add(tt.elapsed)
+		if got := process(tt.names...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Step %d: got %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestAttributeCardinalityLimiterAllowlist(t *testing.T) {
+	next := &batchRecorder{}
+	acl, err := NewAttributeCardinalityLimiter(next, 1, time.Minute,
+		WithCardinalityAttributes("database_name"),
+		WithCardinalityAllowlist(map[string][]string{"database_name": {"orders", "users"}}))
+	if err != nil {
+		t.Fatalf("NewAttributeCardinalityLimiter() error = %v", err)
+	}
+	spans := newTenantSpans("orders", "tenant_42", "users")
+	setSpanAttribute(spans[1], "username", stringAttributeValue("tenant_42"))
+	if err := acl.ProcessTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if got, want := databaseNames(spans), []string{"orders", "other", "users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+	// The attributes that are not limited are kept.
+	if got, _ := stringAttribute(spans[1], "username"); got != "tenant_42" {
+		t.Errorf("Got username %q, want %q", got, "tenant_42")
+	}
+}
+
+func TestNewAttributeCardinalityLimiterInvalidMaxValues(t *testing.T) {
+	if _, err := NewAttributeCardinalityLimiter(&batchRecorder{}, 0, time.Minute); err != ErrInvalidMaxValues {
+		t.Errorf("NewAttributeCardinalityLimiter() error = %v, want %v", err, ErrInvalidMaxValues)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"sort"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+type attributeCountLimiter struct {
+	next     TraceDataProcessor
+	maxAttrs int
+	priority map[string]int
+}
+
+var _ TraceDataProcessor = (*attributeCountLimiter)(nil)
+
+// NewAttributeCountLimiter creates a TraceDataProcessor that keeps at most
+// maxAttrs attributes per span, so that spans are not rejected by backends
+// capping the number of attributes. The attributes listed in priority are kept
+// first, in that order, then the others in lexicographic order of their keys.
+// The number of removed attributes is added to the DroppedAttributesCount of
+// the span. A negative maxAttrs is treated as zero.
+func NewAttributeCountLimiter(next TraceDataProcessor, maxAttrs int, priority ...string) TraceDataProcessor {
+	if maxAttrs < 0 {
+		maxAttrs = 0
+	}
+	priorityIndex := make(map[string]int, len(priority))
+	for i, key := range priority {
+		if _, ok := priorityIndex[key]; !ok {
+			priorityIndex[key] = i
+		}
+	}
+	return &attributeCountLimiter{
+		next:     next,
+		maxAttrs: maxAttrs,
+		priority: priorityIndex,
+	}
+}
+
+func (acl *attributeCountLimiter) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	for _, span := range td.Spans {
+		if span == nil || span.Attributes == nil || len(span.Attributes.AttributeMap) <= acl.maxAttrs {
+			continue
+		}
+
+		attrs := span.Attributes.AttributeMap
+		keys := make([]string, 0, len(attrs))
+		for key := range attrs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			pi, iok := acl.priority[keys[i]]
+			pj, jok := acl.priority[keys[j]]
+			switch {
+			case iok && jok:
+				return pi < pj
+			case iok != jok:
+				return iok
+			default:
+				return keys[i] < keys[j]
+			}
+		})
+
+		for _, key := range keys[acl.maxAttrs:] {
+			delete(attrs, key)
+		}
+		span.Attributes.DroppedAttributesCount += int32(len(keys) - acl.maxAttrs)
+	}
+	return acl.next.ProcessTraceData(ctx, td)
+}
@@ -0,0 +1,93 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestAttributeCountLimiter(t *testing.T) {
+	newSpan := func() *tracepb.Span {
+		span := &tracepb.Span{}
+		for _, key := range []string{"d", "query", "a", "c", "b"} {
+			setSpanAttribute(span, key, stringAttributeValue(key))
+		}
+		span.Attributes.DroppedAttributesCount = 1
+		return span
+	}
+
+	tests := []struct {
+		name        string
+		maxAttrs    int
+		priority    []string
+		wantKeys    []string
+		wantDropped int32
+	}{
+		{
+			name:        "lexicographic",
+			maxAttrs:    3,
+			wantKeys:    []string{"a", "b", "c"},
+			wantDropped: 3,
+		},
+		{
+			name:        "priority first",
+			maxAttrs:    3,
+			priority:    []string{"query", "d"},
+			wantKeys:    []string{"a", "d", "query"},
+			wantDropped: 3,
+		},
+		{
+			name:        "negative limit",
+			maxAttrs:    -1,
+			wantKeys:    nil,
+			wantDropped: 6,
+		},
+		{
+			name:        "under the limit",
+			maxAttrs:    5,
+			wantKeys:    []string{"a", "b", "c", "d", "query"},
+			wantDropped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &mockTraceDataProcessor{}
+			acl := NewAttributeCountLimiter(next, tt.maxAttrs, tt.priority...)
+			span := newSpan()
+			if err := acl.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{span, nil}}); err != nil {
+				t.Fatalf("Wanted nil got error %v", err)
+			}
+
+			var gotKeys []string
+			for key := range span.Attributes.AttributeMap {
+				gotKeys = append(gotKeys, key)
+			}
+			sort.Strings(gotKeys)
+			if !reflect.DeepEqual(gotKeys, tt.wantKeys) {
+				t.Errorf("Got attributes %v, want %v", gotKeys, tt.wantKeys)
+			}
+			if got := span.Attributes.DroppedAttributesCount; got != tt.wantDropped {
+				t.Errorf("Got %d dropped attributes, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// setSpanAttribute sets the attribute key of span to value, creating the
+// attribute map if needed.
+func setSpanAttribute(span *tracepb.Span, key string, value *tracepb.AttributeValue) {
+	if span.Attributes == nil {
+		span.Attributes = &tracepb.Span_Attributes{}
+	}
+	if span.Attributes.AttributeMap == nil {
+		span.Attributes.AttributeMap = make(map[string]*tracepb.AttributeValue)
+	}
+	span.Attributes.AttributeMap[key] = value
+}
+
+// spanAttribute returns the attribute key of span, or nil if it is not set.
+func spanAttribute(span *tracepb.Span, key string) *tracepb.AttributeValue {
+	if span == nil || span.Attributes == nil {
+		return nil
+	}
+	return span.Attributes.AttributeMap[key]
+}
+
+func stringAttributeValue(val string) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_StringValue{
+			StringValue: &tracepb.TruncatableString{Value: val},
+		},
+	}
+}
+
+func int64AttributeValue(val int64) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_IntValue{IntValue: val},
+	}
+}
+
+func boolAttributeValue(val bool) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_BoolValue{BoolValue: val},
+	}
+}
+
+func doubleAttributeValue(val float64) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_DoubleValue{DoubleValue: val},
+	}
+}
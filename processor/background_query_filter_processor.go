@@ -0,0 +1,143 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"regexp"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const (
+	backgroundQueryFilterProcessorName = "background_query_filter"
+
+	usernameAttributeKey = "username"
+)
+
+type backgroundQueryFilterProcessor struct {
+	next     TraceDataProcessor
+	patterns []*regexp.Regexp
+	users    map[string]bool
+}
+
+var _ TraceDataProcessor = (*backgroundQueryFilterProcessor)(nil)
+
+// BackgroundQueryFilterOption is an option to NewBackgroundQueryFilter.
+type BackgroundQueryFilterOption func(*backgroundQueryFilterProcessor)
+
+// DefaultBackgroundQueryPatterns returns the patterns of the background
+// queries dropped by NewBackgroundQueryFilter by default: the autovacuum
+// workers, the transaction control statements and the checkpoints.
+func DefaultBackgroundQueryPatterns() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile(`(?i)^\s*autovacuum:`),
+		regexp.MustCompile(`(?i)^\s*(begin|start\s+transaction|commit|end|rollback|abort)\b[^;]*;?\s*$`),
+		regexp.MustCompile(`(?i)^\s*checkpoint\s*;?\s*$`),
+	}
+}
+
+// DefaultBackgroundUsers returns the users whose queries are dropped by
+// NewBackgroundQueryFilter by default, the maintenance roles of the managed
+// PostgreSQL services.
+func DefaultBackgroundUsers() []string {
+	return []string{"cloudsqladmin", "cloudsqlagent", "rdsadmin", "azure_superuser"}
+}
+
+// WithBackgroundQueryPatterns replaces the patterns of the background queries,
+// DefaultBackgroundQueryPatterns by default.
+func WithBackgroundQueryPatterns(patterns ...*regexp.Regexp) BackgroundQueryFilterOption {
+	return func(bqf *backgroundQueryFilterProcessor) {
+		bqf.patterns = patterns
+	}
+}
+
+// WithBackgroundUsers replaces the users running background queries,
+// DefaultBackgroundUsers by default.
+func WithBackgroundUsers(users ...string) BackgroundQueryFilterOption {
+	return func(bqf *backgroundQueryFilterProcessor) {
+		bqf.users = backgroundUsers(users)
+	}
+}
+
+// NewBackgroundQueryFilter creates a TraceDataProcessor that drops the traces
+// of the background activity of the database, to keep the exported traces
+// focused on the workload: the traces whose query attribute matches one of
+// the background query patterns, or whose username attribute is one of the
+// background users. The spans of the dropped traces are counted in the
+// processor dropped spans metric.
+func NewBackgroundQueryFilter(next TraceDataProcessor, opts ...BackgroundQueryFilterOption) TraceDataProcessor {
+	bqf := &backgroundQueryFilterProcessor{
+		next:     next,
+		patterns: DefaultBackgroundQueryPatterns(),
+		users:    backgroundUsers(DefaultBackgroundUsers()),
+	}
+	for _, opt := range opts {
+		opt(bqf)
+	}
+	return bqf
+}
+
+func backgroundUsers(users []string) map[string]bool {
+	set := make(map[string]bool, len(users))
+	for _, user := range users {
+		set[user] = true
+	}
+	return set
+}
+
+func (bqf *backgroundQueryFilterProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	traces, order := spansByTraceID(td.Spans)
+
+	kept := make([]*tracepb.Span, 0, len(td.Spans))
+	for _, traceID := range order {
+		spans := traces[traceID]
+		if !bqf.isBackground(spans) {
+			kept = append(kept, spans...)
+		}
+	}
+
+	if dropped := len(td.Spans) - len(kept); dropped > 0 {
+		observability.RecordTraceProcessorMetrics(
+			observability.ContextWithProcessorName(ctx, backgroundQueryFilterProcessorName), dropped)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	td.Spans = kept
+	return bqf.next.ProcessTraceData(ctx, td)
+}
+
+// isBackground reports whether the spans of a trace are those of a background
+// query.
+func (bqf *backgroundQueryFilterProcessor) isBackground(spans []*tracepb.Span) bool {
+	for _, span := range spans {
+		if username := spanAttribute(span, usernameAttributeKey).GetStringValue(); username != nil && bqf.users[username.Value] {
+			return true
+		}
+	}
+	query, ok := queryOfSpans(spans)
+	if !ok {
+		return false
+	}
+	for _, pattern := range bqf.patterns {
+		if pattern.MatchString(query) {
+			return true
+		}
+	}
+	return false
+}
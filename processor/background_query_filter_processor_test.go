@@ -0,0 +1,89 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestBackgroundQueryFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		username string
+		opts     []BackgroundQueryFilterOption
+		wantKept bool
+	}{
+		{name: "workload", query: "select * from orders where id = 1", username: "app", wantKept: true},
+		{name: "autovacuum", query: "autovacuum: VACUUM ANALYZE public.orders", username: "app"},
+		{name: "commit", query: "COMMIT;", username: "app"},
+		{name: "begin isolation", query: "begin isolation level repeatable read", username: "app"},
+		{name: "checkpoint", query: "CHECKPOINT", username: "app"},
+		{name: "commit in a query", query: "select * from commits", username: "app", wantKept: true},
+		{name: "background user", query: "select * from pg_stat_activity", username: "cloudsqladmin"},
+		{
+			name:     "custom pattern",
+			query:    "select pg_switch_wal()",
+			username: "app",
+			opts:     []BackgroundQueryFilterOption{WithBackgroundQueryPatterns(regexp.MustCompile(`pg_switch_wal`))},
+		},
+		{
+			name:     "custom users",
+			query:    "select * from pg_stat_activity",
+			username: "cloudsqladmin",
+			opts:     []BackgroundQueryFilterOption{WithBackgroundUsers("monitoring")},
+			wantKept: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := newQuerySpan(1, tt.query)
+			setSpanAttribute(root, usernameAttributeKey, stringAttributeValue(tt.username))
+			child := &tracepb.Span{TraceId: root.TraceId, SpanId: []byte{1, 0, 0, 0, 0, 0, 0, 2}, ParentSpanId: root.SpanId}
+			// Another trace of the same batch, always kept.
+			other := newQuerySpan(2, "select 1")
+
+			next := &batchRecorder{}
+			bqf := NewBackgroundQueryFilter(next, tt.opts...)
+			if err := bqf.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{root, child, other}}); err != nil {
+				t.Fatalf("Wanted nil got error %v", err)
+			}
+
+			want := 1
+			if tt.wantKept {
+				want = 3
+			}
+			if got := len(next.batches[0].Spans); got != want {
+				t.Errorf("Got %d spans, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestBackgroundQueryFilterDropsWholeBatch(t *testing.T) {
+	next := &batchRecorder{}
+	bqf := NewBackgroundQueryFilter(next)
+	if err := bqf.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{newQuerySpan(1, "commit")}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if len(next.batches) != 0 {
+		t.Errorf("Got %d batches, want none", len(next.batches))
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// CompressionGzip compresses the batches with gzip.
+	CompressionGzip = "gzip"
+
+	defaultCompressionBatchSize     = 512
+	defaultCompressionFlushInterval = 5 * time.Second
+)
+
+// ErrUnsupportedCompression occurs when the compression algorithm of a batch
+// compression processor is not supported.
+var ErrUnsupportedCompression = errors.New("unsupported compression, it must be gzip")
+
+// ErrInvalidFlushInterval occurs when the flush interval of a batch
+// compression processor is not positive.
+var ErrInvalidFlushInterval = errors.New("invalid flush interval, it must be positive")
+
+// CompressedTraceDataProcessor can be implemented by the exporters able to
+// send a compressed batch as is, which saves egress for the backends billed on
+// it. None of the exporters of this repository implements it yet, they are
+// sent the batches uncompressed.
+type CompressedTraceDataProcessor interface {
+	// ProcessCompressedTraceData sends payload, a batch of TraceData encoded
+	// as JSON lines, see NewRecordingProcessor, compressed with compression.
+	ProcessCompressedTraceData(ctx context.Context, compression string, payload []byte) error
+}
+
+type batchCompressionProcessor struct {
+	next          TraceDataProcessor
+	batchSize     int
+	flushInterval time.Duration
+	compression   string
+
+	// flushMu serializes the flushes so that the batches reach next in order.
+	// It is acquired before mu.
+	flushMu sync.Mutex
+
+	mu    sync.Mutex
+	batch []data.TraceData
+	spans int
+}
+
+var _ TraceDataProcessor = (*batchCompressionProcessor)(nil)
+
+// BatchCompressionOption is an option to NewBatchCompressionProcessor.
+type BatchCompressionOption func(*batchCompressionProcessor)
+
+// WithCompressionBatchSize sets the number of spans from which a batch is
+// flushed, 512 by default.
+func WithCompressionBatchSize(batchSize int) BatchCompressionOption {
+	return func(bcp *batchCompressionProcessor) {
+		bcp.batchSize = batchSize
+	}
+}
+
+// WithCompressionFlushInterval sets how often the pending batch is flushed
+// regardless of its size, 5s by default.
+func WithCompressionFlushInterval(flushInterval time.Duration) BatchCompressionOption {
+	return func(bcp *batchCompressionProcessor) {
+		bcp.flushInterval = flushInterval
+	}
+}
+
+// WithCompression sets the compression algorithm of the batches, gzip by
+// default and the only one supported.
+func WithCompression(compression string) BatchCompressionOption {
+	return func(bcp *batchCompressionProcessor) {
+		bcp.compression = compression
+	}
+}
+
+// NewBatchCompressionProcessor creates a TraceDataProcessor that accumulates
+// the TraceData into batches, flushed when they reach the batch size or at
+// the flush interval. When next implements CompressedTraceDataProcessor, a
+// batch is sent as a single compressed payload, otherwise its TraceData are
+// forwarded as they are, merged when they share their Node and Resource.
+// A full batch is flushed by the call filling it, the others by a background
+// loop, one batch at a time and in order. The errors of next are logged.
+func NewBatchCompressionProcessor(next TraceDataProcessor, opts ...BatchCompressionOption) (TraceDataProcessor, error) {
+	bcp := &batchCompressionProcessor{
+		next:          next,
+		batchSize:     defaultCompressionBatchSize,
+		flushInterval: defaultCompressionFlushInterval,
+		compression:   CompressionGzip,
+	}
+	for _, opt := range opts {
+		opt(bcp)
+	}
+	if bcp.compression != CompressionGzip {
+		return nil, ErrUnsupportedCompression
+	}
+	if bcp.batchSize < 1 {
+		return nil, ErrInvalidMaxSpansPerBatch
+	}
+	if bcp.flushInterval <= 0 {
+		return nil, ErrInvalidFlushInterval
+	}
+	go bcp.flushLoop()
+	return bcp, nil
+}
+
+func (bcp *batchCompressionProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	bcp.mu.Lock()
+	bcp.add(td)
+	full := bcp.spans >= bcp.batchSize
+	bcp.mu.Unlock()
+
+	if full {
+		bcp.flushPending()
+	}
+	return nil
+}
+
+// add appends td to the pending batch, merging it into the last TraceData of
+// the batch when they share their Node and Resource.
+func (bcp *batchCompressionProcessor) add(td data.TraceData) {
+	bcp.spans += len(td.Spans)
+	if n := len(bcp.batch); n > 0 {
+		last := &bcp.batch[n-1]
+		if proto.Equal(last.Node, td.Node) && proto.Equal(last.Resource, td.Resource) {
+			last.Spans = append(last.Spans, td.Spans...)
+			return
+		}
+	}
+	// Copy the spans so that merging does not write to the caller's array.
+	td.Spans = append(td.Spans[:0:0], td.Spans...)
+	bcp.batch = append(bcp.batch, td)
+}
+
+// cut returns the pending batch and starts a new one.
+func (bcp *batchCompressionProcessor) cut() []data.TraceData {
+	batch := bcp.batch
+	bcp.batch, bcp.spans = nil, 0
+	return batch
+}
+
+func (bcp *batchCompressionProcessor) flushLoop() {
+	ticker := time.NewTicker(bcp.flushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		bcp.flushPending()
+	}
+}
+
+// flushPending flushes the pending batch, if any. The batch is cut while
+// holding flushMu so that it is not sent before an earlier one.
+func (bcp *batchCompressionProcessor) flushPending() {
+	bcp.flushMu.Lock()
+	defer bcp.flushMu.Unlock()
+
+	bcp.mu.Lock()
+	batch := bcp.cut()
+	bcp.mu.Unlock()
+	if len(batch) > 0 {
+		bcp.flush(batch)
+	}
+}
+
+func (bcp *batchCompressionProcessor) flush(batch []data.TraceData) {
+	ctx := context.Background()
+	cp, ok := bcp.next.(CompressedTraceDataProcessor)
+	if !ok {
+		for _, td := range batch {
+			if err := bcp.next.ProcessTraceData(ctx, td); err != nil {
+				log.Printf("Failed to send a batch of %d spans: %v", len(td.Spans), err)
+			}
+		}
+		return
+	}
+
+	payload, err := compressTraceData(batch)
+	if err != nil {
+		log.Printf("Failed to compress a batch: %v", err)
+		return
+	}
+	if err := cp.ProcessCompressedTraceData(ctx, bcp.compression, payload); err != nil {
+		log.Printf("Failed to send a compressed batch of %d bytes: %v", len(payload), err)
+	}
+}
+
+// compressTraceData encodes batch as JSON lines compressed with gzip.
+func compressTraceData(batch []data.TraceData) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, td := range batch {
+		line, err := marshalTraceData(td)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := zw.Write(line); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// compressedRecorder hands over the compressed payloads it receives.
+type compressedRecorder struct {
+	mockTraceDataProcessor
+	payloads chan []byte
+}
+
+func (cr *compressedRecorder) ProcessCompressedTraceData(ctx context.Context, compression string, payload []byte) error {
+	if compression != CompressionGzip {
+		return fmt.Errorf("unexpected compression %q", compression)
+	}
+	cr.payloads <- payload
+	return nil
+}
+
+// channelRecorder hands over the TraceData it receives.
+type channelRecorder chan data.TraceData
+
+func (cr channelRecorder) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	cr <- td
+	return nil
+}
+
+// newPlanTraceData returns a TraceData shaped like the ones of the postgres
+// receiver: a query span and a few plan node spans.
+func newPlanTraceData(traceID byte) data.TraceData {
+	spans := []*tracepb.Span{newQuerySpan(traceID, fmt.Sprintf("select * from orders where customer_id = %d", traceID))}
+	for _, nodeType := range []string{"Nested Loop", "Index Scan", "Seq Scan"} {
+		span := newQuerySpan(traceID, "")
+		span.Name = &tracepb.TruncatableString{Value: nodeType}
+		setSpanAttribute(span, "Table Name", stringAttributeValue("orders"))
+		setSpanAttribute(span, "Rows Fetched", int64AttributeValue(42))
+		spans = append(spans, span)
+	}
+	return data.TraceData{Node: &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "orders"}}, Spans: spans}
+}
+
+func TestNewBatchCompressionProcessorInvalidOptions(t *testing.T) {
+	if _, err := NewBatchCompressionProcessor(&mockTraceDataProcessor{}, WithCompression("zstd")); err != ErrUnsupportedCompression {
+		t.Errorf("NewBatchCompressionProcessor() error = %v, want %v", err, ErrUnsupportedCompression)
+	}
+	if _, err := NewBatchCompressionProcessor(&mockTraceDataProcessor{}, WithCompressionBatchSize(0)); err != ErrInvalidMaxSpansPerBatch {
+		t.Errorf("NewBatchCompressionProcessor() error = %v, want %v", err, ErrInvalidMaxSpansPerBatch)
+	}
+	for _, flushInterval := range []time.Duration{0, -time.Second} {
+		if _, err := NewBatchCompressionProcessor(&mockTraceDataProcessor{}, WithCompressionFlushInterval(flushInterval)); err != ErrInvalidFlushInterval {
+			t.Errorf("NewBatchCompressionProcessor(%v) error = %v, want %v", flushInterval, err, ErrInvalidFlushInterval)
+		}
+	}
+}
+
+func TestBatchCompressionProcessorCompresses(t *testing.T) {
+	const traces = 100
+	next := &compressedRecorder{payloads: make(chan []byte, 1)}
+	bcp, err := NewBatchCompressionProcessor(next, WithCompressionBatchSize(4*traces), WithCompressionFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("NewBatchCompressionProcessor() error = %v", err)
+	}
+	uncompressed := 0
+	for i := 0; i < traces; i++ {
+		td := newPlanTraceData(byte(i))
+		line, _ := marshalTraceData(td)
+		uncompressed += len(line)
+		if err := bcp.ProcessTraceData(context.Background(), td); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+
+	var payload []byte
+	select {
+	case payload = <-next.payloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Got no compressed batch")
+	}
+	t.Logf("Compressed %d bytes into %d bytes", uncompressed, len(payload))
+	if len(payload)*5 > uncompressed {
+		t.Errorf("Got %d bytes compressed from %d bytes, want at least a 5x reduction", len(payload), uncompressed)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("Failed to decompress the batch: %v", err)
+	}
+	lines, _ := ioutil.ReadAll(zr)
+	spans := 0
+	scanner := bufio.NewScanner(bytes.NewReader(lines))
+	scanner.Buffer(nil, maxRecordSize)
+	for scanner.Scan() {
+		td, err := unmarshalTraceData(scanner.Bytes())
+		if err != nil {
+			t.Fatalf("Failed to decode the batch: %v", err)
+		}
+		spans += len(td.Spans)
+	}
+	if spans != 4*traces {
+		t.Errorf("Got %d spans in the batch, want %d", spans, 4*traces)
+	}
+}
+
+func TestBatchCompressionProcessorForwards(t *testing.T) {
+	next := make(channelRecorder, 1)
+	bcp, err := NewBatchCompressionProcessor(next, WithCompressionBatchSize(8), WithCompressionFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("NewBatchCompressionProcessor() error = %v", err)
+	}
+	// Both TraceData share their node, they are merged.
+	for i := 0; i < 2; i++ {
+		if err := bcp.ProcessTraceData(context.Background(), newPlanTraceData(byte(i))); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+	select {
+	case td := <-next:
+		if len(td.Spans) != 8 {
+			t.Errorf("Got %d spans, want 8", len(td.Spans))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Got no batch")
+	}
+}
+
+func TestBatchCompressionProcessorKeepsOrder(t *testing.T) {
+	next := &batchRecorder{}
+	// Every TraceData fills a batch, which is flushed before the call returns.
+	bcp, err := NewBatchCompressionProcessor(next, WithCompressionBatchSize(4), WithCompressionFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("NewBatchCompressionProcessor() error = %v", err)
+	}
+	const traces = 10
+	for i := 0; i < traces; i++ {
+		if err := bcp.ProcessTraceData(context.Background(), newPlanTraceData(byte(i))); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+	if len(next.batches) != traces {
+		t.Fatalf("Got %d batches, want %d", len(next.batches), traces)
+	}
+	for i, td := range next.batches {
+		if want := newPlanTraceData(byte(i)).Spans[0].TraceId; !bytes.Equal(td.Spans[0].TraceId, want) {
+			t.Errorf("Got trace ID %x in batch %d, want %x", td.Spans[0].TraceId, i, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const circuitBreakerProcessorName = "circuit_breaker"
+
+// ErrCircuitBreakerOpen is returned by the circuit breaker processor when data
+// is dropped because the next processor has been failing.
+var ErrCircuitBreakerOpen = errors.New("circuit breaker is open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuitBreakerProcessor struct {
+	next             TraceDataProcessor
+	failureThreshold int
+	cooldown         time.Duration
+	now              func() time.Time
+
+	mu                  sync.Mutex
+	state               circuitState
+	consecutiveFailures int
+	openedAt            time.Time
+}
+
+var _ TraceDataProcessor = (*circuitBreakerProcessor)(nil)
+
+// NewCircuitBreakerProcessor creates a TraceDataProcessor that stops calling next
+// after failureThreshold consecutive failures. While the circuit is open all data
+// is dropped and ErrCircuitBreakerOpen is returned. Once cooldown has elapsed a
+// single TraceData is let through to test whether next has recovered: on success
+// the circuit closes again, on failure it re-opens for another cooldown period.
+func NewCircuitBreakerProcessor(next TraceDataProcessor, failureThreshold int, cooldown time.Duration) TraceDataProcessor {
+	if failureThreshold <= 0 {
+		failureThreshold = 1
+	}
+	return &circuitBreakerProcessor{
+		next:             next,
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		now:              time.Now,
+	}
+}
+
+func (cbp *circuitBreakerProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	if !cbp.allow() {
+		ctx = observability.ContextWithProcessorName(ctx, circuitBreakerProcessorName)
+		observability.RecordTraceProcessorMetrics(ctx, len(td.Spans))
+		return ErrCircuitBreakerOpen
+	}
+
+	err := cbp.next.ProcessTraceData(ctx, td)
+	cbp.onResult(err)
+	return err
+}
+
+// allow reports whether the request can be passed to the next processor,
+// moving an open circuit to half-open once the cooldown has elapsed.
+func (cbp *circuitBreakerProcessor) allow() bool {
+	cbp.mu.Lock()
+	defer cbp.mu.Unlock()
+
+	switch cbp.state {
+	case circuitOpen:
+		if cbp.now().Sub(cbp.openedAt) < cbp.cooldown {
+			return false
+		}
+		// Only the first request after the cooldown is used as a probe, the
+		// others are dropped until its result is known.
+		cbp.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+func (cbp *circuitBreakerProcessor) onResult(err error) {
+	cbp.mu.Lock()
+	defer cbp.mu.Unlock()
+
+	if err == nil {
+		cbp.state = circuitClosed
+		cbp.consecutiveFailures = 0
+		return
+	}
+
+	cbp.consecutiveFailures++
+	if cbp.state == circuitHalfOpen || cbp.consecutiveFailures >= cbp.failureThreshold {
+		cbp.state = circuitOpen
+		cbp.openedAt = cbp.now()
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestCircuitBreakerProcessor(t *testing.T) {
+	next := &mockTraceDataProcessor{MustFail: true}
+	cbp := NewCircuitBreakerProcessor(next, 3, time.Minute).(*circuitBreakerProcessor)
+	now := time.Unix(1000, 0)
+	cbp.now = func() time.Time { return now }
+
+	td := data.TraceData{
+		Spans: make([]*tracepb.Span, 2),
+	}
+
+	// The first failures are passed through until the threshold is reached.
+	for i := 0; i < 3; i++ {
+		if err := cbp.ProcessTraceData(context.Background(), td); err == nil || err == ErrCircuitBreakerOpen {
+			t.Fatalf("Wanted error from next processor got %v", err)
+		}
+	}
+	if err := cbp.ProcessTraceData(context.Background(), td); err != ErrCircuitBreakerOpen {
+		t.Fatalf("Wanted %v got %v", ErrCircuitBreakerOpen, err)
+	}
+	if next.TotalSpans != 6 {
+		t.Fatalf("Wanted 6 spans on next processor got %d", next.TotalSpans)
+	}
+
+	// A failed probe after the cooldown re-opens the circuit.
+	now = now.Add(time.Minute)
+	if err := cbp.ProcessTraceData(context.Background(), td); err == nil || err == ErrCircuitBreakerOpen {
+		t.Fatalf("Wanted error from next processor got %v", err)
+	}
+	if err := cbp.ProcessTraceData(context.Background(), td); err != ErrCircuitBreakerOpen {
+		t.Fatalf("Wanted %v got %v", ErrCircuitBreakerOpen, err)
+	}
+
+	// A successful probe closes the circuit.
+	now = now.Add(time.Minute)
+	next.MustFail = false
+	for i := 0; i < 2; i++ {
+		if err := cbp.ProcessTraceData(context.Background(), td); err != nil {
+			t.Fatalf("Wanted nil got %v", err)
+		}
+	}
+	if next.TotalSpans != 12 {
+		t.Fatalf("Wanted 12 spans on next processor got %d", next.TotalSpans)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+const (
+	coalescedCountAttributeKey = "coalesced_count"
+
+	defaultCoalesceMinSiblings = 3
+)
+
+type coalesceProcessor struct {
+	next        TraceDataProcessor
+	minSiblings int
+}
+
+var _ TraceDataProcessor = (*coalesceProcessor)(nil)
+
+// CoalesceOption is an option to NewCoalesceProcessor.
+type CoalesceOption func(*coalesceProcessor)
+
+// WithCoalesceMinSiblings sets the number of identical sibling spans from
+// which they are coalesced, 3 by default and at least 2.
+func WithCoalesceMinSiblings(minSiblings int) CoalesceOption {
+	return func(cp *coalesceProcessor) {
+		if minSiblings < 2 {
+			minSiblings = 2
+		}
+		cp.minSiblings = minSiblings
+	}
+}
+
+// NewCoalesceProcessor creates a TraceDataProcessor that merges the sibling
+// spans with the same name, e.g. the Seq Scan nodes of the partitions of a
+// table, into their first span when there are enough of them. The merged span
+// starts at the earliest start of the siblings, lasts the sum of their
+// durations and gets a coalesced_count attribute with their number. The
+// children of the merged siblings are attached to the merged span.
+func NewCoalesceProcessor(next TraceDataProcessor, opts ...CoalesceOption) TraceDataProcessor {
+	cp := &coalesceProcessor{
+		next:        next,
+		minSiblings: defaultCoalesceMinSiblings,
+	}
+	for _, opt := range opts {
+		opt(cp)
+	}
+	return cp
+}
+
+func (cp *coalesceProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	td.Spans = cp.coalesce(td.Spans)
+	return cp.next.ProcessTraceData(ctx, td)
+}
+
+// siblingKey identifies the spans coalesced together.
+type siblingKey struct {
+	traceID, parentSpanID, name string
+}
+
+func (cp *coalesceProcessor) coalesce(spans []*tracepb.Span) []*tracepb.Span {
+	siblings := make(map[siblingKey][]*tracepb.Span)
+	var order []siblingKey
+	for _, span := range spans {
+		if span == nil || len(span.ParentSpanId) == 0 {
+			continue
+		}
+		key := siblingKey{string(span.TraceId), string(span.ParentSpanId), span.Name.GetValue()}
+		if _, ok := siblings[key]; !ok {
+			order = append(order, key)
+		}
+		siblings[key] = append(siblings[key], span)
+	}
+
+	// mergedInto maps the span IDs of the merged siblings to the ID of the
+	// span they are merged into.
+	mergedInto := make(map[string][]byte)
+	for _, key := range order {
+		group := siblings[key]
+		if len(group) < cp.minSiblings {
+			continue
+		}
+		merged := group[0]
+		start := internal.TimestampToTime(merged.StartTime)
+		var duration time.Duration
+		for _, span := range group {
+			spanStart := internal.TimestampToTime(span.StartTime)
+			if spanStart.Before(start) {
+				start = spanStart
+			}
+			duration += internal.TimestampToTime(span.EndTime).Sub(spanStart)
+			if span != merged {
+				mergedInto[string(span.SpanId)] = merged.SpanId
+			}
+		}
+		merged.StartTime = internal.TimeToTimestamp(start)
+		merged.EndTime = internal.TimeToTimestamp(start.Add(duration))
+		setSpanAttribute(merged, coalescedCountAttributeKey, int64AttributeValue(int64(len(group))))
+	}
+	if len(mergedInto) == 0 {
+		return spans
+	}
+
+	kept := make([]*tracepb.Span, 0, len(spans)-len(mergedInto))
+	for _, span := range spans {
+		if span != nil {
+			if _, ok := mergedInto[string(span.SpanId)]; ok {
+				continue
+			}
+			if parentSpanID, ok := mergedInto[string(span.ParentSpanId)]; ok {
+				span.ParentSpanId = parentSpanID
+			}
+		}
+		kept = append(kept, span)
+	}
+	return kept
+}
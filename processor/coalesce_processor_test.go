@@ -0,0 +1,112 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+func newPlanNodeSpan(spanID, parentSpanID byte, name string, start time.Time, duration time.Duration) *tracepb.Span {
+	span := newTimedSpan(start, duration)
+	span.TraceId = []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	span.SpanId = []byte{spanID, 0, 0, 0, 0, 0, 0, 0}
+	if parentSpanID != 0 {
+		span.ParentSpanId = []byte{parentSpanID, 0, 0, 0, 0, 0, 0, 0}
+	}
+	span.Name = &tracepb.TruncatableString{Value: name}
+	return span
+}
+
+func TestCoalesceProcessor(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	appendNode := newPlanNodeSpan(1, 0, "Append", start, 10*time.Millisecond)
+	scans := []*tracepb.Span{
+		newPlanNodeSpan(2, 1, "Seq Scan", start.Add(2*time.Millisecond), time.Millisecond),
+		newPlanNodeSpan(3, 1, "Seq Scan", start.Add(time.Millisecond), 2*time.Millisecond),
+		newPlanNodeSpan(4, 1, "Seq Scan", start.Add(5*time.Millisecond), 3*time.Millisecond),
+	}
+	// A child of a merged scan, and a lone sibling of another type.
+	filter := newPlanNodeSpan(5, 4, "Bitmap Index Scan", start.Add(5*time.Millisecond), time.Millisecond)
+	index := newPlanNodeSpan(6, 1, "Index Scan", start, time.Millisecond)
+	spans := append([]*tracepb.Span{appendNode}, scans...)
+	spans = append(spans, filter, index)
+
+	next := &batchRecorder{}
+	cp := NewCoalesceProcessor(next)
+	if err := cp.ProcessTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	got := next.batches[0].Spans
+	if len(got) != 4 {
+		t.Fatalf("Got %d spans, want 4", len(got))
+	}
+	merged := scans[0]
+	if got := spanAttribute(merged, coalescedCountAttributeKey).GetIntValue(); got != 3 {
+		t.Errorf("Got coalesced_count %d, want 3", got)
+	}
+	if got := internal.TimestampToTime(merged.StartTime); !got.Equal(start.Add(time.Millisecond)) {
+		t.Errorf("Got start %v, want the earliest start of the siblings", got)
+	}
+	if got := internal.TimestampToTime(merged.EndTime).Sub(internal.TimestampToTime(merged.StartTime)); got != 6*time.Millisecond {
+		t.Errorf("Got duration %v, want the sum of the durations 6ms", got)
+	}
+	if !bytes.Equal(filter.ParentSpanId, merged.SpanId) {
+		t.Error("Got the child of a merged sibling not attached to the merged span")
+	}
+	if spanAttribute(index, coalescedCountAttributeKey) != nil || spanAttribute(appendNode, coalescedCountAttributeKey) != nil {
+		t.Error("Got coalesced_count on a span that was not coalesced")
+	}
+}
+
+func TestCoalesceProcessorMinSiblings(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	newSpans := func() []*tracepb.Span {
+		return []*tracepb.Span{
+			newPlanNodeSpan(1, 0, "Append", start, 10*time.Millisecond),
+			newPlanNodeSpan(2, 1, "Seq Scan", start, time.Millisecond),
+			newPlanNodeSpan(3, 1, "Seq Scan", start, time.Millisecond),
+		}
+	}
+
+	tests := []struct {
+		name      string
+		opts      []CoalesceOption
+		wantSpans int
+	}{
+		{name: "below the default", wantSpans: 3},
+		{name: "at the threshold", opts: []CoalesceOption{WithCoalesceMinSiblings(2)}, wantSpans: 2},
+		{name: "clamped threshold", opts: []CoalesceOption{WithCoalesceMinSiblings(0)}, wantSpans: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &mockTraceDataProcessor{}
+			cp := NewCoalesceProcessor(next, tt.opts...)
+			if err := cp.ProcessTraceData(context.Background(), data.TraceData{Spans: newSpans()}); err != nil {
+				t.Fatalf("Wanted nil got error %v", err)
+			}
+			if next.TotalSpans != tt.wantSpans {
+				t.Errorf("Got %d spans, want %d", next.TotalSpans, tt.wantSpans)
+			}
+		})
+	}
+}
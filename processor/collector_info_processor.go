@@ -0,0 +1,84 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"log"
+	"os"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/version"
+)
+
+const (
+	collectorHostnameAttributeKey = "collector.hostname"
+	collectorVersionAttributeKey  = "collector.version"
+)
+
+type collectorInfoProcessor struct {
+	next     TraceDataProcessor
+	hostname string
+	version  string
+}
+
+var _ TraceDataProcessor = (*collectorInfoProcessor)(nil)
+
+// NewCollectorInfoProcessor creates a TraceDataProcessor that sets the
+// "collector.hostname" and "collector.version" attributes on the root spans,
+// i.e. the spans whose parent is not part of the TraceData, identifying the
+// collector instance that processed the trace when several of them feed the
+// same backend. Both values are read once, when the processor is created.
+func NewCollectorInfoProcessor(next TraceDataProcessor) TraceDataProcessor {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Printf("Cannot get the hostname of the collector: %v", err)
+	}
+	return &collectorInfoProcessor{
+		next:     next,
+		hostname: hostname,
+		version:  version.Version,
+	}
+}
+
+func (cip *collectorInfoProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	for _, span := range rootSpans(td.Spans) {
+		if cip.hostname != "" {
+			setSpanAttribute(span, collectorHostnameAttributeKey, stringAttributeValue(cip.hostname))
+		}
+		setSpanAttribute(span, collectorVersionAttributeKey, stringAttributeValue(cip.version))
+	}
+	return cip.next.ProcessTraceData(ctx, td)
+}
+
+// rootSpans returns the spans whose parent is not part of spans. The parent of
+// a root span, if any, belongs to another process, e.g. the application that
+// ran a query.
+func rootSpans(spans []*tracepb.Span) []*tracepb.Span {
+	spanIDs := make(map[string]bool, len(spans))
+	for _, span := range spans {
+		if span != nil {
+			spanIDs[string(span.SpanId)] = true
+		}
+	}
+	var roots []*tracepb.Span
+	for _, span := range spans {
+		if span != nil && !spanIDs[string(span.ParentSpanId)] {
+			roots = append(roots, span)
+		}
+	}
+	return roots
+}
@@ -0,0 +1,52 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestCollectorInfoProcessor(t *testing.T) {
+	next := &mockTraceDataProcessor{}
+	cip := &collectorInfoProcessor{next: next, hostname: "collector-1", version: "v0.1.0"}
+
+	root := &tracepb.Span{SpanId: []byte{1, 0, 0, 0, 0, 0, 0, 0}}
+	child := &tracepb.Span{SpanId: []byte{2, 0, 0, 0, 0, 0, 0, 0}, ParentSpanId: root.SpanId}
+	// The parent of a linked root span belongs to another process.
+	linkedRoot := &tracepb.Span{SpanId: []byte{3, 0, 0, 0, 0, 0, 0, 0}, ParentSpanId: []byte{9, 0, 0, 0, 0, 0, 0, 0}}
+	if err := cip.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{child, root, linkedRoot, nil}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	if got := spanAttribute(root, collectorHostnameAttributeKey).GetStringValue().GetValue(); got != "collector-1" {
+		t.Errorf("Got hostname %q, want %q", got, "collector-1")
+	}
+	if got := spanAttribute(root, collectorVersionAttributeKey).GetStringValue().GetValue(); got != "v0.1.0" {
+		t.Errorf("Got version %q, want %q", got, "v0.1.0")
+	}
+	if got := spanAttribute(linkedRoot, collectorHostnameAttributeKey).GetStringValue().GetValue(); got != "collector-1" {
+		t.Errorf("Got hostname %q on the linked root span, want %q", got, "collector-1")
+	}
+	if child.Attributes != nil {
+		t.Errorf("Got attributes %v on a child span", child.Attributes)
+	}
+	if next.TotalSpans != 4 {
+		t.Errorf("Got %d spans forwarded, want 4", next.TotalSpans)
+	}
+}
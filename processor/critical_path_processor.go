@@ -0,0 +1,86 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+const onCriticalPathAttributeKey = "on_critical_path"
+
+type criticalPathProcessor struct {
+	next TraceDataProcessor
+}
+
+var _ TraceDataProcessor = (*criticalPathProcessor)(nil)
+
+// NewCriticalPathProcessor creates a TraceDataProcessor that sets an
+// on_critical_path attribute on the spans of the critical path of every
+// trace: from each root span, the spans whose parent is not in the trace, the
+// chain of the longest children down to a leaf. In a plan tree these are the
+// nodes that determined the duration of the query. Among children of equal
+// duration the one ending last is followed.
+func NewCriticalPathProcessor(next TraceDataProcessor) TraceDataProcessor {
+	return &criticalPathProcessor{next: next}
+}
+
+func (cpp *criticalPathProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	traces, order := spansByTraceID(td.Spans)
+	for _, traceID := range order {
+		markCriticalPath(traces[traceID])
+	}
+	return cpp.next.ProcessTraceData(ctx, td)
+}
+
+// markCriticalPath sets the on_critical_path attribute on the spans of the
+// critical path of spans, the spans of a trace.
+func markCriticalPath(spans []*tracepb.Span) {
+	children := make(map[string][]*tracepb.Span)
+	for _, span := range spans {
+		if span != nil && len(span.ParentSpanId) > 0 {
+			children[string(span.ParentSpanId)] = append(children[string(span.ParentSpanId)], span)
+		}
+	}
+	for _, root := range rootSpans(spans) {
+		// The bound on the length of the path guards against cycles in
+		// malformed traces.
+		for span, length := root, 0; span != nil && length < len(spans); length++ {
+			setSpanAttribute(span, onCriticalPathAttributeKey, boolAttributeValue(true))
+			span = longestSpan(children[string(span.SpanId)])
+		}
+	}
+}
+
+// longestSpan returns the longest of spans, the one ending last among the
+// longest ones, or nil if spans is empty.
+func longestSpan(spans []*tracepb.Span) *tracepb.Span {
+	var longest *tracepb.Span
+	for _, span := range spans {
+		if longest == nil {
+			longest = span
+			continue
+		}
+		duration, longestDuration := spanDuration(span), spanDuration(longest)
+		if duration > longestDuration || (duration == longestDuration &&
+			internal.TimestampToTime(span.EndTime).After(internal.TimestampToTime(longest.EndTime))) {
+			longest = span
+		}
+	}
+	return longest
+}
@@ -0,0 +1,72 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestCriticalPathProcessor(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	// Hash Join (1)
+	//   Seq Scan (2), 6ms
+	//   Hash (3), 3ms
+	//     Seq Scan (4), 2ms
+	// Index Scan (5) and Bitmap Heap Scan (6) last 1ms each under Seq Scan (2),
+	// the second ending last.
+	spans := []*tracepb.Span{
+		newPlanNodeSpan(1, 0, "Hash Join", start, 10*time.Millisecond),
+		newPlanNodeSpan(2, 1, "Seq Scan", start, 6*time.Millisecond),
+		newPlanNodeSpan(3, 1, "Hash", start.Add(6*time.Millisecond), 3*time.Millisecond),
+		newPlanNodeSpan(4, 3, "Seq Scan", start.Add(6*time.Millisecond), 2*time.Millisecond),
+		newPlanNodeSpan(5, 2, "Index Scan", start, time.Millisecond),
+		newPlanNodeSpan(6, 2, "Bitmap Heap Scan", start.Add(time.Millisecond), time.Millisecond),
+	}
+
+	next := &batchRecorder{}
+	cpp := NewCriticalPathProcessor(next)
+	if err := cpp.ProcessTraceData(context.Background(), data.TraceData{Spans: append(spans, nil)}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	wantOnPath := map[byte]bool{1: true, 2: true, 6: true}
+	for _, span := range spans {
+		onPath := spanAttribute(span, onCriticalPathAttributeKey).GetBoolValue()
+		if want := wantOnPath[span.SpanId[0]]; onPath != want {
+			t.Errorf("Span %d: got on_critical_path %v, want %v", span.SpanId[0], onPath, want)
+		}
+	}
+}
+
+func TestCriticalPathProcessorCycle(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	// Both spans are the parent of the other, neither is a root.
+	spans := []*tracepb.Span{
+		newPlanNodeSpan(1, 2, "Hash Join", start, time.Millisecond),
+		newPlanNodeSpan(2, 1, "Seq Scan", start, time.Millisecond),
+	}
+	next := &batchRecorder{}
+	if err := NewCriticalPathProcessor(next).ProcessTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if len(next.batches) != 1 {
+		t.Errorf("Got %d batches, want 1", len(next.batches))
+	}
+}
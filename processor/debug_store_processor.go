@@ -0,0 +1,236 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/golang/protobuf/proto"
+)
+
+// ErrInvalidDebugStoreCapacity occurs when the number of traces retained by a
+// debug store processor is less than 1.
+var ErrInvalidDebugStoreCapacity = errors.New("invalid debug store capacity, it must be greater than zero")
+
+// DebugStoreProcessor is a TraceDataProcessor serving the traces it received
+// last over HTTP, see NewDebugStoreProcessor.
+type DebugStoreProcessor interface {
+	TraceDataProcessor
+	http.Handler
+}
+
+type debugStoreProcessor struct {
+	next TraceDataProcessor
+	now  func() time.Time
+
+	mu sync.Mutex
+	// traces is a ring buffer of the retained traces, oldest is the index of
+	// the oldest one once it is full.
+	traces []*storedTrace
+	oldest int
+	// byID are the retained traces by trace ID.
+	byID map[string]*storedTrace
+}
+
+// storedTrace is a trace retained by a debug store processor.
+type storedTrace struct {
+	traceID     string
+	serviceName string
+	received    time.Time
+	spans       []*tracepb.Span
+}
+
+var _ DebugStoreProcessor = (*debugStoreProcessor)(nil)
+
+// NewDebugStoreProcessor creates a DebugStoreProcessor that forwards the
+// TraceData to next unchanged, and retains copies of the last capacity traces
+// it received in memory for local debugging. As an http.Handler it lists the
+// retained traces, newest first, and shows the spans of the trace whose hex
+// encoded ID is given by the "trace" query parameter, e.g.
+//
+//   http.Handle("/debug/traces", debugStore)
+//
+// The spans of a trace received in several TraceData are grouped while the
+// trace is retained.
+func NewDebugStoreProcessor(next TraceDataProcessor, capacity int) (DebugStoreProcessor, error) {
+	if capacity < 1 {
+		return nil, ErrInvalidDebugStoreCapacity
+	}
+	return &debugStoreProcessor{
+		next:   next,
+		now:    time.Now,
+		traces: make([]*storedTrace, 0, capacity),
+		byID:   make(map[string]*storedTrace),
+	}, nil
+}
+
+func (dsp *debugStoreProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	serviceName := td.Node.GetServiceInfo().GetName()
+	received := dsp.now()
+	traces, order := spansByTraceID(td.Spans)
+
+	dsp.mu.Lock()
+	for _, traceID := range order {
+		copies := make([]*tracepb.Span, 0, len(traces[traceID]))
+		for _, span := range traces[traceID] {
+			if span != nil {
+				// The next processors may modify the spans.
+				copies = append(copies, proto.Clone(span).(*tracepb.Span))
+			}
+		}
+		if len(copies) == 0 {
+			continue
+		}
+		if trace, ok := dsp.byID[traceID]; ok {
+			trace.spans = append(trace.spans, copies...)
+			continue
+		}
+		dsp.store(&storedTrace{traceID: traceID, serviceName: serviceName, received: received, spans: copies})
+	}
+	dsp.mu.Unlock()
+
+	return dsp.next.ProcessTraceData(ctx, td)
+}
+
+// store adds trace to the ring buffer, replacing the oldest trace once it is
+// full. It must be called with the lock held.
+func (dsp *debugStoreProcessor) store(trace *storedTrace) {
+	if len(dsp.traces) < cap(dsp.traces) {
+		dsp.traces = append(dsp.traces, trace)
+	} else {
+		delete(dsp.byID, dsp.traces[dsp.oldest].traceID)
+		dsp.traces[dsp.oldest] = trace
+		dsp.oldest = (dsp.oldest + 1) % len(dsp.traces)
+	}
+	dsp.byID[trace.traceID] = trace
+}
+
+// debugTraceRow is a row of the list of traces.
+type debugTraceRow struct {
+	TraceID     string
+	ServiceName string
+	Received    time.Time
+	RootName    string
+	Duration    time.Duration
+	SpanCount   int
+}
+
+// debugSpanRow is a row of the spans of a trace.
+type debugSpanRow struct {
+	Name         string
+	SpanID       string
+	ParentSpanID string
+	Start        time.Time
+	Duration     time.Duration
+	Attributes   map[string]string
+}
+
+var debugStoreTemplate = template.Must(template.New("traces").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Traces</title></head>
+<body>
+{{if .Spans}}
+<h1>Trace {{.TraceID}}</h1>
+<p><a href="?">All traces</a></p>
+<table>
+<tr><th>Name</th><th>Span ID</th><th>Parent span ID</th><th>Start</th><th>Duration</th><th>Attributes</th></tr>
+{{range .Spans}}<tr><td>{{.Name}}</td><td>{{.SpanID}}</td><td>{{.ParentSpanID}}</td><td>{{.Start.Format "2006-01-02T15:04:05.000000Z07:00"}}</td><td>{{.Duration}}</td><td>{{range $key, $value := .Attributes}}{{$key}}={{$value}} {{end}}</td></tr>
+{{end}}</table>
+{{else}}
+<h1>Last {{len .Traces}} traces</h1>
+<table>
+<tr><th>Trace ID</th><th>Service</th><th>Received</th><th>Root span</th><th>Duration</th><th>Spans</th></tr>
+{{range .Traces}}<tr><td><a href="?trace={{.TraceID}}">{{.TraceID}}</a></td><td>{{.ServiceName}}</td><td>{{.Received.Format "2006-01-02T15:04:05Z07:00"}}</td><td>{{.RootName}}</td><td>{{.Duration}}</td><td>{{.SpanCount}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+func (dsp *debugStoreProcessor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	page := struct {
+		TraceID string
+		Traces  []debugTraceRow
+		Spans   []debugSpanRow
+	}{}
+
+	dsp.mu.Lock()
+	if traceID := r.URL.Query().Get("trace"); traceID != "" {
+		id, err := hex.DecodeString(traceID)
+		trace, ok := dsp.byID[string(id)]
+		if err != nil || !ok {
+			dsp.mu.Unlock()
+			http.Error(w, "unknown trace "+traceID, http.StatusNotFound)
+			return
+		}
+		page.TraceID = traceID
+		page.Spans = debugSpanRows(trace.spans)
+	} else {
+		// Newest first.
+		for i := len(dsp.traces) - 1; i >= 0; i-- {
+			page.Traces = append(page.Traces, debugTraceRowOf(dsp.traces[(dsp.oldest+i)%len(dsp.traces)]))
+		}
+	}
+	dsp.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	debugStoreTemplate.Execute(w, page)
+}
+
+func debugTraceRowOf(trace *storedTrace) debugTraceRow {
+	row := debugTraceRow{
+		TraceID:     hex.EncodeToString([]byte(trace.traceID)),
+		ServiceName: trace.serviceName,
+		Received:    trace.received,
+		SpanCount:   len(trace.spans),
+	}
+	if root := rootSpan(trace.spans); root != nil {
+		row.RootName = root.GetName().GetValue()
+		row.Duration = spanDuration(root)
+	}
+	return row
+}
+
+func debugSpanRows(spans []*tracepb.Span) []debugSpanRow {
+	rows := make([]debugSpanRow, 0, len(spans))
+	for _, span := range spans {
+		attributes := make(map[string]string)
+		for key, value := range span.GetAttributes().GetAttributeMap() {
+			attributes[key], _ = attributeValueString(value)
+		}
+		rows = append(rows, debugSpanRow{
+			Name:         span.GetName().GetValue(),
+			SpanID:       hex.EncodeToString(span.SpanId),
+			ParentSpanID: hex.EncodeToString(span.ParentSpanId),
+			Start:        internal.TimestampToTime(span.StartTime),
+			Duration:     spanDuration(span),
+			Attributes:   attributes,
+		})
+	}
+	return rows
+}
+
+func spanDuration(span *tracepb.Span) time.Duration {
+	return internal.TimestampToTime(span.EndTime).Sub(internal.TimestampToTime(span.StartTime))
+}
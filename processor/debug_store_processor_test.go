@@ -0,0 +1,101 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestNewDebugStoreProcessorInvalidCapacity(t *testing.T) {
+	for _, capacity := range []int{0, -1} {
+		if _, err := NewDebugStoreProcessor(&mockTraceDataProcessor{}, capacity); err != ErrInvalidDebugStoreCapacity {
+			t.Errorf("Got error %v for capacity %d, want %v", err, capacity, ErrInvalidDebugStoreCapacity)
+		}
+	}
+}
+
+func serveDebugStore(t *testing.T, dsp DebugStoreProcessor, query string) (int, string) {
+	rec := httptest.NewRecorder()
+	dsp.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/traces"+query, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestDebugStoreProcessor(t *testing.T) {
+	next := &mockTraceDataProcessor{}
+	dsp, err := NewDebugStoreProcessor(next, 2)
+	if err != nil {
+		t.Fatalf("NewDebugStoreProcessor() error = %v", err)
+	}
+	node := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "orders"}}
+	for _, query := range []string{"select 1", "select 2", "select 3"} {
+		span := newQuerySpan(query[len(query)-1], query)
+		span.Name = &tracepb.TruncatableString{Value: "CloudSQLQuery"}
+		if err := dsp.ProcessTraceData(context.Background(), data.TraceData{Node: node, Spans: []*tracepb.Span{span, nil}}); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+	if next.TotalSpans != 6 {
+		t.Errorf("Got %d spans forwarded, want 6", next.TotalSpans)
+	}
+
+	code, body := serveDebugStore(t, dsp, "")
+	if code != http.StatusOK {
+		t.Fatalf("Got status %d, want %d", code, http.StatusOK)
+	}
+	// The IDs of the traces of "select 2" and "select 3".
+	trace2, trace3 := "32000000000000000000000000000000", "33000000000000000000000000000000"
+	if strings.Contains(body, "31000000000000000000000000000000") {
+		t.Error("Got the oldest trace retained past the capacity")
+	}
+	if i2, i3 := strings.Index(body, trace2), strings.Index(body, trace3); i2 < 0 || i3 < 0 || i3 > i2 {
+		t.Errorf("Got traces not listed newest first:\n%s", body)
+	}
+
+	// The spans of a trace received later are grouped with the trace.
+	child := newQuerySpan('3', "select 3")
+	child.SpanId = []byte{3, 0, 0, 0, 0, 0, 0, 2}
+	child.ParentSpanId = []byte{'3', 0, 0, 0, 0, 0, 0, 1}
+	child.Name = &tracepb.TruncatableString{Value: "Seq Scan"}
+	if err := dsp.ProcessTraceData(context.Background(), data.TraceData{Node: node, Spans: []*tracepb.Span{child}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	// The retained spans are copies.
+	child.Name.Value = "modified"
+
+	code, body = serveDebugStore(t, dsp, "?trace="+trace3)
+	if code != http.StatusOK {
+		t.Fatalf("Got status %d, want %d", code, http.StatusOK)
+	}
+	for _, want := range []string{"CloudSQLQuery", "Seq Scan", "query=select 3"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Got no %q in the trace page:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "modified") {
+		t.Error("Got a span modified after it was processed")
+	}
+
+	if code, _ := serveDebugStore(t, dsp, "?trace=31000000000000000000000000000000"); code != http.StatusNotFound {
+		t.Errorf("Got status %d for an evicted trace, want %d", code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const dedupProcessorName = "dedup"
+
+type dedupEntry struct {
+	hash   uint64
+	seenAt time.Time
+}
+
+type dedupProcessor struct {
+	next      TraceDataProcessor
+	cacheSize int
+	ttl       time.Duration
+	now       func() time.Time
+
+	mu sync.Mutex
+	// lru holds the dedupEntry of the most recently seen spans at its front.
+	lru     *list.List
+	entries map[uint64]*list.Element
+}
+
+var _ TraceDataProcessor = (*dedupProcessor)(nil)
+
+// NewDedupProcessor creates a TraceDataProcessor that drops the spans that were
+// already seen within ttl, guarding against the double delivery caused by
+// overlapping polls or upstream retries. Spans are identified by a hash of
+// their trace ID, span ID, name and timestamps, and at most cacheSize hashes
+// are remembered, the least recently seen ones being evicted first.
+func NewDedupProcessor(next TraceDataProcessor, cacheSize int, ttl time.Duration) TraceDataProcessor {
+	return &dedupProcessor{
+		next:      next,
+		cacheSize: cacheSize,
+		ttl:       ttl,
+		now:       time.Now,
+		lru:       list.New(),
+		entries:   make(map[uint64]*list.Element),
+	}
+}
+
+func (dp *dedupProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	unique := make([]*tracepb.Span, 0, len(td.Spans))
+	dp.mu.Lock()
+	now := dp.now()
+	for _, span := range td.Spans {
+		if span == nil || !dp.seen(spanHash(span), now) {
+			unique = append(unique, span)
+		}
+	}
+	dp.mu.Unlock()
+
+	if dropped := len(td.Spans) - len(unique); dropped > 0 {
+		observability.RecordTraceProcessorMetrics(
+			observability.ContextWithProcessorName(ctx, dedupProcessorName), dropped)
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+	td.Spans = unique
+	return dp.next.ProcessTraceData(ctx, td)
+}
+
+// seen records hash and reports whether it was already seen within the TTL.
+// It must be called with the lock held.
+func (dp *dedupProcessor) seen(hash uint64, now time.Time) bool {
+	if elem, ok := dp.entries[hash]; ok {
+		entry := elem.Value.(*dedupEntry)
+		dp.lru.MoveToFront(elem)
+		if now.Sub(entry.seenAt) < dp.ttl {
+			return true
+		}
+		// Expired, the span is forwarded again and its TTL restarts.
+		entry.seenAt = now
+		return false
+	}
+
+	dp.entries[hash] = dp.lru.PushFront(&dedupEntry{hash: hash, seenAt: now})
+	for dp.lru.Len() > dp.cacheSize {
+		oldest := dp.lru.Back()
+		dp.lru.Remove(oldest)
+		delete(dp.entries, oldest.Value.(*dedupEntry).hash)
+	}
+	return false
+}
+
+// spanHash computes the hash identifying span for deduplication.
+func spanHash(span *tracepb.Span) uint64 {
+	h := fnv.New64a()
+	h.Write(span.TraceId)
+	h.Write(span.SpanId)
+	h.Write([]byte(span.GetName().GetValue()))
+	var buf [12]byte
+	for _, ts := range []struct {
+		seconds int64
+		nanos   int32
+	}{
+		{span.GetStartTime().GetSeconds(), span.GetStartTime().GetNanos()},
+		{span.GetEndTime().GetSeconds(), span.GetEndTime().GetNanos()},
+	} {
+		binary.LittleEndian.PutUint64(buf[0:8], uint64(ts.seconds))
+		binary.LittleEndian.PutUint32(buf[8:12], uint32(ts.nanos))
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,65 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestDedupProcessor(t *testing.T) {
+	next := &mockTraceDataProcessor{}
+	dp := NewDedupProcessor(next, 2, time.Minute).(*dedupProcessor)
+	now := time.Unix(1000, 0)
+	dp.now = func() time.Time { return now }
+
+	span1 := newQuerySpan(1, "select 1")
+	span2 := newQuerySpan(2, "select 2")
+	span3 := newQuerySpan(3, "select 3")
+	process := func(spans ...*tracepb.Span) {
+		if err := dp.ProcessTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+
+	process(span1, span2, span1)
+	if next.TotalSpans != 2 {
+		t.Fatalf("Wanted 2 spans got %d", next.TotalSpans)
+	}
+
+	// Both spans are still cached.
+	process(span1, span2)
+	if next.TotalSpans != 2 {
+		t.Fatalf("Wanted 2 spans got %d", next.TotalSpans)
+	}
+
+	// span3 evicts span1, the least recently seen span.
+	process(span3)
+	process(span1)
+	if next.TotalSpans != 4 {
+		t.Fatalf("Wanted 4 spans got %d", next.TotalSpans)
+	}
+
+	// Once the TTL expired the duplicates are forwarded again.
+	now = now.Add(time.Minute)
+	process(span1)
+	if next.TotalSpans != 5 {
+		t.Fatalf("Wanted 5 spans got %d", next.TotalSpans)
+	}
+}
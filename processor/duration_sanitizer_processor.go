@@ -0,0 +1,67 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// implausibleDurationAttributeKey is the attribute set on spans whose duration
+// exceeds the maximum allowed by the duration sanitizer.
+const implausibleDurationAttributeKey = "implausible_duration"
+
+// minSpanDuration is the duration given to spans that end before they start.
+const minSpanDuration = time.Nanosecond
+
+type durationSanitizerProcessor struct {
+	next        TraceDataProcessor
+	maxDuration time.Duration
+}
+
+var _ TraceDataProcessor = (*durationSanitizerProcessor)(nil)
+
+// NewDurationSanitizerProcessor creates a TraceDataProcessor that protects the
+// backends from durations made absurd by clock skew or NTP steps. Spans that
+// end before they start are given a tiny positive duration, and spans longer
+// than maxDuration are flagged with the "implausible_duration" attribute.
+// A maxDuration of zero disables the flagging.
+func NewDurationSanitizerProcessor(next TraceDataProcessor, maxDuration time.Duration) TraceDataProcessor {
+	return &durationSanitizerProcessor{
+		next:        next,
+		maxDuration: maxDuration,
+	}
+}
+
+func (dsp *durationSanitizerProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	for _, span := range td.Spans {
+		if span == nil || span.StartTime == nil || span.EndTime == nil {
+			continue
+		}
+		startTime := internal.TimestampToTime(span.StartTime)
+		duration := internal.TimestampToTime(span.EndTime).Sub(startTime)
+		if duration < 0 {
+			span.EndTime = internal.TimeToTimestamp(startTime.Add(minSpanDuration))
+			continue
+		}
+		if dsp.maxDuration > 0 && duration > dsp.maxDuration {
+			setSpanAttribute(span, implausibleDurationAttributeKey, boolAttributeValue(true))
+		}
+	}
+	return dsp.next.ProcessTraceData(ctx, td)
+}
@@ -0,0 +1,59 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+func newTimedSpan(start time.Time, duration time.Duration) *tracepb.Span {
+	return &tracepb.Span{
+		StartTime: internal.TimeToTimestamp(start),
+		EndTime:   internal.TimeToTimestamp(start.Add(duration)),
+	}
+}
+
+func TestDurationSanitizerProcessor(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	negative := newTimedSpan(start, -time.Second)
+	tooLong := newTimedSpan(start, 2*time.Hour)
+	normal := newTimedSpan(start, time.Second)
+
+	next := &mockTraceDataProcessor{}
+	dsp := NewDurationSanitizerProcessor(next, time.Hour)
+	td := data.TraceData{Spans: []*tracepb.Span{negative, tooLong, normal, nil}}
+	if err := dsp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	if got := internal.TimestampToTime(negative.EndTime).Sub(start); got != minSpanDuration {
+		t.Errorf("Got duration %v for negative span, want %v", got, minSpanDuration)
+	}
+	if !spanAttribute(tooLong, implausibleDurationAttributeKey).GetBoolValue() {
+		t.Errorf("Span longer than the maximum duration was not flagged")
+	}
+	if spanAttribute(normal, implausibleDurationAttributeKey) != nil {
+		t.Errorf("Span with a sane duration was flagged")
+	}
+	if next.TotalSpans != 4 {
+		t.Errorf("Wanted 4 spans got %d", next.TotalSpans)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"os"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+type envAttributeProcessor struct {
+	next       TraceDataProcessor
+	attributes map[string]string
+}
+
+var _ TraceDataProcessor = (*envAttributeProcessor)(nil)
+
+// NewEnvAttributeProcessor creates a TraceDataProcessor that sets attributes
+// read from environment variables, e.g. "deployment.environment", on the root
+// spans. The keys of mapping are the attribute names and its values the names
+// of the environment variables. The variables are read once, when the
+// processor is created, and the ones that are not set are skipped.
+func NewEnvAttributeProcessor(next TraceDataProcessor, mapping map[string]string) TraceDataProcessor {
+	attributes := make(map[string]string)
+	for key, envVar := range mapping {
+		if value, ok := os.LookupEnv(envVar); ok {
+			attributes[key] = value
+		}
+	}
+	return &envAttributeProcessor{next: next, attributes: attributes}
+}
+
+func (eap *envAttributeProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	if len(eap.attributes) > 0 {
+		for _, span := range rootSpans(td.Spans) {
+			for key, value := range eap.attributes {
+				setSpanAttribute(span, key, stringAttributeValue(value))
+			}
+		}
+	}
+	return eap.next.ProcessTraceData(ctx, td)
+}
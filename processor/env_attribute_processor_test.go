@@ -0,0 +1,56 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestEnvAttributeProcessor(t *testing.T) {
+	os.Setenv("TEST_DEPLOYMENT_ENVIRONMENT", "staging")
+	defer os.Unsetenv("TEST_DEPLOYMENT_ENVIRONMENT")
+	os.Unsetenv("TEST_DEPLOYMENT_REGION")
+
+	eap := NewEnvAttributeProcessor(&mockTraceDataProcessor{}, map[string]string{
+		"deployment.environment": "TEST_DEPLOYMENT_ENVIRONMENT",
+		"deployment.region":      "TEST_DEPLOYMENT_REGION",
+	})
+	// The variables are read when the processor is created.
+	os.Setenv("TEST_DEPLOYMENT_ENVIRONMENT", "prod")
+
+	root := newQuerySpan(1, "select 1")
+	child := newQuerySpan(1, "select 1")
+	child.SpanId = []byte{1, 0, 0, 0, 0, 0, 0, 2}
+	child.ParentSpanId = root.SpanId
+	td := data.TraceData{Spans: []*tracepb.Span{child, root, nil}}
+	if err := eap.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	if got := spanAttribute(root, "deployment.environment").GetStringValue().GetValue(); got != "staging" {
+		t.Errorf("Got deployment.environment %q, want %q", got, "staging")
+	}
+	if spanAttribute(root, "deployment.region") != nil {
+		t.Error("Got deployment.region set from an unset variable")
+	}
+	if spanAttribute(child, "deployment.environment") != nil {
+		t.Error("Got deployment.environment on a child span")
+	}
+}
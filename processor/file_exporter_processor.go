@@ -0,0 +1,62 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// FileFormatOCJSON writes the TraceData as the lines of JSON of a recording
+// processor, the OpenCensus protobuf JSON encoding of their node, resource and
+// spans.
+const FileFormatOCJSON = "oc_json"
+
+// ErrUnsupportedFileFormat occurs when the format of a file exporter is not
+// supported. The OTLP JSON encoding needs the OpenTelemetry protocol
+// definitions, which are not among the dependencies.
+var ErrUnsupportedFileFormat = errors.New("unsupported file format, it must be oc_json")
+
+type fileExporterProcessor struct {
+	recorder *recordingProcessor
+}
+
+var _ TraceDataProcessor = (*fileExporterProcessor)(nil)
+
+// NewFileExporterProcessor creates a TraceDataProcessor that appends every
+// TraceData to the file at path, as a line of JSON in the given format, and
+// forwards nothing: it ends a pipeline where no exporter can be reached, and
+// the file can be sent later with ReplayFile. The failures to write a
+// TraceData are returned. WithRecordingMaxBytes rotates the file, and
+// WithRecordingSync syncs it after every TraceData.
+func NewFileExporterProcessor(path string, format string, opts ...RecordingOption) (TraceDataProcessor, error) {
+	if format != FileFormatOCJSON {
+		return nil, ErrUnsupportedFileFormat
+	}
+	rp := &recordingProcessor{path: path}
+	for _, opt := range opts {
+		opt(rp)
+	}
+	if err := rp.open(); err != nil {
+		return nil, err
+	}
+	return &fileExporterProcessor{recorder: rp}, nil
+}
+
+func (fep *fileExporterProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	return fep.recorder.record(td)
+}
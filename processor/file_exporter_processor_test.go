@@ -0,0 +1,65 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestFileExporterProcessor(t *testing.T) {
+	dir, cleanup := newRecordingDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "traces.jsonl")
+
+	fep, err := NewFileExporterProcessor(path, FileFormatOCJSON, WithRecordingMaxBytes(1), WithRecordingSync())
+	if err != nil {
+		t.Fatalf("NewFileExporterProcessor() error = %v", err)
+	}
+	for traceID := byte(1); traceID <= 2; traceID++ {
+		td := data.TraceData{Spans: []*tracepb.Span{newQuerySpan(traceID, "select 1")}}
+		if err := fep.ProcessTraceData(context.Background(), td); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+
+	// Every TraceData exceeds the size of the file, the first one was rotated.
+	for _, p := range []string{path + ".1", path} {
+		replayed := &batchRecorder{}
+		if err := ReplayFile(p, replayed); err != nil {
+			t.Fatalf("ReplayFile(%q) error = %v", p, err)
+		}
+		if len(replayed.batches) != 1 {
+			t.Errorf("Got %d TraceData in %q, want 1", len(replayed.batches), p)
+		}
+	}
+}
+
+func TestFileExporterProcessorErrors(t *testing.T) {
+	dir, cleanup := newRecordingDir(t)
+	defer cleanup()
+
+	if _, err := NewFileExporterProcessor(filepath.Join(dir, "traces.json"), "otlp_json"); err != ErrUnsupportedFileFormat {
+		t.Errorf("NewFileExporterProcessor() error = %v, want %v", err, ErrUnsupportedFileFormat)
+	}
+	if _, err := NewFileExporterProcessor(filepath.Join(dir, "missing", "traces.jsonl"), FileFormatOCJSON); !os.IsNotExist(err) {
+		t.Errorf("NewFileExporterProcessor() error = %v, want a missing directory", err)
+	}
+}
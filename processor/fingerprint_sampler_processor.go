@@ -0,0 +1,151 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const fingerprintSamplerProcessorName = "fingerprint_sampler"
+
+var (
+	// ErrInvalidFingerprintQuota occurs when the number of traces kept per
+	// fingerprint is less than 1.
+	ErrInvalidFingerprintQuota = errors.New("invalid number of traces per fingerprint, it must be greater than zero")
+	// ErrInvalidFingerprintWindow occurs when the sampling window is not
+	// positive.
+	ErrInvalidFingerprintWindow = errors.New("invalid fingerprint sampling window, it must be positive")
+)
+
+type fingerprintWindow struct {
+	start time.Time
+	count int
+}
+
+type fingerprintSamplerProcessor struct {
+	next                TraceDataProcessor
+	perFingerprintQuota int
+	window              time.Duration
+	now                 func() time.Time
+
+	mu         sync.Mutex
+	windows    map[string]*fingerprintWindow
+	lastPruned time.Time
+}
+
+var _ TraceDataProcessor = (*fingerprintSamplerProcessor)(nil)
+
+// NewFingerprintSamplerProcessor creates a TraceDataProcessor that keeps up to
+// perFingerprintQuota traces per query fingerprint in every window, and drops
+// the others. The fingerprint is computed from the "query" attribute of the
+// trace's spans, so that every distinct query shape is represented regardless
+// of how frequent it is. Traces without a query attribute are always kept.
+// Both perFingerprintQuota and window must be positive.
+func NewFingerprintSamplerProcessor(next TraceDataProcessor, perFingerprintQuota int, window time.Duration) (TraceDataProcessor, error) {
+	if perFingerprintQuota < 1 {
+		return nil, ErrInvalidFingerprintQuota
+	}
+	if window <= 0 {
+		return nil, ErrInvalidFingerprintWindow
+	}
+	return &fingerprintSamplerProcessor{
+		next:                next,
+		perFingerprintQuota: perFingerprintQuota,
+		window:              window,
+		now:                 time.Now,
+		windows:             make(map[string]*fingerprintWindow),
+	}, nil
+}
+
+func (fsp *fingerprintSamplerProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	traces, order := spansByTraceID(td.Spans)
+
+	sampled := make([]*tracepb.Span, 0, len(td.Spans))
+	fsp.mu.Lock()
+	now := fsp.now()
+	fsp.pruneWindows(now)
+	for _, traceID := range order {
+		spans := traces[traceID]
+		query, ok := queryOfSpans(spans)
+		if !ok || fsp.sample(fingerprintQuery(query), now) {
+			sampled = append(sampled, spans...)
+		}
+	}
+	fsp.mu.Unlock()
+
+	if dropped := len(td.Spans) - len(sampled); dropped > 0 {
+		observability.RecordTraceProcessorMetrics(
+			observability.ContextWithProcessorName(ctx, fingerprintSamplerProcessorName), dropped)
+	}
+	if len(sampled) == 0 {
+		return nil
+	}
+	td.Spans = sampled
+	return fsp.next.ProcessTraceData(ctx, td)
+}
+
+// sample reports whether the quota of fingerprint for the current window still
+// allows a trace. It must be called with the lock held.
+func (fsp *fingerprintSamplerProcessor) sample(fingerprint string, now time.Time) bool {
+	w := fsp.windows[fingerprint]
+	if w == nil || now.Sub(w.start) >= fsp.window {
+		w = &fingerprintWindow{start: now}
+		fsp.windows[fingerprint] = w
+	}
+	if w.count >= fsp.perFingerprintQuota {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// pruneWindows removes the windows that expired, at most once per window so
+// that the cost is amortized. It must be called with the lock held.
+func (fsp *fingerprintSamplerProcessor) pruneWindows(now time.Time) {
+	if now.Sub(fsp.lastPruned) < fsp.window {
+		return
+	}
+	for fingerprint, w := range fsp.windows {
+		if now.Sub(w.start) >= fsp.window {
+			delete(fsp.windows, fingerprint)
+		}
+	}
+	fsp.lastPruned = now
+}
+
+// spansByTraceID groups spans by their trace ID, also returning the trace IDs
+// in the order they first appear.
+func spansByTraceID(spans []*tracepb.Span) (map[string][]*tracepb.Span, []string) {
+	traces := make(map[string][]*tracepb.Span)
+	var order []string
+	for _, span := range spans {
+		var traceID string
+		if span != nil {
+			traceID = string(span.TraceId)
+		}
+		if _, ok := traces[traceID]; !ok {
+			order = append(order, traceID)
+		}
+		traces[traceID] = append(traces[traceID], span)
+	}
+	return traces, order
+}
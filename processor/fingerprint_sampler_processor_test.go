@@ -0,0 +1,87 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func newQuerySpan(traceID byte, query string) *tracepb.Span {
+	return &tracepb.Span{
+		TraceId: []byte{traceID, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		SpanId:  []byte{traceID, 0, 0, 0, 0, 0, 0, 1},
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{
+				"query": {
+					Value: &tracepb.AttributeValue_StringValue{
+						StringValue: &tracepb.TruncatableString{Value: query},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestFingerprintSamplerProcessor(t *testing.T) {
+	next := &mockTraceDataProcessor{}
+	tdp, err := NewFingerprintSamplerProcessor(next, 2, time.Minute)
+	if err != nil {
+		t.Fatalf("NewFingerprintSamplerProcessor() error = %v", err)
+	}
+	fsp := tdp.(*fingerprintSamplerProcessor)
+	now := time.Unix(1000, 0)
+	fsp.now = func() time.Time { return now }
+
+	td := data.TraceData{
+		Spans: []*tracepb.Span{
+			newQuerySpan(1, "select * from t where id = 1"),
+			newQuerySpan(2, "select * from t where id = 2"),
+			newQuerySpan(3, "select * from t where id = 3"),
+			newQuerySpan(4, "update t set v = 1"),
+			{TraceId: []byte{5}},
+		},
+	}
+	if err := fsp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	// The third select is over the quota of its fingerprint.
+	if next.TotalSpans != 4 {
+		t.Fatalf("Wanted 4 spans got %d", next.TotalSpans)
+	}
+
+	// The quota is replenished in the next window.
+	now = now.Add(time.Minute)
+	if err := fsp.ProcessTraceData(context.Background(), data.TraceData{Spans: td.Spans[:3]}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if next.TotalSpans != 6 {
+		t.Fatalf("Wanted 6 spans got %d", next.TotalSpans)
+	}
+}
+
+func TestNewFingerprintSamplerProcessorInvalid(t *testing.T) {
+	next := &mockTraceDataProcessor{}
+	if _, err := NewFingerprintSamplerProcessor(next, 0, time.Minute); err != ErrInvalidFingerprintQuota {
+		t.Errorf("Got error %v with a zero quota, want %v", err, ErrInvalidFingerprintQuota)
+	}
+	if _, err := NewFingerprintSamplerProcessor(next, 1, 0); err != ErrInvalidFingerprintWindow {
+		t.Errorf("Got error %v with a zero window, want %v", err, ErrInvalidFingerprintWindow)
+	}
+}
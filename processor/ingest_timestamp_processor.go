@@ -0,0 +1,60 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+const ingestAnnotationDescription = "ingest"
+
+type ingestTimestampProcessor struct {
+	next TraceDataProcessor
+	now  func() time.Time
+}
+
+var _ TraceDataProcessor = (*ingestTimestampProcessor)(nil)
+
+// NewIngestTimestampProcessor creates a TraceDataProcessor that adds an
+// "ingest" annotation, at the time the TraceData goes through the processor,
+// to the root spans, i.e. the spans whose parent is not part of the TraceData.
+// The delay between the end of a span and its ingest annotation is the delay
+// of the pipeline before the processor.
+func NewIngestTimestampProcessor(next TraceDataProcessor) TraceDataProcessor {
+	return &ingestTimestampProcessor{next: next, now: time.Now}
+}
+
+func (itp *ingestTimestampProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	now := internal.TimeToTimestamp(itp.now())
+	for _, span := range rootSpans(td.Spans) {
+		if span.TimeEvents == nil {
+			span.TimeEvents = &tracepb.Span_TimeEvents{}
+		}
+		span.TimeEvents.TimeEvent = append(span.TimeEvents.TimeEvent, &tracepb.Span_TimeEvent{
+			Time: now,
+			Value: &tracepb.Span_TimeEvent_Annotation_{
+				Annotation: &tracepb.Span_TimeEvent_Annotation{
+					Description: &tracepb.TruncatableString{Value: ingestAnnotationDescription},
+				},
+			},
+		})
+	}
+	return itp.next.ProcessTraceData(ctx, td)
+}
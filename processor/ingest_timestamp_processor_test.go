@@ -0,0 +1,56 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+func TestIngestTimestampProcessor(t *testing.T) {
+	ingest := time.Unix(1546300800, 0)
+	itp := NewIngestTimestampProcessor(&mockTraceDataProcessor{}).(*ingestTimestampProcessor)
+	itp.now = func() time.Time { return ingest }
+
+	root := newQuerySpan(1, "select 1")
+	// An existing event is kept.
+	root.TimeEvents = &tracepb.Span_TimeEvents{TimeEvent: []*tracepb.Span_TimeEvent{{}}}
+	child := newQuerySpan(1, "select 1")
+	child.SpanId = []byte{1, 0, 0, 0, 0, 0, 0, 2}
+	child.ParentSpanId = root.SpanId
+	td := data.TraceData{Spans: []*tracepb.Span{child, root, nil}}
+	if err := itp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	events := root.TimeEvents.GetTimeEvent()
+	if len(events) != 2 {
+		t.Fatalf("Got %d time events on the root span, want 2", len(events))
+	}
+	if got := events[1].GetAnnotation().GetDescription().GetValue(); got != ingestAnnotationDescription {
+		t.Errorf("Got annotation %q, want %q", got, ingestAnnotationDescription)
+	}
+	if got := internal.TimestampToTime(events[1].Time); !got.Equal(ingest) {
+		t.Errorf("Got ingest time %v, want %v", got, ingest)
+	}
+	if child.TimeEvents != nil {
+		t.Errorf("Got time events %v on a child span", child.TimeEvents)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/golang/protobuf/proto"
+)
+
+// droppedLargeAttributesAttributeKey is the attribute holding the number of
+// attributes dropped from a span because of their size.
+const droppedLargeAttributesAttributeKey = "dropped_large_attributes"
+
+// ErrInvalidMaxAttributeBytes occurs when the size threshold of a large
+// attribute dropper is less than 1.
+var ErrInvalidMaxAttributeBytes = errors.New("invalid maximum attribute size, it must be greater than zero")
+
+type largeAttributeDropper struct {
+	next     TraceDataProcessor
+	maxBytes int
+	// keys are the attributes that can be dropped, all of them when nil.
+	keys map[string]bool
+}
+
+var _ TraceDataProcessor = (*largeAttributeDropper)(nil)
+
+// NewLargeAttributeDropper creates a TraceDataProcessor that removes the
+// span attributes whose value is larger than maxBytes once serialized, e.g. the
+// full output list of a plan node, for the backends rejecting large
+// attributes rather than truncating them. When keys is not empty only the
+// attributes it names are dropped. The number of removed attributes is set in
+// a "dropped_large_attributes" attribute and added to the
+// DroppedAttributesCount of the span.
+func NewLargeAttributeDropper(next TraceDataProcessor, maxBytes int, keys []string) (TraceDataProcessor, error) {
+	if maxBytes < 1 {
+		return nil, ErrInvalidMaxAttributeBytes
+	}
+	lad := &largeAttributeDropper{next: next, maxBytes: maxBytes}
+	if len(keys) > 0 {
+		lad.keys = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			lad.keys[key] = true
+		}
+	}
+	return lad, nil
+}
+
+func (lad *largeAttributeDropper) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	for _, span := range td.Spans {
+		if span == nil || span.Attributes == nil {
+			continue
+		}
+		attrs := span.Attributes.AttributeMap
+		dropped := 0
+		for key, value := range attrs {
+			if lad.keys != nil && !lad.keys[key] {
+				continue
+			}
+			if proto.Size(value) > lad.maxBytes {
+				delete(attrs, key)
+				dropped++
+			}
+		}
+		if dropped == 0 {
+			continue
+		}
+		span.Attributes.DroppedAttributesCount += int32(dropped)
+		// Add to the count of a previous dropper.
+		if previous := spanAttribute(span, droppedLargeAttributesAttributeKey); previous != nil {
+			dropped += int(previous.GetIntValue())
+		}
+		setSpanAttribute(span, droppedLargeAttributesAttributeKey, int64AttributeValue(int64(dropped)))
+	}
+	return lad.next.ProcessTraceData(ctx, td)
+}
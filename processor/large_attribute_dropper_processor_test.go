@@ -0,0 +1,95 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestNewLargeAttributeDropperInvalidMaxBytes(t *testing.T) {
+	for _, maxBytes := range []int{0, -1} {
+		if _, err := NewLargeAttributeDropper(&mockTraceDataProcessor{}, maxBytes, nil); err != ErrInvalidMaxAttributeBytes {
+			t.Errorf("Got error %v for %d bytes, want %v", err, maxBytes, ErrInvalidMaxAttributeBytes)
+		}
+	}
+}
+
+func TestLargeAttributeDropper(t *testing.T) {
+	large := strings.Repeat("x", 200)
+	newSpan := func() *tracepb.Span {
+		span := newQuerySpan(1, large)
+		setSpanAttribute(span, "Output", stringAttributeValue(large))
+		setSpanAttribute(span, "Table Name", stringAttributeValue("orders"))
+		setSpanAttribute(span, "Rows Fetched", int64AttributeValue(10))
+		return span
+	}
+
+	tests := []struct {
+		name        string
+		keys        []string
+		wantDropped []string
+		wantKept    []string
+	}{
+		{"all keys", nil, []string{"query", "Output"}, []string{"Table Name", "Rows Fetched"}},
+		{"named keys", []string{"Output", "Table Name"}, []string{"Output"}, []string{"query", "Table Name", "Rows Fetched"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			span := newSpan()
+			lad, err := NewLargeAttributeDropper(&mockTraceDataProcessor{}, 100, tt.keys)
+			if err != nil {
+				t.Fatalf("NewLargeAttributeDropper() error = %v", err)
+			}
+			if err := lad.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{span, nil, {}}}); err != nil {
+				t.Fatalf("Wanted nil got error %v", err)
+			}
+			for _, key := range tt.wantDropped {
+				if spanAttribute(span, key) != nil {
+					t.Errorf("Got the large %q attribute kept", key)
+				}
+			}
+			for _, key := range tt.wantKept {
+				if spanAttribute(span, key) == nil {
+					t.Errorf("Got the %q attribute dropped", key)
+				}
+			}
+			if got := spanAttribute(span, "dropped_large_attributes").GetIntValue(); got != int64(len(tt.wantDropped)) {
+				t.Errorf("Got dropped_large_attributes %d, want %d", got, len(tt.wantDropped))
+			}
+			if got := span.Attributes.DroppedAttributesCount; got != int32(len(tt.wantDropped)) {
+				t.Errorf("Got DroppedAttributesCount %d, want %d", got, len(tt.wantDropped))
+			}
+		})
+	}
+}
+
+func TestLargeAttributeDropperNothingDropped(t *testing.T) {
+	span := newQuerySpan(1, "select 1")
+	lad, err := NewLargeAttributeDropper(&mockTraceDataProcessor{}, 100, nil)
+	if err != nil {
+		t.Fatalf("NewLargeAttributeDropper() error = %v", err)
+	}
+	if err := lad.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{span}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if spanAttribute(span, "dropped_large_attributes") != nil {
+		t.Error("Got dropped_large_attributes set on a span without large attributes")
+	}
+}
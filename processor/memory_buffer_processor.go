@@ -0,0 +1,149 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/observability"
+	"github.com/golang/protobuf/proto"
+)
+
+const memoryBufferProcessorName = "memory_buffer"
+
+// ErrInvalidMaxBufferBytes occurs when the memory budget of a memory buffer
+// is less than 1.
+var ErrInvalidMaxBufferBytes = errors.New("invalid maximum buffer size, it must be greater than zero")
+
+// bufferedTraceData is a TraceData waiting in a memory buffer, with the
+// context it was received with and its estimated size.
+type bufferedTraceData struct {
+	ctx  context.Context
+	td   data.TraceData
+	size int
+}
+
+type memoryBufferProcessor struct {
+	next           TraceDataProcessor
+	maxBufferBytes int
+
+	mu          sync.Mutex
+	buffer      []bufferedTraceData
+	bufferBytes int
+	// draining is true while a caller forwards the buffer to next.
+	draining bool
+}
+
+var _ TraceDataProcessor = (*memoryBufferProcessor)(nil)
+
+// NewMemoryBufferProcessor creates a TraceDataProcessor that buffers the
+// TraceData received while next is busy, so that a slow next does not block
+// the callers. The first caller forwards the buffer to next, in order, until
+// it is empty and gets the combined errors of next; the other callers return
+// right away. When the estimated size of the buffered TraceData exceeds
+// maxBufferBytes, the oldest ones are dropped, and counted in the dropped
+// spans metric, rather than growing the buffer. The current size of the
+// buffer is recorded in the buffered bytes metric, the TraceData being
+// forwarded is not counted.
+func NewMemoryBufferProcessor(next TraceDataProcessor, maxBufferBytes int) (TraceDataProcessor, error) {
+	if maxBufferBytes < 1 {
+		return nil, ErrInvalidMaxBufferBytes
+	}
+	return &memoryBufferProcessor{
+		next:           next,
+		maxBufferBytes: maxBufferBytes,
+	}, nil
+}
+
+func (mbp *memoryBufferProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	size := traceDataBytes(td)
+
+	mbp.mu.Lock()
+	mbp.buffer = append(mbp.buffer, bufferedTraceData{ctx: ctx, td: td, size: size})
+	mbp.bufferBytes += size
+	var dropped []bufferedTraceData
+	for mbp.bufferBytes > mbp.maxBufferBytes && len(mbp.buffer) > 0 {
+		dropped = append(dropped, mbp.pop())
+	}
+	bufferBytes := mbp.bufferBytes
+	drain := !mbp.draining
+	mbp.draining = true
+	mbp.mu.Unlock()
+
+	for _, btd := range dropped {
+		observability.RecordTraceProcessorMetrics(
+			observability.ContextWithProcessorName(btd.ctx, memoryBufferProcessorName), len(btd.td.Spans))
+	}
+	observability.RecordTraceProcessorBufferedBytes(
+		observability.ContextWithProcessorName(ctx, memoryBufferProcessorName), bufferBytes)
+	if !drain {
+		return nil
+	}
+	return mbp.drain()
+}
+
+// drain forwards the buffered TraceData to next until the buffer is empty.
+func (mbp *memoryBufferProcessor) drain() error {
+	var errs []error
+	for {
+		mbp.mu.Lock()
+		if len(mbp.buffer) == 0 {
+			mbp.draining = false
+			mbp.mu.Unlock()
+			return internal.CombineErrors(errs)
+		}
+		btd := mbp.pop()
+		bufferBytes := mbp.bufferBytes
+		mbp.mu.Unlock()
+
+		observability.RecordTraceProcessorBufferedBytes(
+			observability.ContextWithProcessorName(btd.ctx, memoryBufferProcessorName), bufferBytes)
+		if err := mbp.next.ProcessTraceData(btd.ctx, btd.td); err != nil {
+			errs = append(errs, err)
+		}
+	}
+}
+
+// pop removes the oldest TraceData from the buffer, mbp.mu must be held.
+func (mbp *memoryBufferProcessor) pop() bufferedTraceData {
+	btd := mbp.buffer[0]
+	// Release the TraceData for the garbage collector.
+	mbp.buffer[0] = bufferedTraceData{}
+	mbp.buffer = mbp.buffer[1:]
+	mbp.bufferBytes -= btd.size
+	return btd
+}
+
+// traceDataBytes estimates the memory used by td with the size of its
+// protobuf encoding.
+func traceDataBytes(td data.TraceData) int {
+	size := 0
+	if td.Node != nil {
+		size += proto.Size(td.Node)
+	}
+	if td.Resource != nil {
+		size += proto.Size(td.Resource)
+	}
+	for _, span := range td.Spans {
+		if span != nil {
+			size += proto.Size(span)
+		}
+	}
+	return size
+}
@@ -0,0 +1,95 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// stalledProcessor blocks in ProcessTraceData until release is closed, it
+// signals every call on started.
+type stalledProcessor struct {
+	started  chan struct{}
+	release  chan struct{}
+	traceIDs []byte
+}
+
+func (sp *stalledProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	sp.started <- struct{}{}
+	<-sp.release
+	sp.traceIDs = append(sp.traceIDs, td.Spans[0].TraceId[0])
+	return nil
+}
+
+func TestNewMemoryBufferProcessorInvalidMaxBufferBytes(t *testing.T) {
+	if _, err := NewMemoryBufferProcessor(&mockTraceDataProcessor{}, 0); err != ErrInvalidMaxBufferBytes {
+		t.Errorf("NewMemoryBufferProcessor() error = %v, want %v", err, ErrInvalidMaxBufferBytes)
+	}
+}
+
+func TestMemoryBufferProcessorDropsOldest(t *testing.T) {
+	newTraceData := func(traceID byte) data.TraceData {
+		return data.TraceData{Spans: []*tracepb.Span{newQuerySpan(traceID, "select * from orders")}}
+	}
+	size := traceDataBytes(newTraceData(1))
+	next := &stalledProcessor{started: make(chan struct{}, 4), release: make(chan struct{})}
+	// The buffer holds two TraceData.
+	mbp, err := NewMemoryBufferProcessor(next, 2*size)
+	if err != nil {
+		t.Fatalf("NewMemoryBufferProcessor() error = %v", err)
+	}
+	ctx := context.Background()
+
+	done := make(chan error)
+	go func() {
+		done <- mbp.ProcessTraceData(ctx, newTraceData(1))
+	}()
+	<-next.started
+
+	// next is stalled, the callers return right away and the oldest of the
+	// buffered TraceData is dropped.
+	for traceID := byte(2); traceID <= 4; traceID++ {
+		if err := mbp.ProcessTraceData(ctx, newTraceData(traceID)); err != nil {
+			t.Fatalf("ProcessTraceData() error = %v", err)
+		}
+	}
+
+	close(next.release)
+	if err := <-done; err != nil {
+		t.Fatalf("ProcessTraceData() error = %v", err)
+	}
+	if got, want := next.traceIDs, []byte{1, 3, 4}; string(got) != string(want) {
+		t.Errorf("Got the traces %v forwarded, want %v", got, want)
+	}
+}
+
+func TestMemoryBufferProcessorForwardsErrors(t *testing.T) {
+	next := &mockTraceDataProcessor{MustFail: true}
+	mbp, err := NewMemoryBufferProcessor(next, 1<<20)
+	if err != nil {
+		t.Fatalf("NewMemoryBufferProcessor() error = %v", err)
+	}
+	td := data.TraceData{Spans: []*tracepb.Span{newQuerySpan(1, "select 1")}}
+	if err := mbp.ProcessTraceData(context.Background(), td); err == nil {
+		t.Error("Got no error from a failing next processor")
+	}
+	if next.TotalSpans != 1 {
+		t.Errorf("Got %d spans forwarded, want 1", next.TotalSpans)
+	}
+}
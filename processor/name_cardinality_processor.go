@@ -0,0 +1,106 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"regexp"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const (
+	nameCardinalityReducerName = "name_cardinality_reducer"
+	// originalNameAttributeKey is the attribute holding the name of a span
+	// before it was rewritten.
+	originalNameAttributeKey = "original_name"
+)
+
+// NameRule rewrites the span names matching Pattern, replacing the matches
+// with Replacement, which can refer to the submatches as in
+// regexp.Regexp.ReplaceAllString.
+type NameRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// DefaultNameRules returns the rules used by NewNameCardinalityReducer when
+// none are given. They strip the index of the index scans, e.g.
+// "Index Scan using orders_customer_id_idx on orders" becomes
+// "Index Scan on orders", and normalize the numeric suffixes of partitions,
+// e.g. "Seq Scan on events_2019_01" becomes "Seq Scan on events_N".
+func DefaultNameRules() []NameRule {
+	return []NameRule{
+		{Pattern: regexp.MustCompile(`^((?:Bitmap )?Index(?: Only)? Scan)(?: Backward)? using \S+`), Replacement: "$1"},
+		{Pattern: regexp.MustCompile(`\b(\w+?)_p?\d+(?:_\d+)*\b`), Replacement: "${1}_N"},
+	}
+}
+
+type nameCardinalityReducer struct {
+	next  TraceDataProcessor
+	rules []NameRule
+}
+
+var _ TraceDataProcessor = (*nameCardinalityReducer)(nil)
+
+// NewNameCardinalityReducer creates a TraceDataProcessor that rewrites the
+// names of the spans with rules, in order, to reduce the number of distinct
+// span names sent to the backends. The original name of the rewritten spans
+// is kept in an "original_name" attribute. A nil rules uses DefaultNameRules.
+func NewNameCardinalityReducer(next TraceDataProcessor, rules []NameRule) TraceDataProcessor {
+	if rules == nil {
+		rules = DefaultNameRules()
+	}
+	return &nameCardinalityReducer{next: next, rules: rules}
+}
+
+func (ncr *nameCardinalityReducer) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	renamed := 0
+	for _, span := range td.Spans {
+		if ncr.rename(span) {
+			renamed++
+		}
+	}
+	if renamed > 0 {
+		processorCtx := observability.ContextWithProcessorName(ctx, nameCardinalityReducerName)
+		observability.RecordTraceProcessorModifiedSpans(processorCtx, renamed)
+	}
+	return ncr.next.ProcessTraceData(ctx, td)
+}
+
+// rename applies the rules to the name of span, and reports whether it
+// changed.
+func (ncr *nameCardinalityReducer) rename(span *tracepb.Span) bool {
+	original := span.GetName().GetValue()
+	if original == "" {
+		return false
+	}
+	name := original
+	for _, rule := range ncr.rules {
+		name = rule.Pattern.ReplaceAllString(name, rule.Replacement)
+	}
+	if name == original {
+		return false
+	}
+	// Keep the name of the first rewrite if the span goes through several
+	// reducers.
+	if spanAttribute(span, originalNameAttributeKey) == nil {
+		setSpanAttribute(span, originalNameAttributeKey, stringAttributeValue(original))
+	}
+	span.Name = &tracepb.TruncatableString{Value: name}
+	return true
+}
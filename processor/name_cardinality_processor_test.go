@@ -0,0 +1,89 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestNameCardinalityReducerDefaultRules(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Index Scan using orders_customer_id_idx on orders", "Index Scan on orders"},
+		{"Index Only Scan Backward using orders_pkey on orders", "Index Only Scan on orders"},
+		{"Bitmap Index Scan using orders_created_idx", "Bitmap Index Scan"},
+		{"Seq Scan on events_2019_01", "Seq Scan on events_N"},
+		{"Seq Scan on measurements_p42", "Seq Scan on measurements_N"},
+		{"Hash Join", "Hash Join"},
+	}
+	for _, tt := range tests {
+		span := &tracepb.Span{Name: &tracepb.TruncatableString{Value: tt.name}}
+		ncr := NewNameCardinalityReducer(&mockTraceDataProcessor{}, nil)
+		if err := ncr.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{span}}); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+		if got := span.GetName().GetValue(); got != tt.want {
+			t.Errorf("Got name %q for %q, want %q", got, tt.name, tt.want)
+		}
+		original := spanAttribute(span, "original_name")
+		if tt.want == tt.name {
+			if original != nil {
+				t.Errorf("Got original_name set on %q which was not renamed", tt.name)
+			}
+		} else if got := original.GetStringValue().GetValue(); got != tt.name {
+			t.Errorf("Got original_name %q, want %q", got, tt.name)
+		}
+	}
+}
+
+func TestNameCardinalityReducerCustomRules(t *testing.T) {
+	rules := []NameRule{
+		{Pattern: regexp.MustCompile(`^CTE Scan on \w+`), Replacement: "CTE Scan"},
+		{Pattern: regexp.MustCompile(`^CTE Scan$`), Replacement: "cte"},
+	}
+	first := &tracepb.Span{Name: &tracepb.TruncatableString{Value: "CTE Scan on recent_orders"}}
+	second := &tracepb.Span{Name: &tracepb.TruncatableString{Value: "Index Scan using orders_pkey"}}
+	td := data.TraceData{Spans: []*tracepb.Span{first, second, {}}}
+
+	ncr := NewNameCardinalityReducer(&mockTraceDataProcessor{}, rules)
+	if err := ncr.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	// The rules apply in order, each to the result of the previous ones.
+	if got := first.GetName().GetValue(); got != "cte" {
+		t.Errorf("Got name %q, want %q", got, "cte")
+	}
+	// The custom rules replace the default ones.
+	if got := second.GetName().GetValue(); got != "Index Scan using orders_pkey" {
+		t.Errorf("Got name %q, want it unchanged", got)
+	}
+
+	// Renaming again keeps the first original name.
+	rules = []NameRule{{Pattern: regexp.MustCompile(`cte`), Replacement: "CTE"}}
+	ncr = NewNameCardinalityReducer(&mockTraceDataProcessor{}, rules)
+	if err := ncr.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if got := spanAttribute(first, "original_name").GetStringValue().GetValue(); got != "CTE Scan on recent_orders" {
+		t.Errorf("Got original_name %q, want %q", got, "CTE Scan on recent_orders")
+	}
+}
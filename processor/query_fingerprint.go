@@ -0,0 +1,149 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"strings"
+	"unicode"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// queryAttributeKey is the attribute holding the SQL text of a query, as set by
+// the PostgreSQL receiver.
+const queryAttributeKey = "query"
+
+// FingerprintQuery returns the fingerprint of query, as set in the
+// query_fingerprint attribute by NewQueryFingerprintProcessor.
+func FingerprintQuery(query string) string {
+	return fingerprintQuery(query)
+}
+
+// fingerprintQuery normalizes the SQL text of query so that queries that only
+// differ by their literals, comments, casing or whitespace share the same
+// fingerprint. For example:
+//
+//   SELECT * FROM t WHERE id IN (1, 2, 3) AND name = 'x'
+//
+// becomes:
+//
+//   select * from t where id in (?) and name = ?
+func fingerprintQuery(query string) string {
+	var sb strings.Builder
+	runes := []rune(query)
+	pendingSpace := false
+	var last rune
+	writeRune := func(r rune) {
+		// Whitespace is collapsed into a single space, and dropped entirely
+		// around list punctuation.
+		if pendingSpace && sb.Len() > 0 && last != '(' && last != ',' && r != ')' && r != ',' {
+			sb.WriteByte(' ')
+		}
+		pendingSpace = false
+		last = r
+		sb.WriteRune(r)
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			pendingSpace = true
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			// Line comment.
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			pendingSpace = true
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			// Block comment.
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i++
+			pendingSpace = true
+		case r == '\'':
+			// String literal, '' is an escaped quote.
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			writeRune('?')
+		case r == '"':
+			// Quoted identifiers are kept as is.
+			writeRune(r)
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				sb.WriteRune(runes[i])
+			}
+			if i < len(runes) {
+				writeRune('"')
+			}
+		case r == '$' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			// Bind parameter.
+			for i+1 < len(runes) && unicode.IsDigit(runes[i+1]) {
+				i++
+			}
+			writeRune('?')
+		case unicode.IsDigit(r) && !precededByIdentifier(runes, i):
+			// Numeric literal.
+			for i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.' || runes[i+1] == 'e' || runes[i+1] == 'E') {
+				i++
+			}
+			writeRune('?')
+		default:
+			writeRune(unicode.ToLower(r))
+		}
+	}
+
+	return collapseValueLists(sb.String())
+}
+
+func precededByIdentifier(runes []rune, i int) bool {
+	if i == 0 {
+		return false
+	}
+	prev := runes[i-1]
+	return unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == '_'
+}
+
+// collapseValueLists replaces lists of placeholders such as "(?,?,?)" by "(?)"
+// so that IN lists of different lengths share the same fingerprint.
+func collapseValueLists(query string) string {
+	for strings.Contains(query, "?,?") {
+		query = strings.Replace(query, "?,?", "?", -1)
+	}
+	return query
+}
+
+// queryOfSpans returns the value of the first query attribute found in spans.
+func queryOfSpans(spans []*tracepb.Span) (string, bool) {
+	for _, span := range spans {
+		if span == nil || span.Attributes == nil {
+			continue
+		}
+		if attr := span.Attributes.AttributeMap[queryAttributeKey]; attr != nil {
+			if sv, ok := attr.Value.(*tracepb.AttributeValue_StringValue); ok && sv.StringValue != nil {
+				return sv.StringValue.Value, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,91 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+const (
+	queryFingerprintAttributeKey     = "query_fingerprint"
+	queryFingerprintHashAttributeKey = "query_fingerprint_hash"
+)
+
+type queryFingerprintProcessor struct {
+	next           TraceDataProcessor
+	hash           bool
+	redactOriginal bool
+}
+
+var _ TraceDataProcessor = (*queryFingerprintProcessor)(nil)
+
+// QueryFingerprintOption is an option to NewQueryFingerprintProcessor.
+type QueryFingerprintOption func(*queryFingerprintProcessor)
+
+// WithQueryFingerprintHash also sets a "query_fingerprint_hash" attribute
+// holding a 64-bit FNV-1a hash of the fingerprint, in hexadecimal, which is
+// cheaper to group on than the fingerprint itself.
+func WithQueryFingerprintHash() QueryFingerprintOption {
+	return func(qfp *queryFingerprintProcessor) {
+		qfp.hash = true
+	}
+}
+
+// WithRedactOriginalQuery replaces the "query" attribute by its fingerprint,
+// so that the literals of the queries, which may be sensitive, are not
+// exported.
+func WithRedactOriginalQuery() QueryFingerprintOption {
+	return func(qfp *queryFingerprintProcessor) {
+		qfp.redactOriginal = true
+	}
+}
+
+// NewQueryFingerprintProcessor creates a TraceDataProcessor that sets a
+// "query_fingerprint" attribute on the spans carrying a "query" attribute.
+// The fingerprint is the query text with its literals replaced by
+// placeholders and its comments, casing and whitespace normalized, so that
+// the executions of the same query shape can be aggregated downstream.
+func NewQueryFingerprintProcessor(next TraceDataProcessor, opts ...QueryFingerprintOption) TraceDataProcessor {
+	qfp := &queryFingerprintProcessor{next: next}
+	for _, opt := range opts {
+		opt(qfp)
+	}
+	return qfp
+}
+
+func (qfp *queryFingerprintProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	for _, span := range td.Spans {
+		query, ok := queryOfSpans([]*tracepb.Span{span})
+		if !ok {
+			continue
+		}
+		fingerprint := fingerprintQuery(query)
+		setSpanAttribute(span, queryFingerprintAttributeKey, stringAttributeValue(fingerprint))
+		if qfp.hash {
+			h := fnv.New64a()
+			h.Write([]byte(fingerprint))
+			setSpanAttribute(span, queryFingerprintHashAttributeKey, stringAttributeValue(strconv.FormatUint(h.Sum64(), 16)))
+		}
+		if qfp.redactOriginal {
+			setSpanAttribute(span, queryAttributeKey, stringAttributeValue(fingerprint))
+		}
+	}
+	return qfp.next.ProcessTraceData(ctx, td)
+}
@@ -0,0 +1,94 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestQueryFingerprintProcessor(t *testing.T) {
+	const (
+		query       = "SELECT * FROM t WHERE id = 42"
+		fingerprint = "select * from t where id = ?"
+	)
+
+	tests := []struct {
+		name      string
+		opts      []QueryFingerprintOption
+		wantQuery string
+		wantHash  bool
+	}{
+		{
+			name:      "default",
+			wantQuery: query,
+		},
+		{
+			name:      "hash",
+			opts:      []QueryFingerprintOption{WithQueryFingerprintHash()},
+			wantQuery: query,
+			wantHash:  true,
+		},
+		{
+			name:      "redact original",
+			opts:      []QueryFingerprintOption{WithRedactOriginalQuery()},
+			wantQuery: fingerprint,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &mockTraceDataProcessor{}
+			qfp := NewQueryFingerprintProcessor(next, tt.opts...)
+			querySpan, otherSpan := newQuerySpan(1, query), &tracepb.Span{}
+			td := data.TraceData{Spans: []*tracepb.Span{querySpan, otherSpan, nil}}
+			if err := qfp.ProcessTraceData(context.Background(), td); err != nil {
+				t.Fatalf("Wanted nil got error %v", err)
+			}
+
+			if got := spanAttribute(querySpan, queryFingerprintAttributeKey).GetStringValue().GetValue(); got != fingerprint {
+				t.Errorf("Got fingerprint %q, want %q", got, fingerprint)
+			}
+			if got := spanAttribute(querySpan, queryAttributeKey).GetStringValue().GetValue(); got != tt.wantQuery {
+				t.Errorf("Got query %q, want %q", got, tt.wantQuery)
+			}
+			if got := spanAttribute(querySpan, queryFingerprintHashAttributeKey) != nil; got != tt.wantHash {
+				t.Errorf("Got hash attribute %v, want %v", got, tt.wantHash)
+			}
+			if otherSpan.Attributes != nil {
+				t.Errorf("Got attributes %v on a span without query", otherSpan.Attributes)
+			}
+			if next.TotalSpans != 3 {
+				t.Errorf("Got %d spans forwarded, want 3", next.TotalSpans)
+			}
+		})
+	}
+}
+
+func TestQueryFingerprintHashIsStable(t *testing.T) {
+	qfp := NewQueryFingerprintProcessor(&mockTraceDataProcessor{}, WithQueryFingerprintHash())
+	first, second := newQuerySpan(1, "select 1"), newQuerySpan(2, "SELECT  2")
+	if err := qfp.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{first, second}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	firstHash := spanAttribute(first, queryFingerprintHashAttributeKey).GetStringValue().GetValue()
+	secondHash := spanAttribute(second, queryFingerprintHashAttributeKey).GetStringValue().GetValue()
+	if firstHash == "" || firstHash != secondHash {
+		t.Errorf("Got hashes %q and %q, want the same non-empty hash", firstHash, secondHash)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import "testing"
+
+func TestFingerprintQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "SELECT * FROM t WHERE id IN (1, 2, 3) AND name = 'x'",
+			want:  "select * from t where id in (?) and name = ?",
+		},
+		{
+			query: "select *\n  from t\twhere id in (4,5)   and name = 'it''s'",
+			want:  "select * from t where id in (?) and name = ?",
+		},
+		{
+			query: `SELECT "Col1" FROM t2 /* comment */ WHERE v > 1.5e3 -- trailing
+			LIMIT $1`,
+			want: `select "Col1" from t2 where v > ? limit ?`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := fingerprintQuery(tt.query); got != tt.want {
+			t.Errorf("fingerprintQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const rateLimiterProcessorName = "rate_limiter"
+
+// ErrInvalidSpansPerSecond occurs when the span rate of a rate limiter is
+// less than 1.
+var ErrInvalidSpansPerSecond = errors.New("invalid number of spans per second, it must be greater than zero")
+
+type rateLimiterProcessor struct {
+	next           TraceDataProcessor
+	spansPerSecond float64
+	delay          bool
+	now            func() time.Time
+	sleep          func(time.Duration)
+
+	mu sync.Mutex
+	// tokens is the number of spans that can be forwarded right away. It is
+	// negative when the spans already admitted exceed the rate.
+	tokens   float64
+	refilled time.Time
+}
+
+var _ TraceDataProcessor = (*rateLimiterProcessor)(nil)
+
+// RateLimiterOption is an option to NewRateLimiterProcessor.
+type RateLimiterOption func(*rateLimiterProcessor)
+
+// WithRateLimiterDelay makes the rate limiter delay the TraceData exceeding
+// the rate until it allows them, instead of dropping them.
+func WithRateLimiterDelay() RateLimiterOption {
+	return func(rlp *rateLimiterProcessor) {
+		rlp.delay = true
+	}
+}
+
+// NewRateLimiterProcessor creates a TraceDataProcessor that forwards at most
+// spansPerSecond spans per second, on average, to next, protecting the quotas
+// of shared backends. The rate is enforced with a token bucket holding up to
+// one second of spans, so short bursts are allowed. A TraceData is never split:
+// it is admitted as long as the bucket is not empty, and the spans exceeding
+// the bucket are paid back by the following ones. The TraceData that are not
+// admitted are dropped, unless WithRateLimiterDelay is used.
+func NewRateLimiterProcessor(next TraceDataProcessor, spansPerSecond int, opts ...RateLimiterOption) (TraceDataProcessor, error) {
+	if spansPerSecond < 1 {
+		return nil, ErrInvalidSpansPerSecond
+	}
+	rlp := &rateLimiterProcessor{
+		next:           next,
+		spansPerSecond: float64(spansPerSecond),
+		now:            time.Now,
+		sleep:          time.Sleep,
+		tokens:         float64(spansPerSecond),
+	}
+	for _, opt := range opts {
+		opt(rlp)
+	}
+	return rlp, nil
+}
+
+func (rlp *rateLimiterProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	if len(td.Spans) == 0 {
+		return rlp.next.ProcessTraceData(ctx, td)
+	}
+
+	wait, admitted := rlp.reserve(len(td.Spans))
+	if !admitted {
+		observability.RecordTraceProcessorMetrics(
+			observability.ContextWithProcessorName(ctx, rateLimiterProcessorName), len(td.Spans))
+		return nil
+	}
+	if wait > 0 {
+		observability.RecordTraceProcessorDelayedSpans(
+			observability.ContextWithProcessorName(ctx, rateLimiterProcessorName), len(td.Spans))
+		rlp.sleep(wait)
+	}
+	return rlp.next.ProcessTraceData(ctx, td)
+}
+
+// reserve takes spans tokens from the bucket. It returns how long to wait
+// before forwarding the spans, or false if they must be dropped.
+func (rlp *rateLimiterProcessor) reserve(spans int) (time.Duration, bool) {
+	rlp.mu.Lock()
+	defer rlp.mu.Unlock()
+
+	now := rlp.now()
+	if !rlp.refilled.IsZero() {
+		rlp.tokens += now.Sub(rlp.refilled).Seconds() * rlp.spansPerSecond
+		if rlp.tokens > rlp.spansPerSecond {
+			rlp.tokens = rlp.spansPerSecond
+		}
+	}
+	rlp.refilled = now
+
+	if rlp.delay {
+		// Wait until the bucket is no longer in debt.
+		var wait time.Duration
+		if rlp.tokens <= 0 {
+			wait = time.Duration(-rlp.tokens / rlp.spansPerSecond * float64(time.Second))
+		}
+		rlp.tokens -= float64(spans)
+		return wait, true
+	}
+	if rlp.tokens <= 0 {
+		return 0, false
+	}
+	rlp.tokens -= float64(spans)
+	return 0, true
+}
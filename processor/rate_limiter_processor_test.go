@@ -0,0 +1,93 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func newRateLimiterForTest(t *testing.T, next TraceDataProcessor, spansPerSecond int, opts ...RateLimiterOption) (*rateLimiterProcessor, *time.Time, *[]time.Duration) {
+	tdp, err := NewRateLimiterProcessor(next, spansPerSecond, opts...)
+	if err != nil {
+		t.Fatalf("NewRateLimiterProcessor() error = %v", err)
+	}
+	rlp := tdp.(*rateLimiterProcessor)
+	now := time.Unix(1000, 0)
+	var sleeps []time.Duration
+	rlp.now = func() time.Time { return now }
+	rlp.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+	}
+	return rlp, &now, &sleeps
+}
+
+func TestRateLimiterProcessorDrop(t *testing.T) {
+	next := &mockTraceDataProcessor{}
+	rlp, now, _ := newRateLimiterForTest(t, next, 10)
+	td := data.TraceData{Spans: make([]*tracepb.Span, 6)}
+
+	// The first TraceData fits, the second one overdraws the bucket and the
+	// third one is dropped.
+	for i := 0; i < 3; i++ {
+		if err := rlp.ProcessTraceData(context.Background(), td); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+	if next.TotalSpans != 12 {
+		t.Errorf("Got %d spans forwarded, want 12", next.TotalSpans)
+	}
+
+	// The debt of 2 spans is paid back after 200ms, the bucket then refills.
+	*now = now.Add(time.Second)
+	if err := rlp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if next.TotalSpans != 18 {
+		t.Errorf("Got %d spans forwarded, want 18", next.TotalSpans)
+	}
+}
+
+func TestRateLimiterProcessorDelay(t *testing.T) {
+	next := &mockTraceDataProcessor{}
+	rlp, _, sleeps := newRateLimiterForTest(t, next, 10, WithRateLimiterDelay())
+	td := data.TraceData{Spans: make([]*tracepb.Span, 10)}
+
+	for i := 0; i < 3; i++ {
+		if err := rlp.ProcessTraceData(context.Background(), td); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+	if next.TotalSpans != 30 {
+		t.Errorf("Got %d spans forwarded, want 30", next.TotalSpans)
+	}
+	// The first TraceData empties the bucket, the second one overdraws it and
+	// the third one waits for the debt to be paid back.
+	want := []time.Duration{time.Second}
+	if len(*sleeps) != len(want) || (*sleeps)[0] != want[0] {
+		t.Errorf("Got sleeps %v, want %v", *sleeps, want)
+	}
+}
+
+func TestNewRateLimiterProcessorInvalid(t *testing.T) {
+	if _, err := NewRateLimiterProcessor(&mockTraceDataProcessor{}, 0); err != ErrInvalidSpansPerSecond {
+		t.Errorf("Got error %v, want %v", err, ErrInvalidSpansPerSecond)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// maxRecordSize bounds the size of the lines read by ReplayFile.
+const maxRecordSize = 64 << 20
+
+type recordingProcessor struct {
+	next     TraceDataProcessor
+	path     string
+	maxBytes int64
+	sync     bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+var _ TraceDataProcessor = (*recordingProcessor)(nil)
+
+// RecordingOption is an option to NewRecordingProcessor.
+type RecordingOption func(*recordingProcessor)
+
+// WithRecordingMaxBytes makes the recording processor rotate the file once it
+// reaches maxBytes: the file is renamed with a .1 suffix, replacing the
+// previous one, and a new file is started. The file grows unbounded by default.
+func WithRecordingMaxBytes(maxBytes int64) RecordingOption {
+	return func(rp *recordingProcessor) {
+		rp.maxBytes = maxBytes
+	}
+}
+
+// WithRecordingSync makes the recording processor sync the file to the disk
+// after every record, so that the records survive a crash of the host, at the
+// cost of a slower recording.
+func WithRecordingSync() RecordingOption {
+	return func(rp *recordingProcessor) {
+		rp.sync = true
+	}
+}
+
+// recordedTraceData is a line of a recording, the fields are the protobuf
+// JSON encoding of the TraceData fields.
+type recordedTraceData struct {
+	Node     json.RawMessage   `json:"node,omitempty"`
+	Resource json.RawMessage   `json:"resource,omitempty"`
+	Spans    []json.RawMessage `json:"spans"`
+}
+
+// NewRecordingProcessor creates a TraceDataProcessor that appends every
+// TraceData to the file at path, as a line of JSON, before forwarding it to
+// next, so that it can be replayed later with ReplayFile. The failure to
+// record a TraceData is logged and does not prevent forwarding it. Every
+// record is written at once, a record torn by a failed write is skipped by
+// ReplayFile.
+func NewRecordingProcessor(next TraceDataProcessor, path string, opts ...RecordingOption) (TraceDataProcessor, error) {
+	rp := &recordingProcessor{next: next, path: path}
+	for _, opt := range opts {
+		opt(rp)
+	}
+	if err := rp.open(); err != nil {
+		return nil, err
+	}
+	return rp, nil
+}
+
+func (rp *recordingProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	if err := rp.record(td); err != nil {
+		log.Printf("Failed to record TraceData to %q: %v", rp.path, err)
+	}
+	return rp.next.ProcessTraceData(ctx, td)
+}
+
+func (rp *recordingProcessor) open() error {
+	file, err := os.OpenFile(rp.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rp.file, rp.size = file, info.Size()
+	return nil
+}
+
+func (rp *recordingProcessor) record(td data.TraceData) error {
+	line, err := marshalTraceData(td)
+	if err != nil {
+		return err
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.maxBytes > 0 && rp.size > 0 && rp.size+int64(len(line)) > rp.maxBytes {
+		if err := rp.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := rp.file.Write(line)
+	rp.size += int64(n)
+	if err != nil && n > 0 {
+		// End the torn record so that the next one starts on its own line.
+		if n, err := rp.file.Write([]byte("\n")); err == nil {
+			rp.size += int64(n)
+		}
+	}
+	if err == nil && rp.sync {
+		err = rp.file.Sync()
+	}
+	return err
+}
+
+func (rp *recordingProcessor) rotate() error {
+	if err := rp.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(rp.path, rp.path+".1"); err != nil {
+		return err
+	}
+	return rp.open()
+}
+
+func marshalTraceData(td data.TraceData) ([]byte, error) {
+	var marshaler jsonpb.Marshaler
+	var record recordedTraceData
+	var err error
+	if td.Node != nil {
+		if record.Node, err = marshalToJSON(&marshaler, td.Node); err != nil {
+			return nil, err
+		}
+	}
+	if td.Resource != nil {
+		if record.Resource, err = marshalToJSON(&marshaler, td.Resource); err != nil {
+			return nil, err
+		}
+	}
+	record.Spans = make([]json.RawMessage, 0, len(td.Spans))
+	for _, span := range td.Spans {
+		if span == nil {
+			continue
+		}
+		raw, err := marshalToJSON(&marshaler, span)
+		if err != nil {
+			return nil, err
+		}
+		record.Spans = append(record.Spans, raw)
+	}
+	line, err := json.Marshal(&record)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+func marshalToJSON(marshaler *jsonpb.Marshaler, msg proto.Message) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	if err := marshaler.Marshal(&buf, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalTraceData(line []byte) (data.TraceData, error) {
+	var record recordedTraceData
+	if err := json.Unmarshal(line, &record); err != nil {
+		return data.TraceData{}, err
+	}
+	var td data.TraceData
+	if len(record.Node) > 0 {
+		td.Node = &commonpb.Node{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(record.Node), td.Node); err != nil {
+			return data.TraceData{}, err
+		}
+	}
+	if len(record.Resource) > 0 {
+		td.Resource = &resourcepb.Resource{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(record.Resource), td.Resource); err != nil {
+			return data.TraceData{}, err
+		}
+	}
+	for _, raw := range record.Spans {
+		span := &tracepb.Span{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(raw), span); err != nil {
+			return data.TraceData{}, err
+		}
+		td.Spans = append(td.Spans, span)
+	}
+	return td, nil
+}
+
+// ReplayFile sends the TraceData recorded by a recording processor in the
+// file at path to next, in order. The records that cannot be decoded, such as
+// the ones torn by a failed write, are skipped and logged. All the records are
+// sent even if next fails, and its errors are combined.
+func ReplayFile(path string, next TraceDataProcessor) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxRecordSize)
+	var errs []error
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		td, err := unmarshalTraceData(line)
+		if err != nil {
+			log.Printf("Skipping invalid record at %s:%d: %v", path, lineNumber, err)
+			continue
+		}
+		if err := next.ProcessTraceData(context.Background(), td); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read %q: %v", path, err))
+	}
+	return internal.CombineErrors(errs)
+}
@@ -0,0 +1,127 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/golang/protobuf/proto"
+)
+
+func newRecordingDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestRecordingProcessorReplay(t *testing.T) {
+	dir, cleanup := newRecordingDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "traces.jsonl")
+
+	tds := []data.TraceData{
+		{
+			Node:  &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "orders"}},
+			Spans: []*tracepb.Span{newQuerySpan(1, "select 1"), newQuerySpan(2, "select 2")},
+		},
+		{Spans: []*tracepb.Span{newQuerySpan(3, "select 3")}},
+	}
+	next := &mockTraceDataProcessor{}
+	rp, err := NewRecordingProcessor(next, path)
+	if err != nil {
+		t.Fatalf("NewRecordingProcessor() error = %v", err)
+	}
+	for _, td := range tds {
+		if err := rp.ProcessTraceData(context.Background(), td); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+	if next.TotalSpans != 3 {
+		t.Errorf("Got %d spans forwarded, want 3", next.TotalSpans)
+	}
+
+	// A torn record is skipped.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open recording: %v", err)
+	}
+	f.WriteString(`{"spans": [{"traceId":`)
+	f.Close()
+
+	replayed := &batchRecorder{}
+	if err := ReplayFile(path, replayed); err != nil {
+		t.Fatalf("ReplayFile() error = %v", err)
+	}
+	if len(replayed.batches) != len(tds) {
+		t.Fatalf("Got %d TraceData replayed, want %d", len(replayed.batches), len(tds))
+	}
+	for i, td := range tds {
+		got := replayed.batches[i]
+		if (got.Node == nil) != (td.Node == nil) || td.Node != nil && !proto.Equal(got.Node, td.Node) {
+			t.Errorf("Got node %v, want %v", got.Node, td.Node)
+		}
+		if len(got.Spans) != len(td.Spans) {
+			t.Fatalf("Got %d spans, want %d", len(got.Spans), len(td.Spans))
+		}
+		for j := range td.Spans {
+			if !proto.Equal(got.Spans[j], td.Spans[j]) {
+				t.Errorf("Got span %v, want %v", got.Spans[j], td.Spans[j])
+			}
+		}
+	}
+}
+
+func TestRecordingProcessorRotation(t *testing.T) {
+	dir, cleanup := newRecordingDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "traces.jsonl")
+
+	// Every record exceeds the maximum size, each one starts a new file.
+	rp, err := NewRecordingProcessor(&mockTraceDataProcessor{}, path, WithRecordingMaxBytes(10))
+	if err != nil {
+		t.Fatalf("NewRecordingProcessor() error = %v", err)
+	}
+	for _, query := range []string{"select 1", "select 2", "select 3"} {
+		td := data.TraceData{Spans: []*tracepb.Span{newQuerySpan(1, query)}}
+		if err := rp.ProcessTraceData(context.Background(), td); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+
+	for file, want := range map[string]string{path + ".1": "select 2", path: "select 3"} {
+		replayed := &batchRecorder{}
+		if err := ReplayFile(file, replayed); err != nil {
+			t.Fatalf("ReplayFile(%q) error = %v", file, err)
+		}
+		var got []string
+		for _, td := range replayed.batches {
+			query, _ := queryOfSpans(td.Spans)
+			got = append(got, query)
+		}
+		if !reflect.DeepEqual(got, []string{want}) {
+			t.Errorf("Got queries %q in %q, want %q", got, file, want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const (
+	requiredAttributeProcessorName = "required_attribute"
+
+	missingAttributesAttributeKey = "missing_required_attributes"
+	defaultRequiredAttributeValue = "unknown"
+)
+
+// MissingAttributePolicy defines what the required attribute processor does
+// with the spans missing a required attribute.
+type MissingAttributePolicy string
+
+const (
+	// MissingAttributeInject sets the missing attributes to their default.
+	MissingAttributeInject MissingAttributePolicy = "inject"
+	// MissingAttributeDrop drops the spans missing an attribute.
+	MissingAttributeDrop MissingAttributePolicy = "drop"
+	// MissingAttributeFlag adds a missing_required_attributes attribute
+	// listing the missing attributes.
+	MissingAttributeFlag MissingAttributePolicy = "flag"
+)
+
+// ErrInvalidMissingAttributePolicy occurs when the policy of a required
+// attribute processor is unknown.
+var ErrInvalidMissingAttributePolicy = errors.New("invalid missing attribute policy, it must be inject, drop or flag")
+
+type requiredAttributeProcessor struct {
+	next      TraceDataProcessor
+	keys      []string
+	onMissing MissingAttributePolicy
+	defaults  map[string]string
+}
+
+var _ TraceDataProcessor = (*requiredAttributeProcessor)(nil)
+
+// RequiredAttributeOption is an option to NewRequiredAttributeProcessor.
+type RequiredAttributeOption func(*requiredAttributeProcessor)
+
+// WithRequiredAttributeDefaults sets the values injected for the missing
+// attributes with the MissingAttributeInject policy. The attributes without a
+// default are set to "unknown".
+func WithRequiredAttributeDefaults(defaults map[string]string) RequiredAttributeOption {
+	return func(rap *requiredAttributeProcessor) {
+		rap.defaults = defaults
+	}
+}
+
+// NewRequiredAttributeProcessor creates a TraceDataProcessor that makes sure
+// every span has the attributes keys, for the backends that reject incomplete
+// spans. The spans missing some of them are handled according to onMissing,
+// and counted in the processor dropped spans metric when they are dropped or
+// in the processor modified spans metric otherwise.
+func NewRequiredAttributeProcessor(next TraceDataProcessor, keys []string, onMissing MissingAttributePolicy, opts ...RequiredAttributeOption) (TraceDataProcessor, error) {
+	switch onMissing {
+	case MissingAttributeInject, MissingAttributeDrop, MissingAttributeFlag:
+	default:
+		return nil, ErrInvalidMissingAttributePolicy
+	}
+	rap := &requiredAttributeProcessor{
+		next:      next,
+		keys:      keys,
+		onMissing: onMissing,
+	}
+	for _, opt := range opts {
+		opt(rap)
+	}
+	return rap, nil
+}
+
+func (rap *requiredAttributeProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	kept := make([]*tracepb.Span, 0, len(td.Spans))
+	incomplete := 0
+	for _, span := range td.Spans {
+		missing := rap.missingKeys(span)
+		if len(missing) == 0 {
+			kept = append(kept, span)
+			continue
+		}
+		incomplete++
+		switch rap.onMissing {
+		case MissingAttributeInject:
+			for _, key := range missing {
+				value, ok := rap.defaults[key]
+				if !ok {
+					value = defaultRequiredAttributeValue
+				}
+				setSpanAttribute(span, key, stringAttributeValue(value))
+			}
+		case MissingAttributeFlag:
+			setSpanAttribute(span, missingAttributesAttributeKey, stringAttributeValue(strings.Join(missing, ",")))
+		case MissingAttributeDrop:
+			continue
+		}
+		kept = append(kept, span)
+	}
+
+	if incomplete > 0 {
+		processorCtx := observability.ContextWithProcessorName(ctx, requiredAttributeProcessorName)
+		if rap.onMissing == MissingAttributeDrop {
+			observability.RecordTraceProcessorMetrics(processorCtx, incomplete)
+		} else {
+			observability.RecordTraceProcessorModifiedSpans(processorCtx, incomplete)
+		}
+	}
+	td.Spans = kept
+	return rap.next.ProcessTraceData(ctx, td)
+}
+
+// missingKeys returns the required attributes that span lacks.
+func (rap *requiredAttributeProcessor) missingKeys(span *tracepb.Span) []string {
+	if span == nil {
+		return nil
+	}
+	var missing []string
+	for _, key := range rap.keys {
+		if spanAttribute(span, key) == nil {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,92 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestNewRequiredAttributeProcessorInvalidPolicy(t *testing.T) {
+	if _, err := NewRequiredAttributeProcessor(&mockTraceDataProcessor{}, []string{"query"}, "ignore"); err != ErrInvalidMissingAttributePolicy {
+		t.Errorf("NewRequiredAttributeProcessor() error = %v, want %v", err, ErrInvalidMissingAttributePolicy)
+	}
+}
+
+func TestRequiredAttributeProcessor(t *testing.T) {
+	keys := []string{"query", "service.name", "database_name"}
+	newSpans := func() []*tracepb.Span {
+		complete := newQuerySpan(1, "select 1")
+		setSpanAttribute(complete, "service.name", stringAttributeValue("orders"))
+		setSpanAttribute(complete, "database_name", stringAttributeValue("orders"))
+		// Lacks service.name and database_name.
+		incomplete := newQuerySpan(2, "select 2")
+		return []*tracepb.Span{complete, incomplete, nil}
+	}
+
+	t.Run("inject", func(t *testing.T) {
+		next := &batchRecorder{}
+		rap, err := NewRequiredAttributeProcessor(next, keys, MissingAttributeInject,
+			WithRequiredAttributeDefaults(map[string]string{"service.name": "postgres"}))
+		if err != nil {
+			t.Fatalf("NewRequiredAttributeProcessor() error = %v", err)
+		}
+		if err := rap.ProcessTraceData(context.Background(), data.TraceData{Spans: newSpans()}); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+		spans := next.batches[0].Spans
+		if len(spans) != 3 {
+			t.Fatalf("Got %d spans, want 3", len(spans))
+		}
+		for key, want := range map[string]string{"service.name": "postgres", "database_name": "unknown"} {
+			if got := spanAttribute(spans[1], key).GetStringValue().GetValue(); got != want {
+				t.Errorf("Got %s %q, want %q", key, got, want)
+			}
+		}
+		if got := spanAttribute(spans[0], "service.name").GetStringValue().GetValue(); got != "orders" {
+			t.Errorf("Got service.name %q on the complete span, want it unchanged", got)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		next := &batchRecorder{}
+		rap, _ := NewRequiredAttributeProcessor(next, keys, MissingAttributeDrop)
+		if err := rap.ProcessTraceData(context.Background(), data.TraceData{Spans: newSpans()}); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+		spans := next.batches[0].Spans
+		if len(spans) != 2 || spans[0].TraceId[0] != 1 || spans[1] != nil {
+			t.Errorf("Got spans %v, want the complete span only", spans)
+		}
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		next := &batchRecorder{}
+		rap, _ := NewRequiredAttributeProcessor(next, keys, MissingAttributeFlag)
+		if err := rap.ProcessTraceData(context.Background(), data.TraceData{Spans: newSpans()}); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+		spans := next.batches[0].Spans
+		if got := spanAttribute(spans[1], missingAttributesAttributeKey).GetStringValue().GetValue(); got != "service.name,database_name" {
+			t.Errorf("Got %s %q, want %q", missingAttributesAttributeKey, got, "service.name,database_name")
+		}
+		if spanAttribute(spans[0], missingAttributesAttributeKey) != nil {
+			t.Error("Got the complete span flagged")
+		}
+	})
+}
@@ -0,0 +1,176 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+type resourcePromotionProcessor struct {
+	next TraceDataProcessor
+	keys []string
+}
+
+var _ TraceDataProcessor = (*resourcePromotionProcessor)(nil)
+
+// NewResourcePromotionProcessor creates a TraceDataProcessor that moves the
+// span attributes named by keys, e.g. "database_name" or "username", to the
+// labels of the Resource of the TraceData and removes them from the spans.
+//
+// An attribute is promoted for a trace when all the spans of the trace that
+// have it agree on its value, and the Resource does not already have a
+// different value for it. The traces are then regrouped by their promoted
+// values, so a TraceData holding the queries of several users is forwarded
+// as one TraceData per user. All the groups are forwarded even if some fail,
+// and the errors are combined.
+func NewResourcePromotionProcessor(next TraceDataProcessor, keys []string) TraceDataProcessor {
+	return &resourcePromotionProcessor{next: next, keys: keys}
+}
+
+func (rpp *resourcePromotionProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	if len(rpp.keys) == 0 || len(td.Spans) == 0 {
+		return rpp.next.ProcessTraceData(ctx, td)
+	}
+
+	type group struct {
+		labels map[string]string
+		spans  []*tracepb.Span
+	}
+	var groups []*group
+	groupsBySignature := make(map[string]*group)
+	traces, order := spansByTraceID(td.Spans)
+	for _, traceID := range order {
+		spans := traces[traceID]
+		labels := rpp.promotedLabels(spans, td.Resource)
+		for _, span := range spans {
+			if span == nil || span.Attributes == nil {
+				continue
+			}
+			for key := range labels {
+				delete(span.Attributes.AttributeMap, key)
+			}
+		}
+
+		signature := labelsSignature(labels)
+		g, ok := groupsBySignature[signature]
+		if !ok {
+			g = &group{labels: labels}
+			groupsBySignature[signature] = g
+			groups = append(groups, g)
+		}
+		g.spans = append(g.spans, spans...)
+	}
+
+	var errs []error
+	for _, g := range groups {
+		promoted := data.TraceData{
+			Node:     td.Node,
+			Resource: promoteLabels(td.Resource, g.labels),
+			Spans:    g.spans,
+		}
+		if err := rpp.next.ProcessTraceData(ctx, promoted); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// promotedLabels returns the attributes of the spans of a trace that can be
+// promoted to labels of resource, as strings.
+func (rpp *resourcePromotionProcessor) promotedLabels(spans []*tracepb.Span, resource *resourcepb.Resource) map[string]string {
+	labels := make(map[string]string)
+	for _, key := range rpp.keys {
+		var value string
+		found, conflict := false, false
+		for _, span := range spans {
+			attr := spanAttribute(span, key)
+			if attr == nil {
+				continue
+			}
+			v, ok := attributeValueString(attr)
+			if !ok || (found && v != value) {
+				conflict = true
+				break
+			}
+			value, found = v, true
+		}
+		if !found || conflict {
+			continue
+		}
+		if existing, ok := resource.GetLabels()[key]; ok && existing != value {
+			continue
+		}
+		labels[key] = value
+	}
+	return labels
+}
+
+// promoteLabels returns a copy of resource with labels added, or resource
+// itself when there are no labels to add.
+func promoteLabels(resource *resourcepb.Resource, labels map[string]string) *resourcepb.Resource {
+	if len(labels) == 0 {
+		return resource
+	}
+	merged := make(map[string]string, len(resource.GetLabels())+len(labels))
+	for key, value := range resource.GetLabels() {
+		merged[key] = value
+	}
+	for key, value := range labels {
+		merged[key] = value
+	}
+	return &resourcepb.Resource{Type: resource.GetType(), Labels: merged}
+}
+
+// labelsSignature returns a string identifying the labels regardless of the
+// iteration order of the map.
+func labelsSignature(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		sb.WriteString(labels[key])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// attributeValueString formats the value of attr as a resource label. It
+// returns false for the values it cannot format.
+func attributeValueString(attr *tracepb.AttributeValue) (string, bool) {
+	switch v := attr.GetValue().(type) {
+	case *tracepb.AttributeValue_StringValue:
+		return v.StringValue.GetValue(), true
+	case *tracepb.AttributeValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10), true
+	case *tracepb.AttributeValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue), true
+	case *tracepb.AttributeValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,121 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestResourcePromotionProcessor(t *testing.T) {
+	newSpan := func(traceID byte, user string) *tracepb.Span {
+		span := newQuerySpan(traceID, "select 1")
+		setSpanAttribute(span, "database_name", stringAttributeValue("orders"))
+		setSpanAttribute(span, "username", stringAttributeValue(user))
+		return span
+	}
+	alice1, bob, alice2 := newSpan(1, "alice"), newSpan(2, "bob"), newSpan(3, "alice")
+	// The spans of the trace 4 disagree on the user, which stays on them.
+	mixed := newSpan(4, "alice")
+	mixedChild := newSpan(4, "carol")
+	mixedChild.SpanId = []byte{4, 0, 0, 0, 0, 0, 0, 2}
+
+	br := &batchRecorder{}
+	rpp := NewResourcePromotionProcessor(br, []string{"database_name", "username", "missing"})
+	td := data.TraceData{
+		Resource: &resourcepb.Resource{Type: "postgres", Labels: map[string]string{"region": "eu"}},
+		Spans:    []*tracepb.Span{alice1, bob, mixed, alice2, mixedChild},
+	}
+	if err := rpp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	want := []struct {
+		labels map[string]string
+		spans  []*tracepb.Span
+	}{
+		{map[string]string{"region": "eu", "database_name": "orders", "username": "alice"}, []*tracepb.Span{alice1, alice2}},
+		{map[string]string{"region": "eu", "database_name": "orders", "username": "bob"}, []*tracepb.Span{bob}},
+		{map[string]string{"region": "eu", "database_name": "orders"}, []*tracepb.Span{mixed, mixedChild}},
+	}
+	if len(br.batches) != len(want) {
+		t.Fatalf("Got %d TraceData, want %d", len(br.batches), len(want))
+	}
+	for i, w := range want {
+		got := br.batches[i]
+		if got.Resource.GetType() != "postgres" {
+			t.Errorf("TraceData %d: got resource type %q, want %q", i, got.Resource.GetType(), "postgres")
+		}
+		if !reflect.DeepEqual(got.Resource.GetLabels(), w.labels) {
+			t.Errorf("TraceData %d: got labels %v, want %v", i, got.Resource.GetLabels(), w.labels)
+		}
+		if !reflect.DeepEqual(got.Spans, w.spans) {
+			t.Errorf("TraceData %d: got %d spans, want %d", i, len(got.Spans), len(w.spans))
+		}
+	}
+
+	for _, span := range []*tracepb.Span{alice1, bob, alice2, mixed} {
+		if spanAttribute(span, "database_name") != nil {
+			t.Errorf("Got database_name left on span %v", span.SpanId)
+		}
+	}
+	if spanAttribute(alice1, "username") != nil {
+		t.Error("Got a promoted username left on the span")
+	}
+	if got := spanAttribute(mixedChild, "username").GetStringValue().GetValue(); got != "carol" {
+		t.Errorf("Got username %q on a span of a conflicting trace, want %q", got, "carol")
+	}
+	// The Resource of the TraceData is not modified.
+	if len(td.Resource.Labels) != 1 {
+		t.Errorf("Got the original resource labels modified: %v", td.Resource.Labels)
+	}
+}
+
+func TestResourcePromotionProcessorConflictingResource(t *testing.T) {
+	span := newQuerySpan(1, "select 1")
+	setSpanAttribute(span, "username", stringAttributeValue("alice"))
+	setSpanAttribute(span, "port", int64AttributeValue(5432))
+
+	br := &batchRecorder{}
+	rpp := NewResourcePromotionProcessor(br, []string{"username", "port"})
+	resource := &resourcepb.Resource{Labels: map[string]string{"username": "bob"}}
+	if err := rpp.ProcessTraceData(context.Background(), data.TraceData{Resource: resource, Spans: []*tracepb.Span{span}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	if len(br.batches) != 1 {
+		t.Fatalf("Got %d TraceData, want 1", len(br.batches))
+	}
+	want := map[string]string{"username": "bob", "port": "5432"}
+	if got := br.batches[0].Resource.GetLabels(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got labels %v, want %v", got, want)
+	}
+	if got := spanAttribute(span, "username").GetStringValue().GetValue(); got != "alice" {
+		t.Errorf("Got username %q, want the conflicting value %q kept on the span", got, "alice")
+	}
+}
+
+func TestResourcePromotionProcessorFailure(t *testing.T) {
+	rpp := NewResourcePromotionProcessor(&mockTraceDataProcessor{MustFail: true}, []string{"query"})
+	td := data.TraceData{Spans: []*tracepb.Span{newQuerySpan(1, "select 1"), newQuerySpan(2, "select 2")}}
+	if err := rpp.ProcessTraceData(context.Background(), td); err == nil {
+		t.Error("Wanted an error got nil")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const (
+	rowThroughputProcessorName = "row_throughput"
+
+	rowsFetchedAttributeKey = "Rows Fetched"
+	rowsPerMsAttributeKey   = "rows_per_ms"
+)
+
+type rowThroughputProcessor struct {
+	next TraceDataProcessor
+}
+
+var _ TraceDataProcessor = (*rowThroughputProcessor)(nil)
+
+// NewRowThroughputProcessor creates a TraceDataProcessor that sets a
+// rows_per_ms attribute, the number of rows produced per millisecond, on the
+// spans with a Rows Fetched attribute and a positive duration. The root spans
+// of the traces, whose parent is not in the trace and which have no Rows
+// Fetched attribute, get the throughput of the whole query: the rows of
+// their children, the top plan nodes, over their own duration. The spans
+// without a duration are skipped. The spans getting the attribute are counted
+// in the modified spans metric.
+func NewRowThroughputProcessor(next TraceDataProcessor) TraceDataProcessor {
+	return &rowThroughputProcessor{next: next}
+}
+
+func (rtp *rowThroughputProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	modified := 0
+	traces, order := spansByTraceID(td.Spans)
+	for _, traceID := range order {
+		modified += setRowThroughputs(traces[traceID])
+	}
+	if modified > 0 {
+		observability.RecordTraceProcessorModifiedSpans(
+			observability.ContextWithProcessorName(ctx, rowThroughputProcessorName), modified)
+	}
+	return rtp.next.ProcessTraceData(ctx, td)
+}
+
+// setRowThroughputs sets the rows_per_ms attribute on the spans of a trace,
+// and returns the number of spans it was set on.
+func setRowThroughputs(spans []*tracepb.Span) int {
+	// The rows of the children of every span, for the root spans.
+	childRows := make(map[string]int64)
+	for _, span := range spans {
+		if rows := spanAttribute(span, rowsFetchedAttributeKey); rows != nil && len(span.ParentSpanId) > 0 {
+			childRows[string(span.ParentSpanId)] += rows.GetIntValue()
+		}
+	}
+
+	modified := 0
+	for _, span := range spans {
+		rows := spanAttribute(span, rowsFetchedAttributeKey)
+		if rows != nil && setRowThroughput(span, rows.GetIntValue()) {
+			modified++
+		}
+	}
+	for _, root := range rootSpans(spans) {
+		rows, ok := childRows[string(root.SpanId)]
+		if ok && spanAttribute(root, rowsFetchedAttributeKey) == nil && setRowThroughput(root, rows) {
+			modified++
+		}
+	}
+	return modified
+}
+
+// setRowThroughput sets the rows_per_ms attribute of span, which produced
+// rows, unless it has no duration.
+func setRowThroughput(span *tracepb.Span, rows int64) bool {
+	ms := spanDurationMillis(span)
+	if ms <= 0 {
+		return false
+	}
+	setSpanAttribute(span, rowsPerMsAttributeKey, doubleAttributeValue(float64(rows)/ms))
+	return true
+}
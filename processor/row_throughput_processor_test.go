@@ -0,0 +1,58 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestRowThroughputProcessor(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	root := newPlanNodeSpan(1, 0, "CloudSQLQuery", start, 10*time.Millisecond)
+	join := newPlanNodeSpan(2, 1, "Hash Join", start, 8*time.Millisecond)
+	setSpanAttribute(join, rowsFetchedAttributeKey, int64AttributeValue(40))
+	scan := newPlanNodeSpan(3, 2, "Seq Scan", start, 4*time.Millisecond)
+	setSpanAttribute(scan, rowsFetchedAttributeKey, int64AttributeValue(1000))
+	// A node that never executed has no duration.
+	skipped := newPlanNodeSpan(4, 2, "Index Scan", start, 0)
+	setSpanAttribute(skipped, rowsFetchedAttributeKey, int64AttributeValue(0))
+	spans := []*tracepb.Span{scan, skipped, join, root}
+
+	next := &batchRecorder{}
+	rtp := NewRowThroughputProcessor(next)
+	if err := rtp.ProcessTraceData(context.Background(), data.TraceData{Spans: append(spans, nil)}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	want := map[byte]float64{1: 4, 2: 5, 3: 250}
+	for _, span := range spans {
+		got := spanAttribute(span, rowsPerMsAttributeKey)
+		wantRate, ok := want[span.SpanId[0]]
+		if !ok {
+			if got != nil {
+				t.Errorf("Span %d: got rows_per_ms %v, want none", span.SpanId[0], got)
+			}
+			continue
+		}
+		if got.GetDoubleValue() != wantRate {
+			t.Errorf("Span %d: got rows_per_ms %v, want %v", span.SpanId[0], got.GetDoubleValue(), wantRate)
+		}
+	}
+}
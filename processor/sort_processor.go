@@ -0,0 +1,98 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"sort"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+type sortProcessor struct {
+	next TraceDataProcessor
+}
+
+var _ TraceDataProcessor = (*sortProcessor)(nil)
+
+// NewSortProcessor creates a TraceDataProcessor that sorts the spans of the
+// TraceData by start time before forwarding them to next, for the backends
+// rendering the spans in the order they receive them. The spans starting at
+// the same time are sorted parents first, and otherwise keep their order. The
+// nil spans are moved last.
+func NewSortProcessor(next TraceDataProcessor) TraceDataProcessor {
+	return &sortProcessor{next: next}
+}
+
+func (sp *sortProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	if len(td.Spans) > 1 {
+		sortSpans(td.Spans)
+	}
+	return sp.next.ProcessTraceData(ctx, td)
+}
+
+// sortSpans sorts spans in place by start time, then depth in their trace.
+func sortSpans(spans []*tracepb.Span) {
+	depths := spanDepths(spans)
+	sort.SliceStable(spans, func(i, j int) bool {
+		a, b := spans[i], spans[j]
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		aStart, bStart := internal.TimestampToTime(a.StartTime), internal.TimestampToTime(b.StartTime)
+		if !aStart.Equal(bStart) {
+			return aStart.Before(bStart)
+		}
+		return depths[a] < depths[b]
+	})
+}
+
+// spanDepths returns the depth of every span among spans, the spans whose
+// parent is not among spans being at depth 0.
+func spanDepths(spans []*tracepb.Span) map[*tracepb.Span]int {
+	type spanKey struct {
+		traceID, spanID string
+	}
+	byID := make(map[spanKey]*tracepb.Span, len(spans))
+	for _, span := range spans {
+		if span != nil {
+			byID[spanKey{string(span.TraceId), string(span.SpanId)}] = span
+		}
+	}
+	depths := make(map[*tracepb.Span]int, len(spans))
+	var depth func(span *tracepb.Span, seen int) int
+	depth = func(span *tracepb.Span, seen int) int {
+		if d, ok := depths[span]; ok {
+			return d
+		}
+		parent, ok := byID[spanKey{string(span.TraceId), string(span.ParentSpanId)}]
+		// The bound on seen guards against cycles in malformed traces.
+		if !ok || len(span.ParentSpanId) == 0 || seen > len(spans) {
+			depths[span] = 0
+			return 0
+		}
+		d := depth(parent, seen+1) + 1
+		depths[span] = d
+		return d
+	}
+	for _, span := range spans {
+		if span != nil {
+			depth(span, 0)
+		}
+	}
+	return depths
+}
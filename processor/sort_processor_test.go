@@ -0,0 +1,65 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestSortProcessor(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	newSpan := func(id, parentID byte, offset time.Duration) *tracepb.Span {
+		span := newTimedSpan(start.Add(offset), time.Millisecond)
+		span.TraceId = []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		span.SpanId = []byte{1, 0, 0, 0, 0, 0, 0, id}
+		if parentID != 0 {
+			span.ParentSpanId = []byte{1, 0, 0, 0, 0, 0, 0, parentID}
+		}
+		return span
+	}
+	// As emitted by the receiver: the children first, then their parent,
+	// the root starting at the same time as its first child.
+	grandChild := newSpan(3, 2, 0)
+	child := newSpan(2, 1, 0)
+	late := newSpan(4, 1, 2*time.Millisecond)
+	other := newSpan(5, 1, time.Millisecond)
+	root := newSpan(1, 0, 0)
+
+	br := &batchRecorder{}
+	sp := NewSortProcessor(br)
+	td := data.TraceData{Spans: []*tracepb.Span{grandChild, nil, child, late, other, root}}
+	if err := sp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	want := []*tracepb.Span{root, child, grandChild, other, late, nil}
+	if got := br.batches[0].Spans; !reflect.DeepEqual(got, want) {
+		var names []string
+		for _, span := range got {
+			if span == nil {
+				names = append(names, "nil")
+				continue
+			}
+			names = append(names, string('0'+span.SpanId[7]))
+		}
+		t.Errorf("Got spans in the order %v, want 1 2 3 5 4 nil", names)
+	}
+}
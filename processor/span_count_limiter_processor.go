@@ -0,0 +1,160 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const spanCountLimiterProcessorName = "span_count_limiter"
+
+// ErrInvalidMaxSpansPerTrace occurs when the span count of a span count
+// limiter is less than 1.
+var ErrInvalidMaxSpansPerTrace = errors.New("invalid maximum number of spans per trace, it must be greater than zero")
+
+// SpanPriorityFunc ranks the spans trimmed by a span count limiter, the spans
+// with the highest priority are kept.
+type SpanPriorityFunc func(span *tracepb.Span) float64
+
+// DurationSpanPriority ranks the spans by duration, in seconds, keeping the
+// slowest ones.
+func DurationSpanPriority(span *tracepb.Span) float64 {
+	duration := internal.TimestampToTime(span.EndTime).Sub(internal.TimestampToTime(span.StartTime))
+	return duration.Seconds()
+}
+
+// AttributeSpanPriority returns a SpanPriorityFunc ranking the spans by the
+// numeric attribute key, e.g. "Rows Fetched" or a cost attribute. The spans
+// without the attribute rank last.
+func AttributeSpanPriority(key string) SpanPriorityFunc {
+	return func(span *tracepb.Span) float64 {
+		switch v := spanAttribute(span, key).GetValue().(type) {
+		case *tracepb.AttributeValue_IntValue:
+			return float64(v.IntValue)
+		case *tracepb.AttributeValue_DoubleValue:
+			return v.DoubleValue
+		}
+		return 0
+	}
+}
+
+type spanCountLimiter struct {
+	next             TraceDataProcessor
+	maxSpansPerTrace int
+	priority         SpanPriorityFunc
+}
+
+var _ TraceDataProcessor = (*spanCountLimiter)(nil)
+
+// NewSpanCountLimiter creates a TraceDataProcessor that keeps at most
+// maxSpansPerTrace spans of every trace of the TraceData, the root spans and
+// then the spans ranked first by priority. A nil priority ranks the spans with
+// DurationSpanPriority. The children of the removed spans are attached to
+// their nearest kept ancestor. The root spans are always kept, even when they
+// alone exceed maxSpansPerTrace.
+func NewSpanCountLimiter(next TraceDataProcessor, maxSpansPerTrace int, priority SpanPriorityFunc) (TraceDataProcessor, error) {
+	if maxSpansPerTrace < 1 {
+		return nil, ErrInvalidMaxSpansPerTrace
+	}
+	if priority == nil {
+		priority = DurationSpanPriority
+	}
+	return &spanCountLimiter{next: next, maxSpansPerTrace: maxSpansPerTrace, priority: priority}, nil
+}
+
+func (scl *spanCountLimiter) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	traces, order := spansByTraceID(td.Spans)
+	// removed holds the parent span IDs of the removed spans by span ID.
+	removed := make(map[string][]byte)
+	for _, traceID := range order {
+		spans := traces[traceID]
+		if len(spans) <= scl.maxSpansPerTrace {
+			continue
+		}
+		scl.trim(spans, removed)
+	}
+	if len(removed) == 0 {
+		return scl.next.ProcessTraceData(ctx, td)
+	}
+
+	kept := make([]*tracepb.Span, 0, len(td.Spans)-len(removed))
+	for _, span := range td.Spans {
+		if span != nil {
+			if _, ok := removed[string(span.SpanId)]; ok {
+				continue
+			}
+			span.ParentSpanId = keptAncestor(span.ParentSpanId, removed)
+		}
+		kept = append(kept, span)
+	}
+	processorCtx := observability.ContextWithProcessorName(ctx, spanCountLimiterProcessorName)
+	observability.RecordTraceProcessorMetrics(processorCtx, len(removed))
+	td.Spans = kept
+	return scl.next.ProcessTraceData(ctx, td)
+}
+
+// trim adds the spans of a trace exceeding the limit to removed.
+func (scl *spanCountLimiter) trim(spans []*tracepb.Span, removed map[string][]byte) {
+	roots := rootSpans(spans)
+	isRoot := make(map[*tracepb.Span]bool, len(roots))
+	for _, root := range roots {
+		isRoot[root] = true
+	}
+	var candidates []*tracepb.Span
+	for _, span := range spans {
+		if span != nil && !isRoot[span] {
+			candidates = append(candidates, span)
+		}
+	}
+	priorities := make(map[*tracepb.Span]float64, len(candidates))
+	for _, span := range candidates {
+		priorities[span] = scl.priority(span)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return priorities[candidates[i]] > priorities[candidates[j]]
+	})
+
+	keep := scl.maxSpansPerTrace - len(roots)
+	if keep < 0 {
+		keep = 0
+	}
+	if keep > len(candidates) {
+		keep = len(candidates)
+	}
+	for _, span := range candidates[keep:] {
+		removed[string(span.SpanId)] = span.ParentSpanId
+	}
+}
+
+// keptAncestor returns the ID of the nearest ancestor of the span
+// parentSpanID that was not removed.
+func keptAncestor(parentSpanID []byte, removed map[string][]byte) []byte {
+	// The depth bound guards against cycles in malformed traces.
+	for depth := 0; depth <= len(removed); depth++ {
+		grandParentSpanID, ok := removed[string(parentSpanID)]
+		if !ok {
+			return parentSpanID
+		}
+		parentSpanID = grandParentSpanID
+	}
+	return parentSpanID
+}
@@ -0,0 +1,110 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestNewSpanCountLimiterInvalidMaxSpans(t *testing.T) {
+	for _, maxSpans := range []int{0, -1} {
+		if _, err := NewSpanCountLimiter(&mockTraceDataProcessor{}, maxSpans, nil); err != ErrInvalidMaxSpansPerTrace {
+			t.Errorf("Got error %v for %d spans, want %v", err, maxSpans, ErrInvalidMaxSpansPerTrace)
+		}
+	}
+}
+
+// newSpanTree returns a root span, a child of the root, and two children of
+// that child, lasting 10s, 1s, 5s and 3s and fetching 1, 40, 20 and 30 rows.
+func newSpanTree() (root, child, slow, fast *tracepb.Span) {
+	start := time.Unix(1546300800, 0)
+	newSpan := func(id byte, parent *tracepb.Span, duration time.Duration, rows int64) *tracepb.Span {
+		span := newTimedSpan(start, duration)
+		span.TraceId = []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		span.SpanId = []byte{1, 0, 0, 0, 0, 0, 0, id}
+		if parent != nil {
+			span.ParentSpanId = parent.SpanId
+		}
+		setSpanAttribute(span, "Rows Fetched", int64AttributeValue(rows))
+		return span
+	}
+	root = newSpan(1, nil, 10*time.Second, 1)
+	child = newSpan(2, root, time.Second, 40)
+	slow = newSpan(3, child, 5*time.Second, 20)
+	fast = newSpan(4, child, 3*time.Second, 30)
+	return root, child, slow, fast
+}
+
+func TestSpanCountLimiterByDuration(t *testing.T) {
+	root, child, slow, fast := newSpanTree()
+	br := &batchRecorder{}
+	scl, err := NewSpanCountLimiter(br, 2, nil)
+	if err != nil {
+		t.Fatalf("NewSpanCountLimiter() error = %v", err)
+	}
+	if err := scl.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{root, child, slow, fast}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	spans := br.batches[0].Spans
+	if len(spans) != 2 || spans[0] != root || spans[1] != slow {
+		t.Fatalf("Got spans %v, want the root and the slowest span", spans)
+	}
+	// The removed child is replaced by its parent.
+	if !bytes.Equal(slow.ParentSpanId, root.SpanId) {
+		t.Errorf("Got parent %x, want the root span %x", slow.ParentSpanId, root.SpanId)
+	}
+}
+
+func TestSpanCountLimiterByAttribute(t *testing.T) {
+	root, child, slow, fast := newSpanTree()
+	br := &batchRecorder{}
+	scl, err := NewSpanCountLimiter(br, 3, AttributeSpanPriority("Rows Fetched"))
+	if err != nil {
+		t.Fatalf("NewSpanCountLimiter() error = %v", err)
+	}
+	if err := scl.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{root, child, slow, fast}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	spans := br.batches[0].Spans
+	if len(spans) != 3 || spans[0] != root || spans[1] != child || spans[2] != fast {
+		t.Fatalf("Got spans %v, want the root and the spans fetching the most rows", spans)
+	}
+	if !bytes.Equal(fast.ParentSpanId, child.SpanId) {
+		t.Errorf("Got parent %x, want the kept child %x", fast.ParentSpanId, child.SpanId)
+	}
+}
+
+func TestSpanCountLimiterUnderLimit(t *testing.T) {
+	root, child, slow, fast := newSpanTree()
+	next := &mockTraceDataProcessor{}
+	scl, err := NewSpanCountLimiter(next, 4, nil)
+	if err != nil {
+		t.Fatalf("NewSpanCountLimiter() error = %v", err)
+	}
+	if err := scl.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{root, child, slow, fast}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if next.TotalSpans != 4 {
+		t.Errorf("Got %d spans, want 4", next.TotalSpans)
+	}
+}
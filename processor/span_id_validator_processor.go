@@ -0,0 +1,138 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"crypto/rand"
+	"log"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const (
+	spanIDValidatorProcessorName = "span_id_validator"
+
+	traceIDLength = 16
+	spanIDLength  = 8
+)
+
+type spanIDValidatorProcessor struct {
+	next TraceDataProcessor
+	fix  bool
+}
+
+var _ TraceDataProcessor = (*spanIDValidatorProcessor)(nil)
+
+// SpanIDValidatorOption is an option to NewSpanIDValidatorProcessor.
+type SpanIDValidatorOption func(*spanIDValidatorProcessor)
+
+// WithSpanIDValidatorFix makes the span ID validator fix the spans it can
+// instead of dropping them: an invalid span ID is replaced by a random one,
+// which the children of the span get as parent, and an invalid parent span ID
+// is removed, making the span a root. The spans with an invalid trace ID are
+// still dropped.
+func WithSpanIDValidatorFix() SpanIDValidatorOption {
+	return func(svp *spanIDValidatorProcessor) {
+		svp.fix = true
+	}
+}
+
+// NewSpanIDValidatorProcessor creates a TraceDataProcessor that drops the
+// spans whose IDs some exporters cannot handle: a trace ID that is not 16
+// bytes, a span ID that is not 8 bytes, either being all zeros, or a parent
+// span ID that is neither empty nor 8 bytes. The dropped spans are counted in
+// the processor dropped spans metric, the fixed ones, see
+// WithSpanIDValidatorFix, in the processor modified spans metric.
+func NewSpanIDValidatorProcessor(next TraceDataProcessor, opts ...SpanIDValidatorOption) TraceDataProcessor {
+	svp := &spanIDValidatorProcessor{next: next}
+	for _, opt := range opts {
+		opt(svp)
+	}
+	return svp
+}
+
+func (svp *spanIDValidatorProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	kept := make([]*tracepb.Span, 0, len(td.Spans))
+	// newSpanIDs maps the invalid span IDs to the IDs that replaced them.
+	newSpanIDs := make(map[string][]byte)
+	dropped, fixed := 0, 0
+	for _, span := range td.Spans {
+		if span == nil {
+			kept = append(kept, span)
+			continue
+		}
+		validTraceID := isValidID(span.TraceId, traceIDLength)
+		validSpanID := isValidID(span.SpanId, spanIDLength)
+		validParent := len(span.ParentSpanId) == 0 || len(span.ParentSpanId) == spanIDLength
+		if validTraceID && validSpanID && validParent {
+			kept = append(kept, span)
+			continue
+		}
+		if !svp.fix || !validTraceID {
+			dropped++
+			continue
+		}
+		if !validSpanID {
+			newSpanID := make([]byte, spanIDLength)
+			rand.Read(newSpanID)
+			newSpanIDs[string(span.SpanId)] = newSpanID
+			span.SpanId = newSpanID
+		}
+		if !validParent {
+			span.ParentSpanId = nil
+		}
+		fixed++
+		kept = append(kept, span)
+	}
+	if len(newSpanIDs) > 0 {
+		for _, span := range kept {
+			if span == nil || len(span.ParentSpanId) == 0 {
+				continue
+			}
+			if newSpanID, ok := newSpanIDs[string(span.ParentSpanId)]; ok {
+				span.ParentSpanId = newSpanID
+			}
+		}
+	}
+
+	if dropped > 0 || fixed > 0 {
+		log.Printf("Found %d spans with invalid IDs, dropped %d and fixed %d", dropped+fixed, dropped, fixed)
+		processorCtx := observability.ContextWithProcessorName(ctx, spanIDValidatorProcessorName)
+		if dropped > 0 {
+			observability.RecordTraceProcessorMetrics(processorCtx, dropped)
+		}
+		if fixed > 0 {
+			observability.RecordTraceProcessorModifiedSpans(processorCtx, fixed)
+		}
+	}
+	td.Spans = kept
+	return svp.next.ProcessTraceData(ctx, td)
+}
+
+// isValidID reports whether id has length bytes and is not all zeros.
+func isValidID(id []byte, length int) bool {
+	if len(id) != length {
+		return false
+	}
+	for _, b := range id {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func newInvalidIDSpans() (valid, badTraceID, badSpanID, childOfBadSpanID, badParent *tracepb.Span) {
+	valid = newQuerySpan(1, "select 1")
+	badTraceID = newQuerySpan(2, "select 2")
+	badTraceID.TraceId = badTraceID.TraceId[:8]
+	badSpanID = newQuerySpan(3, "select 3")
+	badSpanID.SpanId = []byte{0, 0, 0, 0, 0, 0, 0, 0}
+	childOfBadSpanID = newQuerySpan(3, "select 3")
+	childOfBadSpanID.SpanId = []byte{3, 0, 0, 0, 0, 0, 0, 2}
+	childOfBadSpanID.ParentSpanId = badSpanID.SpanId
+	badParent = newQuerySpan(4, "select 4")
+	badParent.ParentSpanId = []byte{4, 0, 0}
+	return
+}
+
+func TestSpanIDValidatorProcessorDrop(t *testing.T) {
+	valid, badTraceID, badSpanID, childOfBadSpanID, badParent := newInvalidIDSpans()
+	next := &batchRecorder{}
+	svp := NewSpanIDValidatorProcessor(next)
+	td := data.TraceData{Spans: []*tracepb.Span{valid, badTraceID, badSpanID, childOfBadSpanID, badParent, nil}}
+	if err := svp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	spans := next.batches[0].Spans
+	if len(spans) != 3 || spans[0] != valid || spans[1] != childOfBadSpanID || spans[2] != nil {
+		t.Errorf("Got spans %v, want the spans with valid IDs only", spans)
+	}
+}
+
+func TestSpanIDValidatorProcessorFix(t *testing.T) {
+	valid, badTraceID, badSpanID, childOfBadSpanID, badParent := newInvalidIDSpans()
+	next := &batchRecorder{}
+	svp := NewSpanIDValidatorProcessor(next, WithSpanIDValidatorFix())
+	td := data.TraceData{Spans: []*tracepb.Span{valid, badTraceID, badSpanID, childOfBadSpanID, badParent}}
+	if err := svp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	// An invalid trace ID cannot be fixed.
+	spans := next.batches[0].Spans
+	if len(spans) != 4 || spans[1] != badSpanID {
+		t.Fatalf("Got spans %v, want all but the one with an invalid trace ID", spans)
+	}
+	if !isValidID(badSpanID.SpanId, spanIDLength) {
+		t.Errorf("Got span ID %x, want a valid one", badSpanID.SpanId)
+	}
+	if !bytes.Equal(childOfBadSpanID.ParentSpanId, badSpanID.SpanId) {
+		t.Errorf("Got parent span ID %x, want the new ID of the parent %x", childOfBadSpanID.ParentSpanId, badSpanID.SpanId)
+	}
+	if badParent.ParentSpanId != nil {
+		t.Errorf("Got parent span ID %x, want it removed", badParent.ParentSpanId)
+	}
+}
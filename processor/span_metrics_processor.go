@@ -0,0 +1,236 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+const (
+	queryLatencyMetricName = "query_latency"
+	queryCountMetricName   = "query_count"
+)
+
+// queryLatencyBounds are the bucket bounds, in milliseconds, of the query
+// latency distribution.
+var queryLatencyBounds = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+type spanMetricsProcessor struct {
+	next        TraceDataProcessor
+	metricsNext MetricsDataProcessor
+	// start is the start time of the cumulative metrics.
+	start time.Time
+	now   func() time.Time
+
+	mu sync.Mutex
+	// latencies are the latencies of the queries, by service name and query
+	// fingerprint.
+	latencies map[queryKey]*queryLatency
+}
+
+// queryKey identifies the queries of a service sharing a fingerprint.
+type queryKey struct {
+	serviceName string
+	fingerprint string
+}
+
+// queryLatency is the cumulative latency distribution of the queries of a
+// queryKey, in milliseconds.
+type queryLatency struct {
+	count int64
+	sum   float64
+	// sumOfSquaredDeviation is updated with Welford's algorithm.
+	sumOfSquaredDeviation float64
+	buckets               []int64
+}
+
+var _ TraceDataProcessor = (*spanMetricsProcessor)(nil)
+
+// NewSpanMetricsProcessor creates a TraceDataProcessor that derives metrics
+// from the root spans of the queries, the spans carrying a "query" attribute,
+// and sends them to metricsNext before forwarding the TraceData to next:
+//
+//   - query_latency, the cumulative distribution of the durations of the
+//     queries, in milliseconds.
+//   - query_count, the cumulative number of queries.
+//
+// Both are keyed by the query_fingerprint label, the "query_fingerprint"
+// attribute of the spans if set, see NewQueryFingerprintProcessor, or else
+// the fingerprint of their query. The metrics are tracked by service, and sent
+// with the Node of the TraceData they were derived from. Every TraceData with
+// queries sends the current values of the fingerprints it holds.
+func NewSpanMetricsProcessor(next TraceDataProcessor, metricsNext MetricsDataProcessor) TraceDataProcessor {
+	return &spanMetricsProcessor{
+		next:        next,
+		metricsNext: metricsNext,
+		start:       time.Now(),
+		now:         time.Now,
+		latencies:   make(map[queryKey]*queryLatency),
+	}
+}
+
+func (smp *spanMetricsProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	// The metrics are computed before forwarding, the next processors may
+	// modify the spans.
+	metrics := smp.record(td)
+
+	var errs []error
+	if err := smp.next.ProcessTraceData(ctx, td); err != nil {
+		errs = append(errs, err)
+	}
+	if len(metrics) > 0 {
+		md := data.MetricsData{Node: td.Node, Resource: td.Resource, Metrics: metrics}
+		if err := smp.metricsNext.ProcessMetricsData(ctx, md); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// record adds the queries of td to the latencies, and returns the metrics of
+// their fingerprints, or nil if td holds no query.
+func (smp *spanMetricsProcessor) record(td data.TraceData) []*metricspb.Metric {
+	serviceName := td.Node.GetServiceInfo().GetName()
+	var keys []queryKey
+	seen := make(map[queryKey]bool)
+
+	smp.mu.Lock()
+	defer smp.mu.Unlock()
+	for _, span := range rootSpans(td.Spans) {
+		fingerprint, ok := spanFingerprint(span)
+		if !ok {
+			continue
+		}
+		key := queryKey{serviceName: serviceName, fingerprint: fingerprint}
+		latency := smp.latencies[key]
+		if latency == nil {
+			latency = &queryLatency{buckets: make([]int64, len(queryLatencyBounds)+1)}
+			smp.latencies[key] = latency
+		}
+		latency.add(spanDurationMillis(span))
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].fingerprint < keys[j].fingerprint })
+	start := internal.TimeToTimestamp(smp.start)
+	now := internal.TimeToTimestamp(smp.now())
+	var latencySeries, countSeries []*metricspb.TimeSeries
+	for _, key := range keys {
+		latency := smp.latencies[key]
+		labelValues := []*metricspb.LabelValue{{Value: key.fingerprint, HasValue: true}}
+		latencySeries = append(latencySeries, &metricspb.TimeSeries{
+			StartTimestamp: start,
+			LabelValues:    labelValues,
+			Points: []*metricspb.Point{{
+				Timestamp: now,
+				Value:     &metricspb.Point_DistributionValue{DistributionValue: latency.distribution()},
+			}},
+		})
+		countSeries = append(countSeries, &metricspb.TimeSeries{
+			StartTimestamp: start,
+			LabelValues:    labelValues,
+			Points: []*metricspb.Point{{
+				Timestamp: now,
+				Value:     &metricspb.Point_Int64Value{Int64Value: latency.count},
+			}},
+		})
+	}
+	return []*metricspb.Metric{
+		newQueryMetric(queryLatencyMetricName, "The latency of the queries", "ms", metricspb.MetricDescriptor_CUMULATIVE_DISTRIBUTION, latencySeries),
+		newQueryMetric(queryCountMetricName, "The number of queries", "1", metricspb.MetricDescriptor_CUMULATIVE_INT64, countSeries),
+	}
+}
+
+func newQueryMetric(name, description, unit string, metricType metricspb.MetricDescriptor_Type, timeseries []*metricspb.TimeSeries) *metricspb.Metric {
+	descriptor := &metricspb.MetricDescriptor{
+		Name:        name,
+		Description: description,
+		Unit:        unit,
+		Type:        metricType,
+		LabelKeys:   []*metricspb.LabelKey{{Key: queryFingerprintAttributeKey}},
+	}
+	return &metricspb.Metric{
+		Descriptor_: &metricspb.Metric_MetricDescriptor{
+			MetricDescriptor: descriptor,
+		},
+		Timeseries: timeseries,
+	}
+}
+
+// spanFingerprint returns the fingerprint of the query of span, if it has one.
+func spanFingerprint(span *tracepb.Span) (string, bool) {
+	if attr := spanAttribute(span, queryFingerprintAttributeKey); attr != nil {
+		if fingerprint := attr.GetStringValue().GetValue(); fingerprint != "" {
+			return fingerprint, true
+		}
+	}
+	query, ok := queryOfSpans([]*tracepb.Span{span})
+	if !ok {
+		return "", false
+	}
+	return fingerprintQuery(query), true
+}
+
+// spanDurationMillis returns the duration of span in milliseconds.
+func spanDurationMillis(span *tracepb.Span) float64 {
+	duration := internal.TimestampToTime(span.EndTime).Sub(internal.TimestampToTime(span.StartTime))
+	return float64(duration) / float64(time.Millisecond)
+}
+
+func (ql *queryLatency) add(ms float64) {
+	mean := 0.0
+	if ql.count > 0 {
+		mean = ql.sum / float64(ql.count)
+	}
+	ql.count++
+	ql.sum += ms
+	ql.sumOfSquaredDeviation += (ms - mean) * (ms - ql.sum/float64(ql.count))
+	// The buckets include their lower bound.
+	ql.buckets[sort.Search(len(queryLatencyBounds), func(i int) bool { return queryLatencyBounds[i] > ms })]++
+}
+
+func (ql *queryLatency) distribution() *metricspb.DistributionValue {
+	buckets := make([]*metricspb.DistributionValue_Bucket, len(ql.buckets))
+	for i, count := range ql.buckets {
+		buckets[i] = &metricspb.DistributionValue_Bucket{Count: count}
+	}
+	return &metricspb.DistributionValue{
+		Count:                 ql.count,
+		Sum:                   ql.sum,
+		SumOfSquaredDeviation: ql.sumOfSquaredDeviation,
+		BucketOptions: &metricspb.DistributionValue_BucketOptions{
+			Type: &metricspb.DistributionValue_BucketOptions_Explicit_{
+				Explicit: &metricspb.DistributionValue_BucketOptions_Explicit{
+					Bounds: queryLatencyBounds,
+				},
+			},
+		},
+		Buckets: buckets,
+	}
+}
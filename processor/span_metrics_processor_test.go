@@ -0,0 +1,137 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+type metricsRecorder struct {
+	metrics []data.MetricsData
+}
+
+func (mr *metricsRecorder) ProcessMetricsData(ctx context.Context, md data.MetricsData) error {
+	mr.metrics = append(mr.metrics, md)
+	return nil
+}
+
+func newQueryTimedSpan(traceID byte, query string, duration time.Duration) *tracepb.Span {
+	span := newTimedSpan(time.Unix(1546300800, 0), duration)
+	querySpan := newQuerySpan(traceID, query)
+	span.TraceId, span.SpanId, span.Attributes = querySpan.TraceId, querySpan.SpanId, querySpan.Attributes
+	return span
+}
+
+func TestSpanMetricsProcessor(t *testing.T) {
+	next := &mockTraceDataProcessor{}
+	mr := &metricsRecorder{}
+	smp := NewSpanMetricsProcessor(next, mr)
+
+	child := newTimedSpan(time.Unix(1546300800, 0), time.Millisecond)
+	child.SpanId = []byte{1, 0, 0, 0, 0, 0, 0, 2}
+	child.ParentSpanId = []byte{1, 0, 0, 0, 0, 0, 0, 1}
+	node := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "orders"}}
+	td := data.TraceData{
+		Node: node,
+		Spans: []*tracepb.Span{
+			newQueryTimedSpan(1, "select * from orders where id = 1", 3*time.Millisecond),
+			child,
+			newQueryTimedSpan(2, "select * from orders where id = 2", 5*time.Millisecond),
+			newQueryTimedSpan(3, "select 1", 100*time.Millisecond),
+		},
+	}
+	if err := smp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if next.TotalSpans != 4 {
+		t.Errorf("Got %d spans forwarded, want 4", next.TotalSpans)
+	}
+	// The fingerprint set by the fingerprint processor has precedence.
+	span := newQueryTimedSpan(4, "select 2", 7*time.Millisecond)
+	setSpanAttribute(span, "query_fingerprint", stringAttributeValue("select ?"))
+	if err := smp.ProcessTraceData(context.Background(), data.TraceData{Node: node, Spans: []*tracepb.Span{span}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	if len(mr.metrics) != 2 {
+		t.Fatalf("Got %d MetricsData, want 2", len(mr.metrics))
+	}
+	md := mr.metrics[0]
+	if md.Node != node {
+		t.Error("Got a MetricsData without the Node of the TraceData")
+	}
+	if len(md.Metrics) != 2 {
+		t.Fatalf("Got %d metrics, want 2", len(md.Metrics))
+	}
+	latency, count := md.Metrics[0], md.Metrics[1]
+	if got := latency.GetMetricDescriptor().GetName(); got != "query_latency" {
+		t.Errorf("Got metric %q, want %q", got, "query_latency")
+	}
+	if got := count.GetMetricDescriptor().GetName(); got != "query_count" {
+		t.Errorf("Got metric %q, want %q", got, "query_count")
+	}
+	var fingerprints []string
+	for _, ts := range count.Timeseries {
+		fingerprints = append(fingerprints, ts.LabelValues[0].Value)
+	}
+	want := []string{"select * from orders where id = ?", "select ?"}
+	if !reflect.DeepEqual(fingerprints, want) {
+		t.Fatalf("Got fingerprints %q, want %q", fingerprints, want)
+	}
+	if got := count.Timeseries[0].Points[0].GetInt64Value(); got != 2 {
+		t.Errorf("Got count %d, want 2", got)
+	}
+	distribution := latency.Timeseries[0].Points[0].GetDistributionValue()
+	if distribution.Count != 2 || distribution.Sum != 8 {
+		t.Errorf("Got count %d and sum %v, want 2 and 8", distribution.Count, distribution.Sum)
+	}
+	// (3 - 4)² + (5 - 4)²
+	if math.Abs(distribution.SumOfSquaredDeviation-2) > 1e-9 {
+		t.Errorf("Got sum of squared deviation %v, want 2", distribution.SumOfSquaredDeviation)
+	}
+	// 3ms and 5ms, the buckets include their lower bound.
+	if got := distribution.Buckets[2].Count + distribution.Buckets[3].Count; got != 2 {
+		t.Errorf("Got %d latencies in [2, 10), want 2", got)
+	}
+
+	// The second TraceData only reports its own fingerprint, cumulatively.
+	count = mr.metrics[1].Metrics[1]
+	if len(count.Timeseries) != 1 {
+		t.Fatalf("Got %d time series, want 1", len(count.Timeseries))
+	}
+	if got := count.Timeseries[0].Points[0].GetInt64Value(); got != 2 {
+		t.Errorf("Got count %d for select ?, want 2", got)
+	}
+}
+
+func TestSpanMetricsProcessorNoQuery(t *testing.T) {
+	mr := &metricsRecorder{}
+	smp := NewSpanMetricsProcessor(&mockTraceDataProcessor{}, mr)
+	td := data.TraceData{Spans: []*tracepb.Span{newTimedSpan(time.Unix(1546300800, 0), time.Second)}}
+	if err := smp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if len(mr.metrics) != 0 {
+		t.Errorf("Got %d MetricsData without queries, want 0", len(mr.metrics))
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const spanNestingProcessorName = "span_nesting"
+
+type spanNestingProcessor struct {
+	next TraceDataProcessor
+}
+
+var _ TraceDataProcessor = (*spanNestingProcessor)(nil)
+
+// NewSpanNestingProcessor creates a TraceDataProcessor that makes every span
+// lie within its parent, for the backends requiring a well-formed span tree.
+// The timing of the plan nodes is derived independently of their parents, so
+// a child can start before or end after its parent. The start and end of such
+// children are clamped to the interval of their parent, from the root spans
+// down so that the repairs carry over to the grandchildren. The repaired spans
+// are logged and counted in the modified spans metric. Only the parents
+// present in the same TraceData are considered.
+func NewSpanNestingProcessor(next TraceDataProcessor) TraceDataProcessor {
+	return &spanNestingProcessor{next: next}
+}
+
+func (snp *spanNestingProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	if repaired := repairNesting(td.Spans); repaired > 0 {
+		log.Printf("Moved %d spans within their parent", repaired)
+		processorCtx := observability.ContextWithProcessorName(ctx, spanNestingProcessorName)
+		observability.RecordTraceProcessorModifiedSpans(processorCtx, repaired)
+	}
+	return snp.next.ProcessTraceData(ctx, td)
+}
+
+// repairNesting clamps the spans to the interval of their parent, and returns
+// the number of spans it changed.
+func repairNesting(spans []*tracepb.Span) int {
+	type spanKey struct {
+		traceID, spanID string
+	}
+	byID := make(map[spanKey]*tracepb.Span, len(spans))
+	var children []*tracepb.Span
+	for _, span := range spans {
+		if span == nil || span.StartTime == nil || span.EndTime == nil {
+			continue
+		}
+		byID[spanKey{string(span.TraceId), string(span.SpanId)}] = span
+		if len(span.ParentSpanId) > 0 {
+			children = append(children, span)
+		}
+	}
+	// Parents first.
+	depths := spanDepths(spans)
+	sort.SliceStable(children, func(i, j int) bool {
+		return depths[children[i]] < depths[children[j]]
+	})
+
+	repaired := 0
+	for _, child := range children {
+		parent, ok := byID[spanKey{string(child.TraceId), string(child.ParentSpanId)}]
+		if !ok || parent == child {
+			continue
+		}
+		parentStart, parentEnd := internal.TimestampToTime(parent.StartTime), internal.TimestampToTime(parent.EndTime)
+		start, end := internal.TimestampToTime(child.StartTime), internal.TimestampToTime(child.EndTime)
+		newStart, newEnd := start, end
+		if newStart.Before(parentStart) {
+			newStart = parentStart
+		}
+		if newStart.After(parentEnd) {
+			newStart = parentEnd
+		}
+		if newEnd.After(parentEnd) {
+			newEnd = parentEnd
+		}
+		if newEnd.Before(newStart) {
+			newEnd = newStart
+		}
+		if newStart.Equal(start) && newEnd.Equal(end) {
+			continue
+		}
+		child.StartTime = internal.TimeToTimestamp(newStart)
+		child.EndTime = internal.TimeToTimestamp(newEnd)
+		repaired++
+	}
+	return repaired
+}
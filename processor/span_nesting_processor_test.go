@@ -0,0 +1,74 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+func TestSpanNestingProcessor(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	newSpan := func(id, parentID byte, offset, duration time.Duration) *tracepb.Span {
+		span := newTimedSpan(start.Add(offset), duration)
+		span.TraceId = []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		span.SpanId = []byte{1, 0, 0, 0, 0, 0, 0, id}
+		if parentID != 0 {
+			span.ParentSpanId = []byte{1, 0, 0, 0, 0, 0, 0, parentID}
+		}
+		return span
+	}
+	root := newSpan(1, 0, 0, 10*time.Millisecond)
+	// Ends after the root, and its child, listed first, starts before it.
+	child := newSpan(2, 1, 5*time.Millisecond, 10*time.Millisecond)
+	grandChild := newSpan(3, 2, 2*time.Millisecond, 10*time.Millisecond)
+	// Entirely after the root.
+	late := newSpan(4, 1, 20*time.Millisecond, time.Millisecond)
+	nested := newSpan(5, 1, time.Millisecond, time.Millisecond)
+
+	next := &mockTraceDataProcessor{}
+	snp := NewSpanNestingProcessor(next)
+	td := data.TraceData{Spans: []*tracepb.Span{grandChild, nil, child, late, nested, root}}
+	if err := snp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		span       *tracepb.Span
+		start, end time.Duration
+	}{
+		{"root", root, 0, 10 * time.Millisecond},
+		{"child", child, 5 * time.Millisecond, 10 * time.Millisecond},
+		{"grand child", grandChild, 5 * time.Millisecond, 10 * time.Millisecond},
+		{"late", late, 10 * time.Millisecond, 10 * time.Millisecond},
+		{"nested", nested, time.Millisecond, 2 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		gotStart := internal.TimestampToTime(tt.span.StartTime).Sub(start)
+		gotEnd := internal.TimestampToTime(tt.span.EndTime).Sub(start)
+		if gotStart != tt.start || gotEnd != tt.end {
+			t.Errorf("Got %s from %v to %v, want from %v to %v", tt.name, gotStart, gotEnd, tt.start, tt.end)
+		}
+	}
+	if next.TotalSpans != 6 {
+		t.Errorf("Got %d spans forwarded, want 6", next.TotalSpans)
+	}
+}
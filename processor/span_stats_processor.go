@@ -0,0 +1,182 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+const (
+	siblingCountAttributeKey    = "sibling_count"
+	minDurationAttributeKey     = "min_duration_ms"
+	maxDurationAttributeKey     = "max_duration_ms"
+	meanDurationAttributeKey    = "mean_duration_ms"
+	summarizedCountAttributeKey = "summarized_count"
+
+	defaultSpanStatsExtremes = 1
+)
+
+// ErrInvalidMinSiblings is returned by NewSpanStatsProcessor when the number
+// of siblings from which they are summarized is less than 2.
+var ErrInvalidMinSiblings = errors.New("invalid minimum number of siblings, it must be at least 2")
+
+type spanStatsProcessor struct {
+	next        TraceDataProcessor
+	minSiblings int
+	extremes    int
+}
+
+var _ TraceDataProcessor = (*spanStatsProcessor)(nil)
+
+// SpanStatsOption is an option to NewSpanStatsProcessor.
+type SpanStatsOption func(*spanStatsProcessor)
+
+// WithSpanStatsExtremes sets the number of the fastest and of the slowest
+// siblings kept as real spans next to the summary span, 1 by default. Zero
+// summarizes all of them.
+func WithSpanStatsExtremes(extremes int) SpanStatsOption {
+	return func(ssp *spanStatsProcessor) {
+		if extremes < 0 {
+			extremes = 0
+		}
+		ssp.extremes = extremes
+	}
+}
+
+// NewSpanStatsProcessor creates a TraceDataProcessor that summarizes the
+// sibling spans with the same name, e.g. the Seq Scan nodes of the partitions
+// of a table, when there are at least minSiblings of them. The fastest and
+// the slowest siblings are kept, see WithSpanStatsExtremes, and the others
+// are replaced by the first of them, which becomes the summary span: it spans
+// from the earliest start to the latest end of the spans it replaces and gets
+// a summarized_count attribute with their number, and sibling_count,
+// min_duration_ms, max_duration_ms and mean_duration_ms attributes with the
+// statistics of all the siblings. The children of the replaced spans are
+// attached to the summary span.
+func NewSpanStatsProcessor(next TraceDataProcessor, minSiblings int, opts ...SpanStatsOption) (TraceDataProcessor, error) {
+	if minSiblings < 2 {
+		return nil, ErrInvalidMinSiblings
+	}
+	ssp := &spanStatsProcessor{
+		next:        next,
+		minSiblings: minSiblings,
+		extremes:    defaultSpanStatsExtremes,
+	}
+	for _, opt := range opts {
+		opt(ssp)
+	}
+	return ssp, nil
+}
+
+func (ssp *spanStatsProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	td.Spans = ssp.summarize(td.Spans)
+	return ssp.next.ProcessTraceData(ctx, td)
+}
+
+func (ssp *spanStatsProcessor) summarize(spans []*tracepb.Span) []*tracepb.Span {
+	siblings := make(map[siblingKey][]*tracepb.Span)
+	var order []siblingKey
+	for _, span := range spans {
+		if span == nil || len(span.ParentSpanId) == 0 {
+			continue
+		}
+		key := siblingKey{string(span.TraceId), string(span.ParentSpanId), span.Name.GetValue()}
+		if _, ok := siblings[key]; !ok {
+			order = append(order, key)
+		}
+		siblings[key] = append(siblings[key], span)
+	}
+
+	// summarizedInto maps the span IDs of the replaced siblings to the ID of
+	// their summary span.
+	summarizedInto := make(map[string][]byte)
+	for _, key := range order {
+		group := siblings[key]
+		// At least two spans are left to summarize once the extremes are
+		// kept.
+		if len(group) < ssp.minSiblings || len(group)-2*ssp.extremes < 2 {
+			continue
+		}
+		byDuration := make([]*tracepb.Span, len(group))
+		copy(byDuration, group)
+		sort.SliceStable(byDuration, func(i, j int) bool {
+			return spanDuration(byDuration[i]) < spanDuration(byDuration[j])
+		})
+		var totalMillis float64
+		for _, span := range group {
+			totalMillis += spanDurationMillis(span)
+		}
+		kept := make(map[*tracepb.Span]bool, 2*ssp.extremes)
+		for _, span := range byDuration[:ssp.extremes] {
+			kept[span] = true
+		}
+		for _, span := range byDuration[len(byDuration)-ssp.extremes:] {
+			kept[span] = true
+		}
+
+		var summary *tracepb.Span
+		var start, end time.Time
+		summarized := 0
+		for _, span := range group {
+			if kept[span] {
+				continue
+			}
+			spanStart, spanEnd := internal.TimestampToTime(span.StartTime), internal.TimestampToTime(span.EndTime)
+			if summary == nil {
+				summary, start, end = span, spanStart, spanEnd
+			} else {
+				summarizedInto[string(span.SpanId)] = summary.SpanId
+			}
+			if spanStart.Before(start) {
+				start = spanStart
+			}
+			if spanEnd.After(end) {
+				end = spanEnd
+			}
+			summarized++
+		}
+		summary.StartTime = internal.TimeToTimestamp(start)
+		summary.EndTime = internal.TimeToTimestamp(end)
+		setSpanAttribute(summary, summarizedCountAttributeKey, int64AttributeValue(int64(summarized)))
+		setSpanAttribute(summary, siblingCountAttributeKey, int64AttributeValue(int64(len(group))))
+		setSpanAttribute(summary, minDurationAttributeKey, doubleAttributeValue(spanDurationMillis(byDuration[0])))
+		setSpanAttribute(summary, maxDurationAttributeKey, doubleAttributeValue(spanDurationMillis(byDuration[len(byDuration)-1])))
+		setSpanAttribute(summary, meanDurationAttributeKey, doubleAttributeValue(totalMillis/float64(len(group))))
+	}
+	if len(summarizedInto) == 0 {
+		return spans
+	}
+
+	kept := make([]*tracepb.Span, 0, len(spans)-len(summarizedInto))
+	for _, span := range spans {
+		if span != nil {
+			if _, ok := summarizedInto[string(span.SpanId)]; ok {
+				continue
+			}
+			if parentSpanID, ok := summarizedInto[string(span.ParentSpanId)]; ok {
+				span.ParentSpanId = parentSpanID
+			}
+		}
+		kept = append(kept, span)
+	}
+	return kept
+}
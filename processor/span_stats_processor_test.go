@@ -0,0 +1,115 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+func TestNewSpanStatsProcessorInvalidMinSiblings(t *testing.T) {
+	if _, err := NewSpanStatsProcessor(&batchRecorder{}, 1); err != ErrInvalidMinSiblings {
+		t.Errorf("Got error %v, want %v", err, ErrInvalidMinSiblings)
+	}
+}
+
+func TestSpanStatsProcessor(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	appendNode := newPlanNodeSpan(1, 0, "Append", start, 20*time.Millisecond)
+	scans := []*tracepb.Span{
+		newPlanNodeSpan(2, 1, "Seq Scan", start.Add(time.Millisecond), 2*time.Millisecond),
+		newPlanNodeSpan(3, 1, "Seq Scan", start.Add(3*time.Millisecond), time.Millisecond),
+		newPlanNodeSpan(4, 1, "Seq Scan", start.Add(4*time.Millisecond), 3*time.Millisecond),
+		newPlanNodeSpan(5, 1, "Seq Scan", start.Add(7*time.Millisecond), 10*time.Millisecond),
+		newPlanNodeSpan(6, 1, "Seq Scan", start.Add(17*time.Millisecond), 2*time.Millisecond),
+	}
+	// A child of a summarized scan, and a lone sibling of another type.
+	filter := newPlanNodeSpan(7, 4, "Bitmap Index Scan", start.Add(4*time.Millisecond), time.Millisecond)
+	index := newPlanNodeSpan(8, 1, "Index Scan", start, time.Millisecond)
+	spans := append([]*tracepb.Span{appendNode}, scans...)
+	spans = append(spans, filter, index)
+
+	next := &batchRecorder{}
+	ssp, err := NewSpanStatsProcessor(next, 3)
+	if err != nil {
+		t.Fatalf("NewSpanStatsProcessor() error = %v", err)
+	}
+	if err := ssp.ProcessTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	// The fastest and the slowest scans are kept, the three others are
+	// summarized into the first of them.
+	got := next.batches[0].Spans
+	if len(got) != 6 {
+		t.Fatalf("Got %d spans, want 6", len(got))
+	}
+	summary := scans[0]
+	for key, want := range map[string]int64{summarizedCountAttributeKey: 3, siblingCountAttributeKey: 5} {
+		if got := spanAttribute(summary, key).GetIntValue(); got != want {
+			t.Errorf("Got %s %d, want %d", key, got, want)
+		}
+	}
+	for key, want := range map[string]float64{minDurationAttributeKey: 1, maxDurationAttributeKey: 10, meanDurationAttributeKey: 3.6} {
+		if got := spanAttribute(summary, key).GetDoubleValue(); got != want {
+			t.Errorf("Got %s %v, want %v", key, got, want)
+		}
+	}
+	if got := internal.TimestampToTime(summary.StartTime); !got.Equal(start.Add(time.Millisecond)) {
+		t.Errorf("Got start %v, want the earliest start of the summarized spans", got)
+	}
+	if got := internal.TimestampToTime(summary.EndTime); !got.Equal(start.Add(19 * time.Millisecond)) {
+		t.Errorf("Got end %v, want the latest end of the summarized spans", got)
+	}
+	if !bytes.Equal(filter.ParentSpanId, summary.SpanId) {
+		t.Error("Got the child of a summarized span not attached to the summary span")
+	}
+	for _, span := range []*tracepb.Span{scans[1], scans[3], index} {
+		if spanAttribute(span, siblingCountAttributeKey) != nil {
+			t.Errorf("Got sibling_count on the kept span %x", span.SpanId)
+		}
+	}
+}
+
+func TestSpanStatsProcessorTooFewSiblings(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	spans := []*tracepb.Span{
+		newPlanNodeSpan(1, 0, "Append", start, 10*time.Millisecond),
+		newPlanNodeSpan(2, 1, "Seq Scan", start, time.Millisecond),
+		newPlanNodeSpan(3, 1, "Seq Scan", start, 2*time.Millisecond),
+		newPlanNodeSpan(4, 1, "Seq Scan", start, 3*time.Millisecond),
+	}
+
+	next := &batchRecorder{}
+	// Only one scan would be left once the extremes are kept.
+	ssp, _ := NewSpanStatsProcessor(next, 3)
+	ssp.ProcessTraceData(context.Background(), data.TraceData{Spans: spans})
+	if got := len(next.batches[0].Spans); got != 4 {
+		t.Errorf("Got %d spans, want 4", got)
+	}
+
+	next = &batchRecorder{}
+	ssp, _ = NewSpanStatsProcessor(next, 3, WithSpanStatsExtremes(0))
+	ssp.ProcessTraceData(context.Background(), data.TraceData{Spans: spans})
+	if got := len(next.batches[0].Spans); got != 2 {
+		t.Errorf("Got %d spans without extremes, want 2", got)
+	}
+}
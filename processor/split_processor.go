@@ -0,0 +1,89 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// ErrInvalidMaxSpansPerBatch occurs when the batch size of a split processor
+// is less than 1.
+var ErrInvalidMaxSpansPerBatch = errors.New("invalid maximum number of spans per batch, it must be greater than zero")
+
+type splitProcessor struct {
+	next             TraceDataProcessor
+	maxSpansPerBatch int
+}
+
+var _ TraceDataProcessor = (*splitProcessor)(nil)
+
+// NewSplitProcessor creates a TraceDataProcessor that forwards the TraceData
+// to next in batches of at most maxSpansPerBatch spans, for the exporters that
+// reject large payloads. The batches keep the Node and Resource of the
+// TraceData. The spans of a trace are kept in the same batch, unless the trace
+// alone exceeds maxSpansPerBatch. All the batches are forwarded even if some
+// fail, and the errors are combined.
+func NewSplitProcessor(next TraceDataProcessor, maxSpansPerBatch int) (TraceDataProcessor, error) {
+	if maxSpansPerBatch < 1 {
+		return nil, ErrInvalidMaxSpansPerBatch
+	}
+	return &splitProcessor{next: next, maxSpansPerBatch: maxSpansPerBatch}, nil
+}
+
+func (sp *splitProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	if len(td.Spans) <= sp.maxSpansPerBatch {
+		return sp.next.ProcessTraceData(ctx, td)
+	}
+
+	var errs []error
+	for _, spans := range sp.split(td.Spans) {
+		batch := data.TraceData{Node: td.Node, Resource: td.Resource, Spans: spans}
+		if err := sp.next.ProcessTraceData(ctx, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// split groups spans by trace into batches of at most maxSpansPerBatch spans.
+// The traces are added to the current batch in order of first appearance, and
+// a trace that does not fit starts a new batch. A trace larger than a batch is
+// split on its own.
+func (sp *splitProcessor) split(spans []*tracepb.Span) [][]*tracepb.Span {
+	traces, order := spansByTraceID(spans)
+	var batches [][]*tracepb.Span
+	var batch []*tracepb.Span
+	for _, traceID := range order {
+		trace := traces[traceID]
+		if len(batch)+len(trace) > sp.maxSpansPerBatch && len(batch) > 0 {
+			batches = append(batches, batch)
+			batch = nil
+		}
+		for len(trace) > sp.maxSpansPerBatch {
+			batches = append(batches, trace[:sp.maxSpansPerBatch])
+			trace = trace[sp.maxSpansPerBatch:]
+		}
+		batch = append(batch, trace...)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
@@ -0,0 +1,103 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// batchRecorder records the TraceData it receives.
+type batchRecorder struct {
+	batches []data.TraceData
+}
+
+func (br *batchRecorder) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	br.batches = append(br.batches, td)
+	return nil
+}
+
+func TestNewSplitProcessorInvalidBatchSize(t *testing.T) {
+	for _, maxSpansPerBatch := range []int{0, -1} {
+		if _, err := NewSplitProcessor(&mockTraceDataProcessor{}, maxSpansPerBatch); err != ErrInvalidMaxSpansPerBatch {
+			t.Errorf("NewSplitProcessor(%d) error = %v, want %v", maxSpansPerBatch, err, ErrInvalidMaxSpansPerBatch)
+		}
+	}
+}
+
+func TestSplitProcessor(t *testing.T) {
+	// Traces of 2, 1, 2 and 5 spans, the first one interleaved with the second.
+	spans := []*tracepb.Span{
+		newQuerySpan(1, "a"), newQuerySpan(2, "b"), newQuerySpan(1, "a"),
+		newQuerySpan(3, "c"), newQuerySpan(3, "c"),
+		newQuerySpan(4, "d"), newQuerySpan(4, "d"), newQuerySpan(4, "d"), newQuerySpan(4, "d"), newQuerySpan(4, "d"),
+	}
+	node := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "orders"}}
+
+	br := &batchRecorder{}
+	sp, err := NewSplitProcessor(br, 3)
+	if err != nil {
+		t.Fatalf("NewSplitProcessor() error = %v", err)
+	}
+	if err := sp.ProcessTraceData(context.Background(), data.TraceData{Node: node, Spans: spans}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	var got [][]byte
+	for _, batch := range br.batches {
+		if batch.Node != node {
+			t.Errorf("Got node %v, want %v", batch.Node, node)
+		}
+		var traceIDs []byte
+		for _, span := range batch.Spans {
+			traceIDs = append(traceIDs, span.TraceId[0])
+		}
+		got = append(got, traceIDs)
+	}
+	want := [][]byte{{1, 1, 2}, {3, 3}, {4, 4, 4}, {4, 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got batches of trace IDs %v, want %v", got, want)
+	}
+}
+
+func TestSplitProcessorSmallTraceData(t *testing.T) {
+	br := &batchRecorder{}
+	sp, _ := NewSplitProcessor(br, 3)
+	td := data.TraceData{Spans: []*tracepb.Span{newQuerySpan(1, "a"), newQuerySpan(2, "b")}}
+	if err := sp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if len(br.batches) != 1 || !reflect.DeepEqual(br.batches[0], td) {
+		t.Errorf("Got batches %v, want the TraceData unchanged", br.batches)
+	}
+}
+
+func TestSplitProcessorErrors(t *testing.T) {
+	next := &mockTraceDataProcessor{MustFail: true}
+	sp, _ := NewSplitProcessor(next, 1)
+	td := data.TraceData{Spans: []*tracepb.Span{newQuerySpan(1, "a"), newQuerySpan(2, "b")}}
+	if err := sp.ProcessTraceData(context.Background(), td); err == nil {
+		t.Error("Wanted error got nil")
+	}
+	// The failure of a batch does not prevent the others.
+	if next.TotalSpans != 2 {
+		t.Errorf("Got %d spans forwarded, want 2", next.TotalSpans)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const (
+	tailSamplerProcessorName = "tail_sampler"
+
+	defaultTailSamplerMinDuration  = time.Second
+	defaultTailSamplerSamplingRate = 0.1
+)
+
+type tailSamplerProcessor struct {
+	next         TraceDataProcessor
+	minDuration  time.Duration
+	samplingRate float64
+}
+
+var _ TraceDataProcessor = (*tailSamplerProcessor)(nil)
+
+// TailSamplerOption is an option to NewTailSamplerProcessor.
+type TailSamplerOption func(*tailSamplerProcessor)
+
+// WithTailSamplerMinDuration sets the duration from which a trace is always
+// kept, 1s by default.
+func WithTailSamplerMinDuration(minDuration time.Duration) TailSamplerOption {
+	return func(tsp *tailSamplerProcessor) {
+		tsp.minDuration = minDuration
+	}
+}
+
+// WithTailSamplerSamplingRate sets the fraction, between 0 and 1, of the fast
+// and successful traces that are kept, 0.1 by default.
+func WithTailSamplerSamplingRate(samplingRate float64) TailSamplerOption {
+	return func(tsp *tailSamplerProcessor) {
+		tsp.samplingRate = math.Max(0, math.Min(1, samplingRate))
+	}
+}
+
+// NewTailSamplerProcessor creates a TraceDataProcessor that samples traces
+// according to their outcome: the traces whose root span has an error status
+// or lasts at least the minimum duration are always kept, and a fraction of
+// the others is. The decision is made per trace, so its spans are kept or
+// dropped together, and depends only on the trace ID for the other traces, so
+// it is the same for all the batches of a trace. The root span of a trace is
+// its first span whose parent is not part of the TraceData.
+func NewTailSamplerProcessor(next TraceDataProcessor, opts ...TailSamplerOption) TraceDataProcessor {
+	tsp := &tailSamplerProcessor{
+		next:         next,
+		minDuration:  defaultTailSamplerMinDuration,
+		samplingRate: defaultTailSamplerSamplingRate,
+	}
+	for _, opt := range opts {
+		opt(tsp)
+	}
+	return tsp
+}
+
+func (tsp *tailSamplerProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	traces, order := spansByTraceID(td.Spans)
+
+	sampled := make([]*tracepb.Span, 0, len(td.Spans))
+	for _, traceID := range order {
+		spans := traces[traceID]
+		if tsp.sample(traceID, spans) {
+			sampled = append(sampled, spans...)
+		}
+	}
+
+	if dropped := len(td.Spans) - len(sampled); dropped > 0 {
+		observability.RecordTraceProcessorMetrics(
+			observability.ContextWithProcessorName(ctx, tailSamplerProcessorName), dropped)
+	}
+	if len(sampled) == 0 {
+		return nil
+	}
+	td.Spans = sampled
+	return tsp.next.ProcessTraceData(ctx, td)
+}
+
+// sample reports whether the trace made of spans must be kept.
+func (tsp *tailSamplerProcessor) sample(traceID string, spans []*tracepb.Span) bool {
+	root := rootSpan(spans)
+	if root == nil {
+		return true
+	}
+	if root.Status != nil && root.Status.Code != 0 {
+		return true
+	}
+	if internal.TimestampToTime(root.EndTime).Sub(internal.TimestampToTime(root.StartTime)) >= tsp.minDuration {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(traceID))
+	return float64(h.Sum64())/float64(math.MaxUint64) < tsp.samplingRate
+}
+
+// rootSpan returns the first span of spans whose parent is not among spans,
+// or nil if there is none.
+func rootSpan(spans []*tracepb.Span) *tracepb.Span {
+	spanIDs := make(map[string]bool, len(spans))
+	for _, span := range spans {
+		if span != nil {
+			spanIDs[string(span.SpanId)] = true
+		}
+	}
+	for _, span := range spans {
+		if span != nil && !spanIDs[string(span.ParentSpanId)] {
+			return span
+		}
+	}
+	return nil
+}
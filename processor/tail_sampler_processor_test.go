@@ -0,0 +1,92 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// newTwoSpanTrace returns the root span, lasting duration, and a child span of
+// a trace.
+func newTwoSpanTrace(traceID byte, duration time.Duration, status *tracepb.Status) []*tracepb.Span {
+	root := newTimedSpan(time.Unix(1546300800, 0), duration)
+	root.TraceId = []byte{traceID, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	root.SpanId = []byte{traceID, 0, 0, 0, 0, 0, 0, 1}
+	root.Status = status
+	child := newTimedSpan(time.Unix(1546300800, 0), duration/2)
+	child.TraceId = root.TraceId
+	child.SpanId = []byte{traceID, 0, 0, 0, 0, 0, 0, 2}
+	child.ParentSpanId = root.SpanId
+	// The child comes first, as emitted by the PostgreSQL receiver.
+	return []*tracepb.Span{child, root}
+}
+
+func TestTailSamplerProcessor(t *testing.T) {
+	failed := newTwoSpanTrace(1, time.Millisecond, &tracepb.Status{Code: 13})
+	slow := newTwoSpanTrace(2, 2*time.Second, nil)
+	fast := newTwoSpanTrace(3, time.Millisecond, &tracepb.Status{Code: 0})
+
+	var spans []*tracepb.Span
+	for _, trace := range [][]*tracepb.Span{failed, slow, fast} {
+		spans = append(spans, trace...)
+	}
+
+	tests := []struct {
+		name         string
+		samplingRate float64
+		wantSpans    int
+	}{
+		{name: "drop fast traces", samplingRate: 0, wantSpans: 4},
+		{name: "keep fast traces", samplingRate: 1, wantSpans: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &mockTraceDataProcessor{}
+			tsp := NewTailSamplerProcessor(next, WithTailSamplerMinDuration(time.Second), WithTailSamplerSamplingRate(tt.samplingRate))
+			if err := tsp.ProcessTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+				t.Fatalf("Wanted nil got error %v", err)
+			}
+			if next.TotalSpans != tt.wantSpans {
+				t.Errorf("Got %d spans forwarded, want %d", next.TotalSpans, tt.wantSpans)
+			}
+		})
+	}
+}
+
+func TestTailSamplerProcessorSamplingRate(t *testing.T) {
+	next := &mockTraceDataProcessor{}
+	tsp := NewTailSamplerProcessor(next, WithTailSamplerSamplingRate(0.5))
+
+	const traces = 1000
+	var spans []*tracepb.Span
+	for i := 0; i < traces; i++ {
+		root := newTimedSpan(time.Unix(1546300800, 0), time.Millisecond)
+		root.TraceId = []byte{byte(i), byte(i >> 8), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+		root.SpanId = []byte{1, 0, 0, 0, 0, 0, 0, 0}
+		spans = append(spans, root)
+	}
+	if err := tsp.ProcessTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if next.TotalSpans < 400 || next.TotalSpans > 600 {
+		t.Errorf("Got %d of %d traces sampled, want about half", next.TotalSpans, traces)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const (
+	timelineNormalizerProcessorName = "timeline_normalizer"
+
+	timelineNormalizedAttributeKey = "timeline_normalized"
+)
+
+type timelineNormalizer struct {
+	next TraceDataProcessor
+}
+
+var _ TraceDataProcessor = (*timelineNormalizer)(nil)
+
+// NewTimelineNormalizer creates a TraceDataProcessor that lays out the
+// children of every span one after the other over the whole interval of
+// their parent, each getting a share of it proportional to its duration, in
+// the order of their start times. The timings of the plan nodes are derived
+// from per loop averages, so sibling spans often overlap or leave gaps that
+// make confusing waterfall charts. The reported timings are altered, use it
+// for presentation only: the moved spans get a timeline_normalized attribute
+// and are counted in the modified spans metric. The root spans, whose parent
+// is not in the trace, are kept as they are.
+func NewTimelineNormalizer(next TraceDataProcessor) TraceDataProcessor {
+	return &timelineNormalizer{next: next}
+}
+
+func (tn *timelineNormalizer) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	modified := 0
+	traces, order := spansByTraceID(td.Spans)
+	for _, traceID := range order {
+		modified += normalizeTimeline(traces[traceID])
+	}
+	if modified > 0 {
+		observability.RecordTraceProcessorModifiedSpans(
+			observability.ContextWithProcessorName(ctx, timelineNormalizerProcessorName), modified)
+	}
+	return tn.next.ProcessTraceData(ctx, td)
+}
+
+// normalizeTimeline lays out the children of the spans of a trace over the
+// interval of their parent, from the root spans down, and returns the number
+// of spans moved.
+func normalizeTimeline(spans []*tracepb.Span) int {
+	children := make(map[string][]*tracepb.Span)
+	for _, span := range spans {
+		if span != nil && len(span.ParentSpanId) > 0 {
+			children[string(span.ParentSpanId)] = append(children[string(span.ParentSpanId)], span)
+		}
+	}
+
+	modified := 0
+	parents := rootSpans(spans)
+	// visited guards against cycles in malformed traces.
+	visited := make(map[*tracepb.Span]bool)
+	for len(parents) > 0 {
+		parent := parents[0]
+		parents = parents[1:]
+		if visited[parent] {
+			continue
+		}
+		visited[parent] = true
+		siblings := children[string(parent.SpanId)]
+		modified += layOutSiblings(parent, siblings)
+		parents = append(parents, siblings...)
+	}
+	return modified
+}
+
+// layOutSiblings lays out siblings, the children of parent, one after the
+// other over the interval of parent, and returns the number of siblings
+// moved. The siblings are left as they are when none of them has a duration.
+func layOutSiblings(parent *tracepb.Span, siblings []*tracepb.Span) int {
+	var total time.Duration
+	for _, span := range siblings {
+		if duration := spanDuration(span); duration > 0 {
+			total += duration
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+
+	ordered := make([]*tracepb.Span, len(siblings))
+	copy(ordered, siblings)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return internal.TimestampToTime(ordered[i].StartTime).Before(internal.TimestampToTime(ordered[j].StartTime))
+	})
+
+	parentStart := internal.TimestampToTime(parent.StartTime)
+	parentDuration := spanDuration(parent)
+	modified := 0
+	var elapsed time.Duration
+	for _, span := range ordered {
+		duration := spanDuration(span)
+		if duration < 0 {
+			duration = 0
+		}
+		start := parentStart.Add(time.Duration(float64(parentDuration) * float64(elapsed) / float64(total)))
+		elapsed += duration
+		end := parentStart.Add(time.Duration(float64(parentDuration) * float64(elapsed) / float64(total)))
+		if start.Equal(internal.TimestampToTime(span.StartTime)) && end.Equal(internal.TimestampToTime(span.EndTime)) {
+			continue
+		}
+		span.StartTime = internal.TimeToTimestamp(start)
+		span.EndTime = internal.TimeToTimestamp(end)
+		setSpanAttribute(span, timelineNormalizedAttributeKey, boolAttributeValue(true))
+		modified++
+	}
+	return modified
+}
@@ -0,0 +1,89 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+func TestTimelineNormalizer(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	// Nested Loop (1), 10ms
+	//   Index Scan (3), 6ms from 1ms, overlapping Seq Scan (2)
+	//     Bitmap Index Scan (4), 3ms
+	//   Seq Scan (2), 2ms
+	spans := []*tracepb.Span{
+		newPlanNodeSpan(1, 0, "Nested Loop", start, 10*time.Millisecond),
+		newPlanNodeSpan(3, 1, "Index Scan", start.Add(time.Millisecond), 6*time.Millisecond),
+		newPlanNodeSpan(4, 3, "Bitmap Index Scan", start.Add(3*time.Millisecond), 3*time.Millisecond),
+		newPlanNodeSpan(2, 1, "Seq Scan", start, 2*time.Millisecond),
+	}
+
+	next := &batchRecorder{}
+	tn := NewTimelineNormalizer(next)
+	if err := tn.ProcessTraceData(context.Background(), data.TraceData{Spans: append(spans, nil)}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	// The siblings share the 10ms of their parent in proportion to their
+	// durations, in the order of their start times, and the lone child fills
+	// its parent.
+	want := map[byte]struct {
+		start, end time.Duration
+		normalized bool
+	}{
+		1: {0, 10 * time.Millisecond, false},
+		2: {0, 2500 * time.Microsecond, true},
+		3: {2500 * time.Microsecond, 10 * time.Millisecond, true},
+		4: {2500 * time.Microsecond, 10 * time.Millisecond, true},
+	}
+	for _, span := range spans {
+		w := want[span.SpanId[0]]
+		gotStart := internal.TimestampToTime(span.StartTime).Sub(start)
+		gotEnd := internal.TimestampToTime(span.EndTime).Sub(start)
+		if gotStart != w.start || gotEnd != w.end {
+			t.Errorf("Span %d: got %v to %v, want %v to %v", span.SpanId[0], gotStart, gotEnd, w.start, w.end)
+		}
+		if got := spanAttribute(span, timelineNormalizedAttributeKey).GetBoolValue(); got != w.normalized {
+			t.Errorf("Span %d: got timeline_normalized %v, want %v", span.SpanId[0], got, w.normalized)
+		}
+	}
+}
+
+func TestTimelineNormalizerZeroDurations(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	// Children that never executed have no duration to share the parent.
+	spans := []*tracepb.Span{
+		newPlanNodeSpan(1, 0, "Append", start, 10*time.Millisecond),
+		newPlanNodeSpan(2, 1, "Seq Scan", start.Add(time.Millisecond), 0),
+		newPlanNodeSpan(3, 1, "Seq Scan", start.Add(2*time.Millisecond), 0),
+	}
+
+	tn := NewTimelineNormalizer(&batchRecorder{})
+	if err := tn.ProcessTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	for _, span := range spans[1:] {
+		if spanAttribute(span, timelineNormalizedAttributeKey) != nil {
+			t.Errorf("Span %d: got it normalized, want it unchanged", span.SpanId[0])
+		}
+	}
+}
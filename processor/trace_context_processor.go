@@ -0,0 +1,65 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+const (
+	traceparentAttributeKey = "traceparent"
+	sampledAttributeKey     = "sampled"
+)
+
+type traceContextProcessor struct {
+	next TraceDataProcessor
+}
+
+var _ TraceDataProcessor = (*traceContextProcessor)(nil)
+
+// NewTraceContextProcessor creates a TraceDataProcessor that sets a
+// traceparent attribute on the root spans, the spans whose parent is not in
+// the batch, with their IDs in the W3C Trace Context format, e.g.
+// "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", to correlate
+// them with the tools of that format. The spans with invalid IDs, see
+// NewSpanIDValidatorProcessor, are left untouched. The spans are sampled
+// unless they have a sampled attribute set to false.
+func NewTraceContextProcessor(next TraceDataProcessor) TraceDataProcessor {
+	return &traceContextProcessor{next: next}
+}
+
+func (tcp *traceContextProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	for _, root := range rootSpans(td.Spans) {
+		if isValidID(root.TraceId, traceIDLength) && isValidID(root.SpanId, spanIDLength) {
+			setSpanAttribute(root, traceparentAttributeKey, stringAttributeValue(traceparent(root)))
+		}
+	}
+	return tcp.next.ProcessTraceData(ctx, td)
+}
+
+// traceparent returns the traceparent header of the W3C Trace Context
+// referring to span, as sampled since the span was recorded, unless its
+// sampled attribute tells otherwise.
+func traceparent(span *tracepb.Span) string {
+	flags := "01"
+	if sampled := spanAttribute(span, sampledAttributeKey); sampled != nil && !sampled.GetBoolValue() {
+		flags = "00"
+	}
+	return fmt.Sprintf("00-%x-%x-%s", span.TraceId, span.SpanId, flags)
+}
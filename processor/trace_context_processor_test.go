@@ -0,0 +1,66 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestTraceContextProcessor(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	root := newPlanNodeSpan(0x0a, 0, "CloudSQLQuery", start, time.Millisecond)
+	// The query is the child of an application span, which is not in the
+	// batch.
+	root.ParentSpanId = []byte{0xff, 0, 0, 0, 0, 0, 0, 0}
+	child := newPlanNodeSpan(0x0b, 0x0a, "Seq Scan", start, time.Millisecond)
+	invalid := newPlanNodeSpan(0x0c, 0, "CloudSQLQuery", start, time.Millisecond)
+	invalid.TraceId = []byte{1, 2, 3}
+
+	next := &batchRecorder{}
+	tcp := NewTraceContextProcessor(next)
+	if err := tcp.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{root, child, invalid, nil}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+
+	want := "00-01000000000000000000000000000000-0a00000000000000-01"
+	if got := spanAttribute(root, traceparentAttributeKey).GetStringValue().GetValue(); got != want {
+		t.Errorf("Got traceparent %q, want %q", got, want)
+	}
+	if spanAttribute(child, traceparentAttributeKey) != nil {
+		t.Error("Got traceparent on a child span")
+	}
+	if spanAttribute(invalid, traceparentAttributeKey) != nil {
+		t.Error("Got traceparent on a span with an invalid trace ID")
+	}
+}
+
+func TestTraceContextProcessorNotSampled(t *testing.T) {
+	root := newPlanNodeSpan(0x0a, 0, "CloudSQLQuery", time.Unix(1546300800, 0), time.Millisecond)
+	setSpanAttribute(root, sampledAttributeKey, boolAttributeValue(false))
+
+	tcp := NewTraceContextProcessor(&batchRecorder{})
+	if err := tcp.ProcessTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{root}}); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	want := "00-01000000000000000000000000000000-0a00000000000000-00"
+	if got := spanAttribute(root, traceparentAttributeKey).GetStringValue().GetValue(); got != want {
+		t.Errorf("Got traceparent %q, want %q", got, want)
+	}
+}
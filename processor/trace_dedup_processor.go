@@ -0,0 +1,151 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+const traceDedupProcessorName = "trace_dedup"
+
+// ErrInvalidMaxTraces occurs when the maximum number of trace IDs remembered
+// by a trace dedup processor is not positive.
+var ErrInvalidMaxTraces = errors.New("invalid maximum number of traces, it must be greater than zero")
+
+type traceDedupEntry struct {
+	traceID string
+	seenAt  time.Time
+}
+
+type traceDedupProcessor struct {
+	next      TraceDataProcessor
+	window    time.Duration
+	maxTraces int
+	now       func() time.Time
+
+	mu sync.Mutex
+	// lru holds the traceDedupEntry of the most recently seen traces at its
+	// front.
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+var _ TraceDataProcessor = (*traceDedupProcessor)(nil)
+
+// NewTraceDedupProcessor creates a TraceDataProcessor that forwards the spans
+// of a trace ID in at most one TraceData per window, and drops the spans of
+// the trace in the following TraceData, counting them in the dropped spans
+// metric. With the deterministic IDs of the PostgreSQL receiver, the rows
+// pulled again, e.g. after a failed acknowledgement, are then exported once.
+// A trace ID is remembered only once next accepted its spans, so the
+// TraceData retried after next failed are forwarded again. At most maxTraces
+// trace IDs are remembered, the least recently seen ones being evicted first.
+// The queries linked to the trace of the application that ran them share its
+// trace ID, only the first of them within the window is forwarded.
+func NewTraceDedupProcessor(next TraceDataProcessor, window time.Duration, maxTraces int) (TraceDataProcessor, error) {
+	if maxTraces < 1 {
+		return nil, ErrInvalidMaxTraces
+	}
+	return &traceDedupProcessor{
+		next:      next,
+		window:    window,
+		maxTraces: maxTraces,
+		now:       time.Now,
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+	}, nil
+}
+
+func (tdp *traceDedupProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	// The spans of the traces first seen in td are all forwarded.
+	duplicates := make(map[string]bool)
+	var forwarded []string
+	tdp.mu.Lock()
+	now := tdp.now()
+	for _, span := range td.Spans {
+		if span == nil {
+			continue
+		}
+		traceID := string(span.TraceId)
+		if _, ok := duplicates[traceID]; !ok {
+			duplicates[traceID] = tdp.seen(traceID, now)
+			if !duplicates[traceID] {
+				forwarded = append(forwarded, traceID)
+			}
+		}
+	}
+	tdp.mu.Unlock()
+
+	kept := make([]*tracepb.Span, 0, len(td.Spans))
+	for _, span := range td.Spans {
+		if span == nil || !duplicates[string(span.TraceId)] {
+			kept = append(kept, span)
+		}
+	}
+	if dropped := len(td.Spans) - len(kept); dropped > 0 {
+		observability.RecordTraceProcessorMetrics(
+			observability.ContextWithProcessorName(ctx, traceDedupProcessorName), dropped)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	td.Spans = kept
+	if err := tdp.next.ProcessTraceData(ctx, td); err != nil {
+		return err
+	}
+
+	tdp.mu.Lock()
+	for _, traceID := range forwarded {
+		tdp.record(traceID, now)
+	}
+	tdp.mu.Unlock()
+	return nil
+}
+
+// seen reports whether traceID was already seen within the window. It must
+// be called with the lock held.
+func (tdp *traceDedupProcessor) seen(traceID string, now time.Time) bool {
+	elem, ok := tdp.entries[traceID]
+	if !ok {
+		return false
+	}
+	tdp.lru.MoveToFront(elem)
+	return now.Sub(elem.Value.(*traceDedupEntry).seenAt) < tdp.window
+}
+
+// record remembers that traceID was forwarded at now, restarting its window
+// when it expired. It must be called with the lock held.
+func (tdp *traceDedupProcessor) record(traceID string, now time.Time) {
+	if elem, ok := tdp.entries[traceID]; ok {
+		tdp.lru.MoveToFront(elem)
+		elem.Value.(*traceDedupEntry).seenAt = now
+		return
+	}
+
+	tdp.entries[traceID] = tdp.lru.PushFront(&traceDedupEntry{traceID: traceID, seenAt: now})
+	for tdp.lru.Len() > tdp.maxTraces {
+		oldest := tdp.lru.Back()
+		tdp.lru.Remove(oldest)
+		delete(tdp.entries, oldest.Value.(*traceDedupEntry).traceID)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func TestNewTraceDedupProcessorInvalidMaxTraces(t *testing.T) {
+	for _, maxTraces := range []int{0, -1} {
+		if _, err := NewTraceDedupProcessor(&mockTraceDataProcessor{}, time.Minute, maxTraces); err != ErrInvalidMaxTraces {
+			t.Errorf("NewTraceDedupProcessor(%d) error = %v, want %v", maxTraces, err, ErrInvalidMaxTraces)
+		}
+	}
+}
+
+func TestTraceDedupProcessor(t *testing.T) {
+	next := &mockTraceDataProcessor{}
+	p, err := NewTraceDedupProcessor(next, time.Minute, 2)
+	if err != nil {
+		t.Fatalf("NewTraceDedupProcessor() error = %v", err)
+	}
+	tdp := p.(*traceDedupProcessor)
+	now := time.Unix(1000, 0)
+	tdp.now = func() time.Time { return now }
+
+	// A trace of two spans, and two single span traces.
+	trace1 := []*tracepb.Span{newQuerySpan(1, "select 1"), newQuerySpan(1, "select 1")}
+	trace2 := newQuerySpan(2, "select 2")
+	trace3 := newQuerySpan(3, "select 3")
+	process := func(spans ...*tracepb.Span) {
+		if err := tdp.ProcessTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+			t.Fatalf("Wanted nil got error %v", err)
+		}
+	}
+
+	// All the spans of a trace seen for the first time are forwarded, and
+	// so are the nil spans.
+	process(trace1[0], trace1[1], trace2, nil)
+	if next.TotalSpans != 4 {
+		t.Fatalf("Wanted 4 spans got %d", next.TotalSpans)
+	}
+
+	// The traces pulled again are dropped.
+	process(trace1...)
+	process(trace2)
+	if next.TotalSpans != 4 {
+		t.Fatalf("Wanted 4 spans got %d", next.TotalSpans)
+	}
+
+	// trace3 evicts trace1, the least recently seen trace.
+	process(trace3)
+	process(trace1...)
+	if next.TotalSpans != 7 {
+		t.Fatalf("Wanted 7 spans got %d", next.TotalSpans)
+	}
+
+	// Once the window is over the trace is forwarded again.
+	now = now.Add(time.Minute)
+	process(trace3)
+	if next.TotalSpans != 8 {
+		t.Fatalf("Wanted 8 spans got %d", next.TotalSpans)
+	}
+}
+
+func TestTraceDedupProcessorFailingNext(t *testing.T) {
+	next := &mockTraceDataProcessor{MustFail: true}
+	tdp, err := NewTraceDedupProcessor(next, time.Minute, 2)
+	if err != nil {
+		t.Fatalf("NewTraceDedupProcessor() error = %v", err)
+	}
+	td := data.TraceData{Spans: []*tracepb.Span{newQuerySpan(1, "select 1")}}
+
+	if err := tdp.ProcessTraceData(context.Background(), td); err == nil {
+		t.Fatal("Wanted error got nil")
+	}
+
+	// The retry of the failed TraceData is forwarded, next counts the spans
+	// of both.
+	next.MustFail = false
+	if err := tdp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if next.TotalSpans != 2 {
+		t.Fatalf("Wanted 2 spans got %d", next.TotalSpans)
+	}
+
+	// Once forwarded, the trace is dropped.
+	if err := tdp.ProcessTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if next.TotalSpans != 2 {
+		t.Fatalf("Wanted 2 spans got %d", next.TotalSpans)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpplanreceiver receives PostgreSQL execution plans pushed over
+// HTTP, for example from an application hook, and converts them to spans the
+// same way the PostgreSQL receiver does.
+package httpplanreceiver
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+	"github.com/census-instrumentation/opencensus-service/processor"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/postgresreceiver"
+)
+
+const (
+	defaultAddress     = ":8642"
+	defaultMaxBodySize = 10 << 20
+
+	// SharedSecretHeader is the HTTP header carrying the shared secret when
+	// the receiver is configured with one.
+	SharedSecretHeader = "X-Plan-Receiver-Secret"
+
+	traceSource = "HTTPPlan"
+)
+
+// Config is the configuration of the HTTP plan receiver.
+type Config struct {
+	// The address onto which the HTTP server will be bound.
+	Address string `mapstructure:"address"`
+	// The maximum size in bytes of a request body.
+	MaxBodySize int64 `mapstructure:"max_body_size"`
+	// When set, requests must carry this value in the SharedSecretHeader.
+	SharedSecret string `mapstructure:"shared_secret"`
+}
+
+// HTTPPlanReceiver accepts JSON execution plans, either as a JSON array or as
+// newline-delimited JSON, and forwards each of them as a data.TraceData.
+type HTTPPlanReceiver struct {
+	// mu protects the fields of this struct
+	mu sync.Mutex
+
+	config        Config
+	nextProcessor processor.TraceDataProcessor
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	server    *http.Server
+}
+
+var _ receiver.TraceReceiver = (*HTTPPlanReceiver)(nil)
+var _ http.Handler = (*HTTPPlanReceiver)(nil)
+
+var (
+	errAlreadyStarted = errors.New("already started")
+	errAlreadyStopped = errors.New("already stopped")
+)
+
+// New creates a new httpplanreceiver.HTTPPlanReceiver reference.
+func New(config *Config) (*HTTPPlanReceiver, error) {
+	if config == nil {
+		return nil, errors.New("nil HTTP plan receiver config")
+	}
+	hpr := &HTTPPlanReceiver{config: *config}
+	if hpr.config.Address == "" {
+		hpr.config.Address = defaultAddress
+	}
+	if hpr.config.MaxBodySize <= 0 {
+		hpr.config.MaxBodySize = defaultMaxBodySize
+	}
+	return hpr, nil
+}
+
+// TraceSource returns the name of the trace data source.
+func (hpr *HTTPPlanReceiver) TraceSource() string {
+	return traceSource
+}
+
+// Address returns the address onto which the receiver's HTTP server is bound,
+// the default one if none is configured.
+func (hpr *HTTPPlanReceiver) Address() string {
+	return hpr.config.Address
+}
+
+// StartTraceReception spins up the receiver's HTTP server and makes the receiver start its processing.
+func (hpr *HTTPPlanReceiver) StartTraceReception(ctx context.Context, nextProcessor processor.TraceDataProcessor) error {
+	hpr.mu.Lock()
+	defer hpr.mu.Unlock()
+
+	var err = errAlreadyStarted
+
+	hpr.startOnce.Do(func() {
+		ln, lerr := net.Listen("tcp", hpr.config.Address)
+		if lerr != nil {
+			err = lerr
+			return
+		}
+
+		server := &http.Server{Handler: hpr}
+		go func() {
+			_ = server.Serve(ln)
+		}()
+
+		hpr.nextProcessor = nextProcessor
+		hpr.server = server
+
+		err = nil
+	})
+
+	return err
+}
+
+// StopTraceReception tells the receiver that should stop reception,
+// giving it a chance to perform any necessary clean-up and shutting down
+// its HTTP server.
+func (hpr *HTTPPlanReceiver) StopTraceReception(ctx context.Context) error {
+	var err = errAlreadyStopped
+	hpr.stopOnce.Do(func() {
+		err = hpr.server.Close()
+	})
+	return err
+}
+
+// itemError reports the failure to parse or process one of the plans of a
+// request.
+type itemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+type response struct {
+	Accepted int         `json:"accepted"`
+	Errors   []itemError `json:"errors,omitempty"`
+}
+
+func (hpr *HTTPPlanReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if secret := hpr.config.SharedSecret; secret != "" {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(SharedSecretHeader)), []byte(secret)) != 1 {
+			http.Error(w, "invalid shared secret", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// Read one byte past the limit to tell a body of exactly MaxBodySize
+	// bytes from a larger one.
+	var body bytes.Buffer
+	_, err := body.ReadFrom(io.LimitReader(r.Body, hpr.config.MaxBodySize+1))
+	_ = r.Body.Close()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read the request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if int64(body.Len()) > hpr.config.MaxBodySize {
+		http.Error(w, fmt.Sprintf("request body larger than %d bytes", hpr.config.MaxBodySize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	plans, err := splitPlans(body.Bytes())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := observability.ContextWithReceiverName(context.Background(), traceSource)
+	var resp response
+	receivedSpans, droppedSpans := 0, 0
+	downstreamFailed := false
+	for i, plan := range plans {
+		spans, err := postgresreceiver.ParseExecutionPlan(plan)
+		if err != nil {
+			resp.Errors = append(resp.Errors, itemError{Index: i, Error: err.Error()})
+			continue
+		}
+		if len(spans) == 0 {
+			// The plan was not sampled by the application.
+			resp.Accepted++
+			continue
+		}
+		td := data.TraceData{
+			Node: &commonpb.Node{
+				Identifier: &commonpb.ProcessIdentifier{
+					HostName: "PostgreSQL",
+					Pid:      uint32(os.Getpid()),
+				},
+			},
+			Spans: spans,
+		}
+		if err := hpr.nextProcessor.ProcessTraceData(ctx, td); err != nil {
+			resp.Errors = append(resp.Errors, itemError{Index: i, Error: fmt.Sprintf("failed to process the plan: %v", err)})
+			droppedSpans += len(spans)
+			downstreamFailed = true
+			continue
+		}
+		receivedSpans += len(spans)
+		resp.Accepted++
+	}
+	observability.RecordTraceReceiverMetrics(ctx, receivedSpans, droppedSpans)
+
+	// Nothing was accepted: the request is invalid unless the pipeline failed,
+	// in which case it can be retried.
+	status := http.StatusOK
+	if resp.Accepted == 0 && len(resp.Errors) > 0 {
+		status = http.StatusBadRequest
+		if downstreamFailed {
+			status = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// splitPlans splits a request body holding either a JSON array of plans or
+// newline-delimited JSON plans into the individual plans.
+func splitPlans(body []byte) ([]json.RawMessage, error) {
+	blob := bytes.TrimSpace(body)
+
+	var plans []json.RawMessage
+	if len(blob) > 0 && blob[0] == '[' {
+		if err := json.Unmarshal(blob, &plans); err != nil {
+			return nil, fmt.Errorf("invalid JSON array of plans: %v", err)
+		}
+		return plans, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	for {
+		var plan json.RawMessage
+		if err := dec.Decode(&plan); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid newline-delimited JSON plans: %v", err)
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
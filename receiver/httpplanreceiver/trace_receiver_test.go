@@ -0,0 +1,191 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpplanreceiver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+const plan = `{"start timestamp": 1546300800.5, "duration": 0.003, "Query Text": "select 1", "username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres", "Plan": {"Node Type": "Result", "Actual Startup Time": 0.001, "Actual Total Time": 0.002, "Actual Rows": 1}}`
+
+func newTestReceiver(t *testing.T, config *Config) (*HTTPPlanReceiver, *exportertest.SinkTraceExporter) {
+	hpr, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create receiver: %v", err)
+	}
+	sink := new(exportertest.SinkTraceExporter)
+	hpr.nextProcessor = sink
+	return hpr, sink
+}
+
+func TestAddress(t *testing.T) {
+	hpr, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := hpr.Address(); got != defaultAddress {
+		t.Errorf("Got address %q, want the default %q", got, defaultAddress)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantAccepted int
+		wantErrors   []int
+	}{
+		{
+			name:         "newline-delimited",
+			body:         plan + "\n" + plan + "\n",
+			wantStatus:   http.StatusOK,
+			wantAccepted: 2,
+		},
+		{
+			name:         "array with a malformed plan",
+			body:         "[" + plan + `, {"Plan": 1}, ` + plan + "]",
+			wantStatus:   http.StatusOK,
+			wantAccepted: 2,
+			wantErrors:   []int{1},
+		},
+		{
+			name:       "only malformed plans",
+			body:       `{"duration": "slow"}`,
+			wantStatus: http.StatusBadRequest,
+			wantErrors: []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hpr, sink := newTestReceiver(t, &Config{})
+			w := httptest.NewRecorder()
+			hpr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			var resp response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to unmarshal response %q: %v", w.Body.String(), err)
+			}
+			if resp.Accepted != tt.wantAccepted {
+				t.Errorf("Got %d accepted plans, want %d", resp.Accepted, tt.wantAccepted)
+			}
+			if len(resp.Errors) != len(tt.wantErrors) {
+				t.Fatalf("Got errors %v, want errors for items %v", resp.Errors, tt.wantErrors)
+			}
+			for i, itemErr := range resp.Errors {
+				if itemErr.Index != tt.wantErrors[i] {
+					t.Errorf("Got error for item %d, want %d", itemErr.Index, tt.wantErrors[i])
+				}
+			}
+			if got := len(sink.AllTraces()); got != tt.wantAccepted {
+				t.Errorf("Got %d traces, want %d", got, tt.wantAccepted)
+			}
+		})
+	}
+}
+
+func TestServeHTTPSharedSecret(t *testing.T) {
+	hpr, sink := newTestReceiver(t, &Config{SharedSecret: "s3cr3t"})
+
+	w := httptest.NewRecorder()
+	hpr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(plan)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Got status %d without secret, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(plan))
+	req.Header.Set(SharedSecretHeader, "s3cr3t")
+	hpr.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Got status %d with secret, want %d", w.Code, http.StatusOK)
+	}
+	if got := len(sink.AllTraces()); got != 1 {
+		t.Errorf("Got %d traces, want 1", got)
+	}
+}
+
+func TestServeHTTPMaxBodySize(t *testing.T) {
+	hpr, sink := newTestReceiver(t, &Config{MaxBodySize: 16})
+
+	w := httptest.NewRecorder()
+	hpr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(plan)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Got status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if got := len(sink.AllTraces()); got != 0 {
+		t.Errorf("Got %d traces, want 0", got)
+	}
+
+	// A body of exactly the maximum size is accepted.
+	hpr, sink = newTestReceiver(t, &Config{MaxBodySize: int64(len(plan))})
+	w = httptest.NewRecorder()
+	hpr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(plan)))
+	if w.Code != http.StatusOK {
+		t.Errorf("Got status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+// failingProcessor fails every other trace, starting with the first one.
+type failingProcessor struct {
+	calls int
+}
+
+func (fp *failingProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	fp.calls++
+	if fp.calls%2 == 1 {
+		return errors.New("queue full")
+	}
+	return nil
+}
+
+func TestServeHTTPDownstreamFailure(t *testing.T) {
+	hpr, _ := newTestReceiver(t, &Config{})
+	hpr.nextProcessor = &failingProcessor{}
+
+	w := httptest.NewRecorder()
+	hpr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(plan+"\n"+plan)))
+	if w.Code != http.StatusOK {
+		t.Errorf("Got status %d, want %d", w.Code, http.StatusOK)
+	}
+	var resp response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response %q: %v", w.Body.String(), err)
+	}
+	if resp.Accepted != 1 || len(resp.Errors) != 1 || resp.Errors[0].Index != 0 {
+		t.Errorf("Got response %+v, want the first plan rejected and the second one accepted", resp)
+	}
+
+	// When the pipeline rejects everything, the client should retry.
+	hpr.nextProcessor = &failingProcessor{}
+	w = httptest.NewRecorder()
+	hpr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(plan)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Got status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
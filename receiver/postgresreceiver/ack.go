@@ -0,0 +1,49 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+)
+
+// rowAcks are the rows of a poll to acknowledge, see Config.AckCommand.
+type rowAcks struct {
+	// counters are the counter columns of the rows.
+	counters []int
+	// pushFailed is true when the next processor rejected traces of the
+	// poll, whose rows must then be pulled again.
+	pushFailed bool
+}
+
+// ackRows runs ackCommand for the rows of acks in tx and commits it, unless
+// the next processor rejected traces of the poll, in which case tx is left to
+// be rolled back.
+func (pgr *PostgresReceiver) ackRows(ctx context.Context, conn *connection, tx pollTx, ackCommand string, acks *rowAcks) {
+	if acks.pushFailed {
+		conn.logger.Printf("Rolling back the poll of service %q, its rows are pulled again by the next poll", conn.config.ServiceName)
+		return
+	}
+	for _, counter := range acks.counters {
+		if _, err := tx.ExecContext(ctx, ackCommand, counter); err != nil {
+			conn.logger.Printf("Acknowledging row %d failed for service %q: %v", counter, conn.config.ServiceName, err)
+			pgr.handleError(OpAck, conn.config.ServiceName, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		conn.logger.Printf("Committing the acknowledgements failed for service %q: %v", conn.config.ServiceName, err)
+		pgr.handleError(OpAck, conn.config.ServiceName, err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProcessExecutionPlanAck(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	queueFull := errors.New("queue full")
+	connReset := errors.New("connection reset")
+	tests := []struct {
+		name           string
+		pushErr        error
+		ackErr         error
+		wantAcked      []interface{}
+		wantCommitted  bool
+		wantRolledBack bool
+	}{
+		{name: "exported", wantAcked: []interface{}{1, 2, 3}, wantCommitted: true},
+		{name: "export failed", pushErr: queueFull, wantRolledBack: true},
+		{name: "ack failed", ackErr: connReset, wantRolledBack: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The invalid plan is acknowledged too.
+			tx := &fakePollTx{plans: []string{plan, "{not json", plan}, ackErr: tt.ackErr}
+			pgr := &PostgresReceiver{
+				config:      Config{AckCommand: "delete from plans where counter = $1"},
+				pullCommand: "select * from google_trace()",
+				parser:      defaultPlanParser,
+			}
+			conn := &connection{querier: &fakeTxQuerier{tx: tx}}
+			if tt.pushErr != nil {
+				pgr.processExecutionPlan(context.Background(), conn, failingProcessor{tt.pushErr})
+			} else {
+				pgr.processExecutionPlan(context.Background(), conn, &recordingProcessor{})
+			}
+
+			if !reflect.DeepEqual(tx.acked, tt.wantAcked) {
+				t.Errorf("Got acknowledged rows %v, want %v", tx.acked, tt.wantAcked)
+			}
+			if tx.committed != tt.wantCommitted || tx.rolledBack != tt.wantRolledBack {
+				t.Errorf("Got committed %v and rolled back %v, want %v and %v", tx.committed, tx.rolledBack, tt.wantCommitted, tt.wantRolledBack)
+			}
+		})
+	}
+}
+
+func TestProcessExecutionPlanAckWithoutTransactions(t *testing.T) {
+	fq := &fakeQuerier{plans: []string{resultPlan}}
+	pgr := &PostgresReceiver{
+		config:      Config{AckCommand: "delete from plans where counter = $1"},
+		pullCommand: "select * from google_trace()",
+		parser:      defaultPlanParser,
+	}
+	rp := &recordingProcessor{}
+	pgr.processExecutionPlan(context.Background(), &connection{querier: fq}, rp)
+	if len(rp.traces) != 0 {
+		t.Errorf("Got %d traces, want none without a transaction to acknowledge them", len(rp.traces))
+	}
+}
+
+func TestValidateConfigAckCommand(t *testing.T) {
+	config := &Config{
+		PullCommand:        "select 1",
+		PullInterval:       time.Second,
+		AckCommand:         "delete from plans where counter = $1",
+		BackpressurePolicy: BackpressureDrop,
+		PushTimeout:        time.Second,
+	}
+	if err := validateConfig(config); err == nil {
+		t.Error("Got no error for ack_command with the drop backpressure policy")
+	}
+}
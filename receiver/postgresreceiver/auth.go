@@ -0,0 +1,111 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// AuthMethod defines how the receiver authenticates to PostgreSQL. When it is
+// empty, the credentials are taken from the connect strings as they are.
+//
+// With AuthPassword and AuthSCRAM, the receiver sends Password for User. The
+// server decides, in pg_hba.conf, whether the password is checked in clear
+// text, with MD5 or with SCRAM-SHA-256, which the driver supports since
+// v1.1.0. AuthSCRAM documents that the server is expected to ask for SCRAM.
+//
+// With AuthCert, the receiver presents the client certificate SSLCert and its
+// key SSLKey, and verifies the server certificate against SSLRootCert if set.
+type AuthMethod string
+
+const (
+	// AuthPassword authenticates with a password.
+	AuthPassword AuthMethod = "password"
+	// AuthSCRAM authenticates with a password checked with SCRAM-SHA-256.
+	AuthSCRAM AuthMethod = "scram"
+	// AuthCert authenticates with a client certificate.
+	AuthCert AuthMethod = "cert"
+)
+
+// authParams returns the connection parameters of the authentication method
+// of config, after checking that its credentials are present.
+func authParams(config *Config) (map[string]string, error) {
+	if config.AuthMethod == "" {
+		return nil, nil
+	}
+	params := make(map[string]string)
+	if config.User != "" {
+		params["user"] = config.User
+	}
+
+	switch config.AuthMethod {
+	case AuthPassword, AuthSCRAM:
+		if config.Password == "" {
+			return nil, fmt.Errorf("auth_method %q requires a password", config.AuthMethod)
+		}
+		params["password"] = config.Password
+	case AuthCert:
+		if config.SSLCert == "" || config.SSLKey == "" {
+			return nil, fmt.Errorf("auth_method %q requires ssl_cert and ssl_key", config.AuthMethod)
+		}
+		if err := checkReadable(config.SSLCert); err != nil {
+			return nil, fmt.Errorf("ssl_cert: %v", err)
+		}
+		if err := checkKeyFile(config.SSLKey); err != nil {
+			return nil, fmt.Errorf("ssl_key: %v", err)
+		}
+		params["sslcert"] = config.SSLCert
+		params["sslkey"] = config.SSLKey
+		// Without a root certificate the server certificate is not verified.
+		params["sslmode"] = "require"
+		if config.SSLRootCert != "" {
+			if err := checkReadable(config.SSLRootCert); err != nil {
+				return nil, fmt.Errorf("ssl_root_cert: %v", err)
+			}
+			params["sslrootcert"] = config.SSLRootCert
+			params["sslmode"] = "verify-full"
+		}
+	default:
+		return nil, fmt.Errorf("unknown auth_method %q, must be %q, %q or %q", config.AuthMethod, AuthPassword, AuthSCRAM, AuthCert)
+	}
+	return params, nil
+}
+
+// checkReadable checks that path is a file the receiver can open.
+func checkReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// checkKeyFile checks that the private key at path is readable, and, as the
+// driver refuses keys that others can read, only by its owner.
+func checkKeyFile(path string) error {
+	if err := checkReadable(path); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%s has group or world access, it must be 0600 or less", path)
+	}
+	return nil
+}
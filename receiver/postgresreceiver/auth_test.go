@@ -0,0 +1,124 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnStrFromConfigAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "postgresreceiver")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile := func(name string, perm os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("pem"), perm); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	cert, key, rootCert := writeFile("client.crt", 0644), writeFile("client.key", 0600), writeFile("root.crt", 0644)
+	openKey := writeFile("open.key", 0644)
+	missing := filepath.Join(dir, "missing.crt")
+
+	tests := []struct {
+		name    string
+		config  Config
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "none",
+			config: Config{ConnStr: "user=postgres password=secret", User: "ignored"},
+			want:   "user=postgres password=secret",
+		},
+		{
+			name:   "password",
+			config: Config{ConnStr: "dbname=orders", AuthMethod: AuthPassword, User: "ocagent", Password: "it's secret"},
+			want:   `dbname=orders password='it\'s secret' user='ocagent'`,
+		},
+		{
+			name:   "scram",
+			config: Config{ConnStr: "dbname=orders", AuthMethod: AuthSCRAM, Password: "secret"},
+			want:   `dbname=orders password='secret'`,
+		},
+		{
+			name:    "scram without password",
+			config:  Config{ConnStr: "dbname=orders", AuthMethod: AuthSCRAM},
+			wantErr: "requires a password",
+		},
+		{
+			name:   "cert",
+			config: Config{ConnStr: "dbname=orders", AuthMethod: AuthCert, SSLCert: cert, SSLKey: key},
+			want:   `dbname=orders sslcert='` + cert + `' sslkey='` + key + `' sslmode='require'`,
+		},
+		{
+			name:   "cert with root certificate",
+			config: Config{ConnStr: "dbname=orders", AuthMethod: AuthCert, SSLCert: cert, SSLKey: key, SSLRootCert: rootCert},
+			want:   `dbname=orders sslcert='` + cert + `' sslkey='` + key + `' sslmode='verify-full' sslrootcert='` + rootCert + `'`,
+		},
+		{
+			name:    "cert without key",
+			config:  Config{ConnStr: "dbname=orders", AuthMethod: AuthCert, SSLCert: cert},
+			wantErr: "requires ssl_cert and ssl_key",
+		},
+		{
+			name:    "missing cert",
+			config:  Config{ConnStr: "dbname=orders", AuthMethod: AuthCert, SSLCert: missing, SSLKey: key},
+			wantErr: "ssl_cert",
+		},
+		{
+			name:    "key readable by others",
+			config:  Config{ConnStr: "dbname=orders", AuthMethod: AuthCert, SSLCert: cert, SSLKey: openKey},
+			wantErr: "group or world access",
+		},
+		{
+			name:    "unknown",
+			config:  Config{ConnStr: "dbname=orders", AuthMethod: "kerberos"},
+			wantErr: "unknown auth_method",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := connStrFromConfig(&tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("connStrFromConfig() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Got connect string %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewChecksAuth(t *testing.T) {
+	config := &Config{ConnStr: "dbname=orders sslmode=disable", PullCommand: "select 1", PullInterval: time.Second, AuthMethod: AuthPassword}
+	if _, err := New(config); err == nil {
+		t.Error("Got no error for the password auth method without a password")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// BackpressurePolicy defines what the receiver does when the next processor
+// is slower than the rate at which plans are pulled.
+//
+// With BackpressureBlock, the default, the poll loop waits for the next
+// processor to accept every TraceData. Nothing is lost, but a slow pipeline
+// delays the following polls and plans accumulate in the database.
+//
+// With BackpressureDrop, the poll loop waits at most PushTimeout for the next
+// processor to be ready and drops the TraceData otherwise. Polls keep their
+// pace and the database is drained, at the cost of losing traces, which are
+// counted in the receiver dropped spans metric.
+type BackpressurePolicy string
+
+const (
+	// BackpressureBlock makes the poll loop wait for the next processor.
+	BackpressureBlock BackpressurePolicy = "block"
+	// BackpressureDrop makes the poll loop drop traces the next processor
+	// cannot accept within PushTimeout.
+	BackpressureDrop BackpressurePolicy = "drop"
+)
+
+func (bp BackpressurePolicy) validate() error {
+	switch bp {
+	case "", BackpressureBlock, BackpressureDrop:
+		return nil
+	default:
+		return fmt.Errorf("unknown backpressure_policy %q, must be %q or %q", bp, BackpressureBlock, BackpressureDrop)
+	}
+}
+
+type pushRequest struct {
+	ctx           context.Context
+	td            data.TraceData
+	nextProcessor processor.TraceDataProcessor
+}
+
+// pushLoop delivers the TraceData handed over by push when the backpressure
+// policy is BackpressureDrop.
+func (pgr *PostgresReceiver) pushLoop(stopCh <-chan struct{}) {
+	for {
+		select {
+		case req := <-pgr.pushes:
+			pgr.handlePushError(req.td, req.nextProcessor.ProcessTraceData(req.ctx, req.td))
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// push sends td to nextProcessor according to the configured backpressure
+// policy, or only counts it with DryRun. It returns the error of
+// nextProcessor, unless td is handed over to the push loop.
+func (pgr *PostgresReceiver) push(ctx context.Context, nextProcessor processor.TraceDataProcessor, td data.TraceData) error {
+	pgr.mu.Lock()
+	policy, timeout, started := pgr.config.BackpressurePolicy, pgr.config.PushTimeout, pgr.stopCh != nil
+	dryRun := pgr.config.DryRun
+	pgr.mu.Unlock()
+
+	if dryRun {
+		observability.RecordTraceReceiverMetrics(ctx, len(td.Spans), 0)
+		atomic.AddInt64(&pgr.counters.dryRunSpans, int64(len(td.Spans)))
+		return nil
+	}
+	if policy != BackpressureDrop || !started {
+		err := nextProcessor.ProcessTraceData(ctx, td)
+		observability.RecordTraceReceiverMetrics(ctx, len(td.Spans), 0)
+		atomic.AddInt64(&pgr.counters.emittedSpans, int64(len(td.Spans)))
+		return err
+	}
+
+	timer := pgr.timeSource().NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case pgr.pushes <- pushRequest{ctx: ctx, td: td, nextProcessor: nextProcessor}:
+		observability.RecordTraceReceiverMetrics(ctx, len(td.Spans), 0)
+		atomic.AddInt64(&pgr.counters.emittedSpans, int64(len(td.Spans)))
+	case <-timer.C():
+		observability.RecordTraceReceiverMetrics(ctx, 0, len(td.Spans))
+	}
+	return nil
+}
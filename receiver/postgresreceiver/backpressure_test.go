@@ -0,0 +1,100 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// blockingProcessor blocks every ProcessTraceData call until release is closed.
+type blockingProcessor struct {
+	release  chan struct{}
+	received chan data.TraceData
+}
+
+func (bp *blockingProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	<-bp.release
+	bp.received <- td
+	return nil
+}
+
+func TestPushDropPolicy(t *testing.T) {
+	pgr := &PostgresReceiver{
+		config: Config{
+			BackpressurePolicy: BackpressureDrop,
+			PushTimeout:        10 * time.Millisecond,
+		},
+		pushes: make(chan pushRequest),
+		stopCh: make(chan struct{}),
+	}
+	defer close(pgr.stopCh)
+	go pgr.pushLoop(pgr.stopCh)
+
+	bp := &blockingProcessor{
+		release:  make(chan struct{}),
+		received: make(chan data.TraceData, 2),
+	}
+	td := data.TraceData{Spans: make([]*tracepb.Span, 3)}
+
+	// The first trace is handed over to the push loop, which then blocks on the
+	// processor, so the second one can only be dropped.
+	start := time.Now()
+	pgr.push(context.Background(), bp, td)
+	pgr.push(context.Background(), bp, td)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Pushes took %v, want them bounded by the push timeout", elapsed)
+	}
+
+	close(bp.release)
+	<-bp.received
+	select {
+	case <-bp.received:
+		t.Fatal("Got the dropped trace delivered")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestValidateConfigPushTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  BackpressurePolicy
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "block without timeout", policy: BackpressureBlock},
+		{name: "drop with timeout", policy: BackpressureDrop, timeout: time.Second},
+		{name: "drop without timeout", policy: BackpressureDrop, wantErr: true},
+		{name: "drop with negative timeout", policy: BackpressureDrop, timeout: -time.Second, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				PullCommand:        "select 1",
+				PullInterval:       time.Second,
+				BackpressurePolicy: tt.policy,
+				PushTimeout:        tt.timeout,
+			}
+			if err := validateConfig(config); (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
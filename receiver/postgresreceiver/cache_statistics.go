@@ -0,0 +1,70 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// cacheStatisticKeys are the statistics reported as attributes of the nodes
+// caching rows, by node type, mapped to their attribute.
+//
+// A Memoize node, PostgreSQL 14 and later, caches the rows of its inner side
+// by parameter values, e.g.:
+//
+//   {"Node Type": "Memoize", "Cache Hits": 980, "Cache Misses": 20,
+//    "Cache Evictions": 0, "Cache Overflows": 0, "Peak Memory Usage": 12, ...}
+//
+// A Hash node builds the hash table of a Hash Join, which spills to disk when
+// it needs more than one batch, e.g.:
+//
+//   {"Node Type": "Hash", "Hash Buckets": 1024, "Original Hash Buckets": 1024,
+//    "Hash Batches": 4, "Original Hash Batches": 1, "Peak Memory Usage": 4096, ...}
+//
+// The peak memory usage is in kB.
+var cacheStatisticKeys = map[string]map[string]string{
+	"Memoize": {
+		"Cache Hits":        "cache_hits",
+		"Cache Misses":      "cache_misses",
+		"Cache Evictions":   "cache_evictions",
+		"Cache Overflows":   "cache_overflows",
+		"Peak Memory Usage": "peak_memory_kb",
+	},
+	"Hash": {
+		"Hash Buckets":          "hash_buckets",
+		"Original Hash Buckets": "original_hash_buckets",
+		"Hash Batches":          "hash_batches",
+		"Original Hash Batches": "original_hash_batches",
+		"Peak Memory Usage":     "peak_memory_kb",
+	},
+}
+
+// cacheStatisticsAttributes returns the attributes of the cache statistics
+// reported by a Memoize or Hash node, see cacheStatisticKeys. It returns nil
+// for the other nodes.
+func cacheStatisticsAttributes(plan map[string]interface{}) map[string]*tracepb.AttributeValue {
+	nodeType, _ := plan["Node Type"].(string)
+	keys := cacheStatisticKeys[nodeType]
+	if keys == nil {
+		return nil
+	}
+	attributes := make(map[string]*tracepb.AttributeValue)
+	for key, attribute := range keys {
+		if value, ok := plan[key].(float64); ok {
+			attributes[attribute] = int64ToAttributeValue(int64(value))
+		}
+	}
+	return attributes
+}
@@ -0,0 +1,96 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import "time"
+
+// Clock tells the time to the receiver, paces its polls and times its waits,
+// between the connection attempts and for the push timeout, so that the tests
+// of a pipeline can drive the polls and check their timing. The receiver uses
+// the real clock unless WithClock is used, FakeClock is a Clock for tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTicker returns a Ticker ticking every d.
+	NewTicker(d time.Duration) Ticker
+	// NewTimer returns a Timer firing once d elapsed.
+	NewTimer(d time.Duration) Timer
+}
+
+// Ticker delivers ticks at intervals, as a time.Ticker does.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+	// Stop turns off the ticker, no more ticks are delivered.
+	Stop()
+}
+
+// Timer delivers a single tick once its duration elapsed, as a time.Timer
+// does.
+type Timer interface {
+	// C returns the channel on which the tick is delivered.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It reports whether the timer was
+	// stopped before firing.
+	Stop() bool
+}
+
+// realClock is the Clock of the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{ticker: time.NewTicker(d)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{timer: time.NewTimer(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (rt realTicker) C() <-chan time.Time {
+	return rt.ticker.C
+}
+
+func (rt realTicker) Stop() {
+	rt.ticker.Stop()
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (rt realTimer) C() <-chan time.Time {
+	return rt.timer.C
+}
+
+func (rt realTimer) Stop() bool {
+	return rt.timer.Stop()
+}
+
+// timeSource returns the clock of the receiver, the real clock unless one was
+// set with WithClock.
+func (pgr *PostgresReceiver) timeSource() Clock {
+	if pgr.clock == nil {
+		return realClock{}
+	}
+	return pgr.clock
+}
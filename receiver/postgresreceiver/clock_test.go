@@ -0,0 +1,145 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// waitFor waits up to a second for cond to hold.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPollLoopWithFakeClock(t *testing.T) {
+	start := time.Unix(1546300800, 0)
+	clock := NewFakeClock(start)
+	fq := &fakeQuerier{databaseName: "orders", serverVersion: "160002", plans: []string{resultPlan}}
+	pgr := &PostgresReceiver{
+		pullCommand:     "select * from google_trace()",
+		parser:          defaultPlanParser,
+		conns:           []*connection{{querier: fq}},
+		intervalChanges: make(chan time.Duration, 1),
+		clock:           clock,
+	}
+	rp := &recordingProcessor{}
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		pgr.pollLoop(10*time.Second, stopCh, rp)
+		close(done)
+	}()
+	waitFor(t, "the ticker", func() bool {
+		created, _ := clock.TickerCounts()
+		return created == 1
+	})
+
+	// No poll until the interval elapsed.
+	clock.Advance(9 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if got := atomic.LoadInt64(&pgr.counters.polls); got != 0 {
+		t.Fatalf("Got %d polls before the interval elapsed, want 0", got)
+	}
+
+	clock.Advance(time.Second)
+	// The time of the last poll is stored after the poll is counted.
+	waitFor(t, "the poll", func() bool { return atomic.LoadInt64(&pgr.counters.lastPoll) != 0 })
+	if got, want := atomic.LoadInt64(&pgr.counters.lastPoll), start.Add(10*time.Second).UnixNano(); got != want {
+		t.Errorf("Got the last poll at %v, want %v", time.Unix(0, got), time.Unix(0, want))
+	}
+
+	// A new interval replaces the ticker.
+	pgr.intervalChanges <- time.Second
+	waitFor(t, "the new ticker", func() bool {
+		created, active := clock.TickerCounts()
+		return created == 2 && active == 1
+	})
+	clock.Advance(time.Second)
+	waitFor(t, "the second poll", func() bool { return atomic.LoadInt64(&pgr.counters.polls) == 2 })
+
+	close(stopCh)
+	<-done
+	if _, active := clock.TickerCounts(); active != 0 {
+		t.Errorf("Got %d tickers left running, want 0", active)
+	}
+}
+
+func TestNewConnectRetriesWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1546300800, 0))
+	// Nothing listens on port 1, the init command cannot connect.
+	config := &Config{
+		ConnStr: "host=127.0.0.1 port=1 sslmode=disable", InitCommand: "select 1",
+		PullCommand: "select 1", PullInterval: time.Hour,
+		ConnectRetries: 1, ConnectRetryBackoff: time.Hour,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := New(config, WithClock(clock))
+		errCh <- err
+	}()
+
+	// The wait before the retry is timed by the clock.
+	waitFor(t, "the retry timer", func() bool { return clock.PendingTimers() == 1 })
+	clock.Advance(time.Hour)
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Error("New() error = nil, want the connection error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Got New() still waiting after the backoff elapsed")
+	}
+}
+
+func TestPushTimeoutWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1546300800, 0))
+	// Nothing receives the pushes, they time out.
+	pgr := &PostgresReceiver{
+		config: Config{BackpressurePolicy: BackpressureDrop, PushTimeout: time.Minute},
+		pushes: make(chan pushRequest),
+		stopCh: make(chan struct{}),
+		clock:  clock,
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- pgr.push(context.Background(), &recordingProcessor{}, data.TraceData{Spans: make([]*tracepb.Span, 3)})
+	}()
+
+	waitFor(t, "the push timer", func() bool { return clock.PendingTimers() == 1 })
+	clock.Advance(time.Minute)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("push() error = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Got push() still waiting after the push timeout elapsed")
+	}
+	if got := atomic.LoadInt64(&pgr.counters.emittedSpans); got != 0 {
+		t.Errorf("Got %d emitted spans, want the trace dropped", got)
+	}
+}
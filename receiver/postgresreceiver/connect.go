@@ -0,0 +1,33 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"time"
+)
+
+// connectWithRetry calls connect until it succeeds, at most retries more
+// times after the first failure. The wait between attempts starts at backoff
+// and doubles after each attempt. It returns the error of the last attempt.
+func connectWithRetry(logger logger, retries int, backoff time.Duration, sleep func(time.Duration), connect func() error) error {
+	err := connect()
+	for attempt := 1; err != nil && attempt <= retries; attempt++ {
+		logger.Printf("Failed to connect to postgres, retrying in %v (%d/%d): %v", backoff, attempt, retries, err)
+		sleep(backoff)
+		backoff *= 2
+		err = connect()
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConnectWithRetry(t *testing.T) {
+	errNotReady := errors.New("the database system is starting up")
+
+	tests := []struct {
+		name       string
+		retries    int
+		failures   int
+		wantErr    error
+		wantSleeps []time.Duration
+	}{
+		{name: "first attempt", retries: 3, failures: 0},
+		{name: "no retry", retries: 0, failures: 1, wantErr: errNotReady},
+		{
+			name:       "eventually ready",
+			retries:    3,
+			failures:   2,
+			wantSleeps: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:       "never ready",
+			retries:    3,
+			failures:   10,
+			wantErr:    errNotReady,
+			wantSleeps: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sleeps []time.Duration
+			attempts := 0
+			err := connectWithRetry(logger{}, tt.retries, time.Second, func(d time.Duration) {
+				sleeps = append(sleeps, d)
+			}, func() error {
+				attempts++
+				if attempts <= tt.failures {
+					return errNotReady
+				}
+				return nil
+			})
+			if err != tt.wantErr {
+				t.Errorf("Got error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(sleeps, tt.wantSleeps) {
+				t.Errorf("Got sleeps %v, want %v", sleeps, tt.wantSleeps)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// setDeterministicIDs replaces the random IDs of spans, the spans of the row
+// counter, with IDs derived from the row.
+// The trace ID is a hash of the counter, the query and the start time of the
+// root span, unless the query is linked to the trace of the application that
+// ran it, which is kept. The span IDs are hashes of the trace ID and of the
+// path of the spans from the root span, the position of every ancestor among
+// its siblings, so that the spans of a plan parsed twice get the same IDs.
+func setDeterministicIDs(spans []*tracepb.Span, counter int) {
+	root := rootOfSpans(spans)
+	query := root.Attributes.GetAttributeMap()["query"].GetStringValue().GetValue()
+	traceID := root.TraceId
+	if len(root.ParentSpanId) == 0 {
+		traceID = deterministicTraceID(counter, query, internal.TimestampToTime(root.StartTime).UnixNano())
+	}
+
+	// The children of the spans, in the order of the spans.
+	children := make(map[string][]*tracepb.Span)
+	for _, span := range spans {
+		if span == root {
+			continue
+		}
+		children[string(span.ParentSpanId)] = append(children[string(span.ParentSpanId)], span)
+	}
+
+	type node struct {
+		span *tracepb.Span
+		path string
+	}
+	stack := []node{{root, ""}}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		oldSpanID := string(n.span.SpanId)
+		n.span.TraceId = traceID
+		n.span.SpanId = deterministicSpanID(traceID, n.path)
+		for i, child := range children[oldSpanID] {
+			child.ParentSpanId = n.span.SpanId
+			stack = append(stack, node{child, n.path + "/" + strconv.Itoa(i)})
+		}
+		// A span is only visited once, even if another span has the same ID.
+		delete(children, oldSpanID)
+	}
+}
+
+// deterministicTraceID hashes the row counter, the query and the start time
+// of a query in nanoseconds into a trace ID.
+func deterministicTraceID(counter int, query string, startNanos int64) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(strconv.Itoa(counter))
+	buf.WriteByte(0)
+	buf.WriteString(query)
+	buf.WriteByte(0)
+	var nanos [8]byte
+	binary.BigEndian.PutUint64(nanos[:], uint64(startNanos))
+	buf.Write(nanos[:])
+	sum := sha256.Sum256(buf.Bytes())
+	return sum[:16]
+}
+
+// deterministicSpanID hashes a trace ID and the path of a span in the trace
+// into a span ID.
+func deterministicSpanID(traceID []byte, path string) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, traceID...), path...))
+	return sum[:8]
+}
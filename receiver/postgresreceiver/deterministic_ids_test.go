@@ -0,0 +1,111 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+const nestedPlan = `{
+	"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select * from orders join items using (id)",
+	"username": "postgres", "session_username": "postgres", "connection_id": 42,
+	"Plan": {"Node Type": "Hash Join", "Actual Startup Time": 0.1, "Actual Total Time": 0.4, "Actual Rows": 1, "Plans": [
+		{"Node Type": "Seq Scan", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1},
+		{"Node Type": "Seq Scan", "Actual Startup Time": 0.1, "Actual Total Time": 0.3, "Actual Rows": 1}
+	]}
+}`
+
+func TestProcessRowsDeterministicIDs(t *testing.T) {
+	pgr := &PostgresReceiver{config: Config{DeterministicIDs: true}, parser: defaultPlanParser}
+	rp := &recordingProcessor{}
+	// Pulling the same row twice gives the same spans.
+	for i := 0; i < 2; i++ {
+		rows := &faultyRows{plans: []string{nestedPlan}}
+		if _, err := pgr.processRows(context.Background(), &connection{}, rows, rp); err != nil {
+			t.Fatalf("processRows() error = %v", err)
+		}
+	}
+	if len(rp.traces) != 2 {
+		t.Fatalf("Got %d traces, want 2", len(rp.traces))
+	}
+
+	first, second := rp.traces[0].Spans, rp.traces[1].Spans
+	root := first[len(first)-1]
+	spanIDs := make(map[string]bool)
+	for i, span := range first {
+		if !bytes.Equal(span.TraceId, second[i].TraceId) || !bytes.Equal(span.SpanId, second[i].SpanId) ||
+			!bytes.Equal(span.ParentSpanId, second[i].ParentSpanId) {
+			t.Errorf("Got span %d with different IDs in the two traces", i)
+		}
+		if !bytes.Equal(span.TraceId, root.TraceId) {
+			t.Errorf("Got span %q in trace %x, want %x", span.Name.GetValue(), span.TraceId, root.TraceId)
+		}
+		spanIDs[string(span.SpanId)] = true
+	}
+	if len(spanIDs) != len(first) {
+		t.Errorf("Got %d distinct span IDs, want %d", len(spanIDs), len(first))
+	}
+	join := spanByName(first, "Hash Join")
+	if !bytes.Equal(join.ParentSpanId, root.SpanId) {
+		t.Errorf("Got the Hash Join span under %x, want the root span %x", join.ParentSpanId, root.SpanId)
+	}
+	for _, span := range first {
+		if span.Name.GetValue() == "Seq Scan" && !bytes.Equal(span.ParentSpanId, join.SpanId) {
+			t.Errorf("Got a Seq Scan span under %x, want the Hash Join span %x", span.ParentSpanId, join.SpanId)
+		}
+	}
+}
+
+func TestSetDeterministicIDs(t *testing.T) {
+	spans, err := defaultPlanParser.Parse([]byte(nestedPlan))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	setDeterministicIDs(spans, 1)
+	other, err := defaultPlanParser.Parse([]byte(nestedPlan))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	setDeterministicIDs(other, 2)
+	if bytes.Equal(spans[0].TraceId, other[0].TraceId) {
+		t.Error("Got the same trace ID for different rows")
+	}
+
+	// The trace of the application running the query is kept.
+	linked := `{"parent_trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "parent_span_id": "00f067aa0ba902b7",` + nestedPlan[1:]
+	spans, err = defaultPlanParser.Parse([]byte(linked))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	setDeterministicIDs(spans, 1)
+	root := spans[len(spans)-1]
+	if got := hex.EncodeToString(root.TraceId); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Got trace ID %s, want the one of the application", got)
+	}
+	if got := hex.EncodeToString(root.ParentSpanId); got != "00f067aa0ba902b7" {
+		t.Errorf("Got parent span ID %s, want the one of the application", got)
+	}
+}
+
+func TestValidateConfigDeterministicIDs(t *testing.T) {
+	config := &Config{PullCommand: "select 1", PullInterval: time.Second, DeterministicIDs: true, PartialSpans: true}
+	if err := validateConfig(config); err == nil {
+		t.Error("Got no error for deterministic_ids with partial_spans")
+	}
+}
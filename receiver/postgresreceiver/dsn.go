@@ -0,0 +1,94 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// connStrFromConfig returns the connect string of config with the receiver
+// specific run-time parameters, such as application_name and statement_timeout,
+// and the credentials of its authentication method added to it. Parameters
+// already present in the connect string are overridden.
+func connStrFromConfig(config *Config) (string, error) {
+	params := make(map[string]string)
+	if config.ApplicationName != "" {
+		params["application_name"] = config.ApplicationName
+	}
+	if config.StatementTimeout > 0 {
+		// PostgreSQL takes milliseconds, round up so that a sub-millisecond
+		// timeout does not become 0, which disables it.
+		params["statement_timeout"] = fmt.Sprintf("%d", (config.StatementTimeout+time.Millisecond-1)/time.Millisecond)
+	}
+	auth, err := authParams(config)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range auth {
+		params[key] = value
+	}
+	return addConnStrParams(config.ConnStr, params)
+}
+
+// addConnStrParams adds params to connStr, which can either be in the URL
+// form (postgres://...) or in the key/value form (key=value ...).
+func addConnStrParams(connStr string, params map[string]string) (string, error) {
+	if len(params) == 0 {
+		return connStr, nil
+	}
+
+	if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
+		u, err := url.Parse(connStr)
+		if err != nil {
+			return "", fmt.Errorf("invalid connect string: %v", err)
+		}
+		query := u.Query()
+		for key, value := range params {
+			query.Set(key, value)
+		}
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// In the key/value form the last occurrence of a key wins, so appending is
+	// enough to override any existing value.
+	var sb strings.Builder
+	sb.WriteString(connStr)
+	for _, key := range keys {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		sb.WriteString(quoteConnStrValue(params[key]))
+	}
+	return sb.String(), nil
+}
+
+func quoteConnStrValue(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `'`, `\'`, -1)
+	return "'" + value + "'"
+}
@@ -0,0 +1,80 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnStrFromConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{
+			name:   "no params",
+			config: Config{ConnStr: "user=postgres sslmode=disable"},
+			want:   "user=postgres sslmode=disable",
+		},
+		{
+			name: "key/value",
+			config: Config{
+				ConnStr:          "user=postgres sslmode=disable",
+				ApplicationName:  "oc agent's receiver",
+				StatementTimeout: 5 * time.Second,
+			},
+			want: `user=postgres sslmode=disable application_name='oc agent\'s receiver' statement_timeout='5000'`,
+		},
+		{
+			name: "sub-millisecond timeout",
+			config: Config{
+				ConnStr:          "user=postgres",
+				StatementTimeout: 1500 * time.Microsecond,
+			},
+			want: `user=postgres statement_timeout='2'`,
+		},
+		{
+			name: "microsecond timeout",
+			config: Config{
+				ConnStr:          "user=postgres",
+				StatementTimeout: time.Microsecond,
+			},
+			want: `user=postgres statement_timeout='1'`,
+		},
+		{
+			name: "url",
+			config: Config{
+				ConnStr:          "postgres://postgres@localhost/db?sslmode=disable&application_name=old",
+				ApplicationName:  "ocagent",
+				StatementTimeout: 1500 * time.Millisecond,
+			},
+			want: "postgres://postgres@localhost/db?application_name=ocagent&sslmode=disable&statement_timeout=1500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := connStrFromConfig(&tt.config)
+			if err != nil {
+				t.Fatalf("connStrFromConfig() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("connStrFromConfig() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
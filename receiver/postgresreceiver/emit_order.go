@@ -0,0 +1,86 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"fmt"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// EmitOrder is the order of the spans of a query returned by the parser, for
+// the exporters sensitive to it.
+type EmitOrder string
+
+const (
+	// EmitChildrenFirst emits the spans of the children of a plan node
+	// before its own, and the root span of the query last.
+	EmitChildrenFirst EmitOrder = "children-first"
+	// EmitParentsFirst emits the root span of the query first, and the span
+	// of a plan node before the spans of its children.
+	EmitParentsFirst EmitOrder = "parents-first"
+)
+
+func (eo EmitOrder) validate() error {
+	switch eo {
+	case "", EmitChildrenFirst, EmitParentsFirst:
+		return nil
+	default:
+		return fmt.Errorf("unknown emit_order %q, must be %q or %q", eo, EmitChildrenFirst, EmitParentsFirst)
+	}
+}
+
+// parentsFirst returns the spans of a query, emitted children first, in the
+// parents first order. The siblings keep their order.
+func parentsFirst(spans []*tracepb.Span) []*tracepb.Span {
+	root := rootOfSpans(spans)
+	children := make(map[string][]*tracepb.Span)
+	for _, span := range spans {
+		if span != root {
+			children[string(span.ParentSpanId)] = append(children[string(span.ParentSpanId)], span)
+		}
+	}
+
+	ordered := make([]*tracepb.Span, 0, len(spans))
+	stack := []*tracepb.Span{root}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		ordered = append(ordered, span)
+		siblings := children[string(span.SpanId)]
+		// A span is only visited once, even if another span has the same ID.
+		delete(children, string(span.SpanId))
+		for i := len(siblings) - 1; i >= 0; i-- {
+			stack = append(stack, siblings[i])
+		}
+	}
+	return ordered
+}
+
+// rootOfSpans returns the root span of spans, the spans of a query, the
+// first span whose parent is not one of them.
+func rootOfSpans(spans []*tracepb.Span) *tracepb.Span {
+	spanIDs := make(map[string]bool, len(spans))
+	for _, span := range spans {
+		spanIDs[string(span.SpanId)] = true
+	}
+	for _, span := range spans {
+		if !spanIDs[string(span.ParentSpanId)] {
+			return span
+		}
+	}
+	// A malformed trace without a root, fall back to the emit order.
+	return spans[len(spans)-1]
+}
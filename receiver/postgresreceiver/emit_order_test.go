@@ -0,0 +1,82 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEmitOrder(t *testing.T) {
+	tests := []struct {
+		order     EmitOrder
+		wantNames string
+	}{
+		{"", "Seq Scan, Seq Scan, Hash Join, CloudSQLQuery"},
+		{EmitChildrenFirst, "Seq Scan, Seq Scan, Hash Join, CloudSQLQuery"},
+		{EmitParentsFirst, "CloudSQLQuery, Hash Join, Seq Scan, Seq Scan"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.order), func(t *testing.T) {
+			spans, err := newPlanParser(&Config{EmitOrder: tt.order}).Parse([]byte(nestedPlan))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var names []string
+			for _, span := range spans {
+				names = append(names, span.Name.GetValue())
+			}
+			if got := strings.Join(names, ", "); got != tt.wantNames {
+				t.Errorf("Got spans %s, want %s", got, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestParentsFirstKeepsSiblingOrder(t *testing.T) {
+	spans, err := defaultPlanParser.Parse([]byte(nestedPlan))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	firstScan, secondScan := spans[0], spans[1]
+	ordered := parentsFirst(spans)
+	if ordered[2] != firstScan || ordered[3] != secondScan {
+		t.Error("Got the Seq Scan siblings reordered")
+	}
+
+	// The IDs derived from the spans do not depend on their order.
+	setDeterministicIDs(ordered, 1)
+	other, err := newPlanParser(&Config{EmitOrder: EmitChildrenFirst}).Parse([]byte(nestedPlan))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	setDeterministicIDs(other, 1)
+	// The positions of the children first spans in the parents first order.
+	positions := []int{2, 3, 1, 0}
+	for i, span := range other {
+		if !bytes.Equal(span.SpanId, ordered[positions[i]].SpanId) {
+			t.Errorf("Got span %d with different IDs in the two orders", i)
+		}
+	}
+}
+
+func TestValidateConfigEmitOrder(t *testing.T) {
+	config := &Config{PullCommand: "select 1", PullInterval: time.Second, EmitOrder: "breadth-first"}
+	if err := validateConfig(config); err == nil {
+		t.Error("Got no error for an unknown emit_order")
+	}
+}
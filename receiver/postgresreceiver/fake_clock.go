@@ -0,0 +1,179 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FakeClock is a Clock whose time only moves with Advance, which delivers the
+// ticks of its tickers and fires its timers that are due. It is meant for the
+// tests of the pipelines using the receiver, see WithClock.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	// timers holds the timers that neither fired nor were stopped.
+	timers []*fakeTimer
+}
+
+var _ Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a FakeClock telling now until it is advanced.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the clock.
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+// NewTicker returns a Ticker ticking every d of the time of the clock.
+func (fc *FakeClock) NewTicker(d time.Duration) Ticker {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	ft := &fakeTicker{c: make(chan time.Time, 1), interval: d, next: fc.now.Add(d)}
+	fc.tickers = append(fc.tickers, ft)
+	return ft
+}
+
+// NewTimer returns a Timer firing once the clock is advanced by d. As with a
+// time.Timer, it fires right away when d is not positive.
+func (fc *FakeClock) NewTimer(d time.Duration) Timer {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	ft := &fakeTimer{c: make(chan time.Time, 1), at: fc.now.Add(d)}
+	if d <= 0 {
+		ft.fire()
+		return ft
+	}
+	fc.timers = append(fc.timers, ft)
+	return ft
+}
+
+// Advance moves the time forward by d. As with a time.Ticker, the ticks that
+// are not received in time are dropped.
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.now = fc.now.Add(d)
+	for _, ft := range fc.tickers {
+		if ft.isStopped() {
+			continue
+		}
+		for !ft.next.After(fc.now) {
+			select {
+			case ft.c <- ft.next:
+			default:
+			}
+			ft.next = ft.next.Add(ft.interval)
+		}
+	}
+
+	pending := fc.timers[:0]
+	for _, ft := range fc.timers {
+		if ft.at.After(fc.now) {
+			if atomic.LoadInt32(&ft.state) == timerPending {
+				pending = append(pending, ft)
+			}
+			continue
+		}
+		ft.fire()
+	}
+	fc.timers = pending
+}
+
+// TickerCounts returns the number of tickers created, and of those that are
+// not stopped. The tests wait for the receiver to create its ticker before
+// advancing the clock.
+func (fc *FakeClock) TickerCounts() (created, active int) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for _, ft := range fc.tickers {
+		if !ft.isStopped() {
+			active++
+		}
+	}
+	return len(fc.tickers), active
+}
+
+// PendingTimers returns the number of timers that neither fired nor were
+// stopped, such as the one of the receiver waiting between two connection
+// attempts.
+func (fc *FakeClock) PendingTimers() int {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	pending := 0
+	for _, ft := range fc.timers {
+		if atomic.LoadInt32(&ft.state) == timerPending {
+			pending++
+		}
+	}
+	return pending
+}
+
+type fakeTicker struct {
+	c        chan time.Time
+	interval time.Duration
+	// next is the time of the next tick, only accessed with the lock of the
+	// clock held.
+	next    time.Time
+	stopped int32
+}
+
+func (ft *fakeTicker) C() <-chan time.Time {
+	return ft.c
+}
+
+func (ft *fakeTicker) Stop() {
+	atomic.StoreInt32(&ft.stopped, 1)
+}
+
+func (ft *fakeTicker) isStopped() bool {
+	return atomic.LoadInt32(&ft.stopped) == 1
+}
+
+const (
+	timerPending int32 = iota
+	timerFired
+	timerStopped
+)
+
+type fakeTimer struct {
+	c  chan time.Time
+	at time.Time
+	// state is timerPending until the timer fires or is stopped.
+	state int32
+}
+
+func (ft *fakeTimer) C() <-chan time.Time {
+	return ft.c
+}
+
+func (ft *fakeTimer) Stop() bool {
+	return atomic.CompareAndSwapInt32(&ft.state, timerPending, timerStopped)
+}
+
+// fire delivers the tick of the timer unless it was stopped.
+func (ft *fakeTimer) fire() {
+	if atomic.CompareAndSwapInt32(&ft.state, timerPending, timerFired) {
+		ft.c <- ft.at
+	}
+}
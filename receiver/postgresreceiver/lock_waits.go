@@ -0,0 +1,81 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"fmt"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// addLockWaits adds the lock waits of the optional "lock_waits" array of a
+// payload, which some pipelines add to the auto_explain output, as
+// annotations of the root span of the query. Every lock wait has the relation
+// and the mode of the lock, and optionally its duration and its offset from
+// the start of the query, both in milliseconds, e.g.:
+//
+//   "lock_waits": [
+//     {"relation": "orders", "mode": "RowExclusiveLock", "duration": 12.5, "offset": 0.3}
+//   ]
+//
+// The annotations are at the start of the wait, or of the query when the
+// offset is missing, and the root span gets a total_lock_wait_ms attribute
+// with the sum of the durations when there are some.
+func addLockWaits(root *tracepb.Span, lockWaits interface{}, start time.Time) {
+	list, ok := lockWaits.([]interface{})
+	if !ok || len(list) == 0 {
+		return
+	}
+
+	var totalWaitMs float64
+	timed := false
+	for _, lockWait := range list {
+		lw, ok := lockWait.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		relation, _ := lw["relation"].(string)
+		mode, _ := lw["mode"].(string)
+		attributes := map[string]*tracepb.AttributeValue{
+			"relation": stringToAttributeValue(relation),
+			"mode":     stringToAttributeValue(mode),
+		}
+		if duration, ok := lw["duration"].(float64); ok {
+			attributes["duration_ms"] = doubleToAttributeValue(duration)
+			totalWaitMs += duration
+			timed = true
+		}
+		at := start
+		if offset, ok := lw["offset"].(float64); ok {
+			at = start.Add(time.Duration(offset * float64(time.Millisecond)))
+		}
+
+		if root.TimeEvents == nil {
+			root.TimeEvents = &tracepb.Span_TimeEvents{}
+		}
+		root.TimeEvents.TimeEvent = append(root.TimeEvents.TimeEvent, &tracepb.Span_TimeEvent{
+			Time: internal.TimeToTimestamp(at),
+			Value: &tracepb.Span_TimeEvent_Annotation_{Annotation: &tracepb.Span_TimeEvent_Annotation{
+				Description: &tracepb.TruncatableString{Value: fmt.Sprintf("lock wait on %s (%s)", relation, mode)},
+				Attributes:  &tracepb.Span_Attributes{AttributeMap: attributes},
+			}},
+		})
+	}
+	if timed {
+		root.Attributes.AttributeMap["total_lock_wait_ms"] = doubleToAttributeValue(totalWaitMs)
+	}
+}
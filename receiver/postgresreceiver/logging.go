@@ -0,0 +1,87 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// logger writes to the standard logger, prefixing the messages with the name
+// of the receiver so that the receivers of a process can be told apart. The
+// zero value writes the messages as they are.
+type logger struct {
+	prefix string
+}
+
+func newLogger(config *Config) logger {
+	return logger{prefix: fmt.Sprintf("[%s] ", instanceName(config))}
+}
+
+func (l logger) Printf(format string, v ...interface{}) {
+	log.Output(2, l.prefix+fmt.Sprintf(format, v...))
+}
+
+func (l logger) Println(v ...interface{}) {
+	log.Output(2, l.prefix+fmt.Sprintln(v...))
+}
+
+// instanceName returns the name of the receiver configured by config: its
+// Name, or else "postgres" followed by the host and database of ConnStr when
+// it polls a single database.
+func instanceName(config *Config) string {
+	if config.Name != "" {
+		return config.Name
+	}
+	if len(config.Connections) > 0 || config.ConnStr == "" {
+		return receiverName
+	}
+	host, dbname := connStrHostAndDatabase(config.ConnStr)
+	if host == "" {
+		host = "localhost"
+	}
+	if dbname == "" {
+		return receiverName + "/" + host
+	}
+	return receiverName + "/" + host + "/" + dbname
+}
+
+// connStrHostAndDatabase returns the host and database of connStr, in the URL
+// or in the key/value form, or empty strings when they are not set.
+func connStrHostAndDatabase(connStr string) (host, dbname string) {
+	if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
+		u, err := url.Parse(connStr)
+		if err != nil {
+			return "", ""
+		}
+		return u.Hostname(), strings.TrimPrefix(u.Path, "/")
+	}
+	for _, field := range strings.Fields(connStr) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.Trim(kv[1], "'")
+		switch kv[0] {
+		case "host":
+			host = value
+		case "dbname":
+			dbname = value
+		}
+	}
+	return host, dbname
+}
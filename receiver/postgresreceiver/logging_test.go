@@ -0,0 +1,55 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestInstanceName(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   string
+	}{
+		{config: Config{Name: "orders", ConnStr: "host=db dbname=users"}, want: "orders"},
+		{config: Config{ConnStr: "host=db.internal dbname=orders sslmode=disable"}, want: "postgres/db.internal/orders"},
+		{config: Config{ConnStr: "user=postgres dbname='orders'"}, want: "postgres/localhost/orders"},
+		{config: Config{ConnStr: "postgres://postgres@db.internal:5432/orders?sslmode=disable"}, want: "postgres/db.internal/orders"},
+		{config: Config{ConnStr: "sslmode=disable"}, want: "postgres/localhost"},
+		{config: Config{Connections: []ConnectionConfig{{ConnStr: "dbname=orders"}, {ConnStr: "dbname=users"}}}, want: "postgres"},
+	}
+	for _, tt := range tests {
+		if got := instanceName(&tt.config); got != tt.want {
+			t.Errorf("instanceName(%+v) = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	flags := log.Flags()
+	log.SetFlags(0)
+	defer log.SetFlags(flags)
+
+	newLogger(&Config{Name: "orders"}).Printf("Pull command failed: %v", "timeout")
+	if got, want := buf.String(), "[orders] Pull command failed: timeout\n"; got != want {
+		t.Errorf("Got log %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+// TagKeyReceiverName is the tag key of the name of the receiver, see
+// Config.Name.
+var TagKeyReceiverName, _ = tag.NewKey("postgres_receiver")
+
+var (
+	mPollDuration    = stats.Float64("postgresreceiver/poll_duration_ms", "Duration of a single poll of the pull command, including row processing", stats.UnitMilliseconds)
+	mRowsProcessed   = stats.Int64("postgresreceiver/rows_processed", "Counts the number of rows returned by the pull command", stats.UnitDimensionless)
+	mSkippedPolls    = stats.Int64("postgresreceiver/skipped_polls", "Counts the number of polls skipped because the previous one was still running", stats.UnitDimensionless)
+	mInvalidPlans    = stats.Int64("postgresreceiver/invalid_plans", "Counts the number of plans skipped because they lack required keys", stats.UnitDimensionless)
+	mOversizedPlans  = stats.Int64("postgresreceiver/oversized_plans", "Counts the number of plans skipped because they are larger than the max_plan_bytes setting", stats.UnitDimensionless)
+	mDistinctQueries = stats.Int64("postgresreceiver/distinct_queries", "The number of distinct query fingerprints in the last poll of a database", stats.UnitDimensionless)
+	mStaleDatabases  = stats.Int64("postgresreceiver/stale_databases", "Counts the number of times a database returned no rows for longer than the stale_after setting", stats.UnitDimensionless)
+)
+
+// ViewPollDuration defines the view for the poll duration metric.
+var ViewPollDuration = &view.View{
+	Name:        mPollDuration.Name(),
+	Description: mPollDuration.Description(),
+	Measure:     mPollDuration,
+	Aggregation: view.Distribution(1, 2, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 10000, 30000, 60000),
+	TagKeys:     []tag.Key{observability.TagKeyReceiver, TagKeyReceiverName},
+}
+
+// ViewRowsProcessed defines the view for the rows processed metric.
+var ViewRowsProcessed = &view.View{
+	Name:        mRowsProcessed.Name(),
+	Description: mRowsProcessed.Description(),
+	Measure:     mRowsProcessed,
+	Aggregation: view.Sum(),
+	TagKeys:     []tag.Key{observability.TagKeyReceiver, TagKeyReceiverName},
+}
+
+// ViewSkippedPolls defines the view for the skipped polls metric.
+var ViewSkippedPolls = &view.View{
+	Name:        mSkippedPolls.Name(),
+	Description: mSkippedPolls.Description(),
+	Measure:     mSkippedPolls,
+	Aggregation: view.Sum(),
+	TagKeys:     []tag.Key{observability.TagKeyReceiver, TagKeyReceiverName},
+}
+
+// ViewInvalidPlans defines the view for the invalid plans metric.
+var ViewInvalidPlans = &view.View{
+	Name:        mInvalidPlans.Name(),
+	Description: mInvalidPlans.Description(),
+	Measure:     mInvalidPlans,
+	Aggregation: view.Sum(),
+	TagKeys:     []tag.Key{observability.TagKeyReceiver, TagKeyReceiverName},
+}
+
+// ViewStaleDatabases defines the view for the stale databases metric.
+var ViewStaleDatabases = &view.View{
+	Name:        mStaleDatabases.Name(),
+	Description: mStaleDatabases.Description(),
+	Measure:     mStaleDatabases,
+	Aggregation: view.Sum(),
+	TagKeys:     []tag.Key{observability.TagKeyReceiver, TagKeyReceiverName},
+}
+
+// ViewOversizedPlans defines the view for the oversized plans metric.
+var ViewOversizedPlans = &view.View{
+	Name:        mOversizedPlans.Name(),
+	Description: mOversizedPlans.Description(),
+	Measure:     mOversizedPlans,
+	Aggregation: view.Sum(),
+	TagKeys:     []tag.Key{observability.TagKeyReceiver, TagKeyReceiverName},
+}
+
+// ViewDistinctQueries defines the view for the distinct queries metric.
+var ViewDistinctQueries = &view.View{
+	Name:        mDistinctQueries.Name(),
+	Description: mDistinctQueries.Description(),
+	Measure:     mDistinctQueries,
+	Aggregation: view.LastValue(),
+	TagKeys:     []tag.Key{observability.TagKeyReceiver, TagKeyReceiverName},
+}
+
+// MetricViews returns the views for the metrics recorded by the PostgreSQL receiver.
+func MetricViews() []*view.View {
+	return []*view.View{
+		ViewPollDuration,
+		ViewRowsProcessed,
+		ViewSkippedPolls,
+		ViewInvalidPlans,
+		ViewStaleDatabases,
+		ViewDistinctQueries,
+		ViewOversizedPlans,
+	}
+}
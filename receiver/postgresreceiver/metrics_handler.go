@@ -0,0 +1,94 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const defaultMetricsPath = "/metrics"
+
+// receiverCounters are the internals of the receiver exposed by its metrics
+// handler. They are updated atomically, the int64 fields come first to be
+// 64-bit aligned.
+type receiverCounters struct {
+	polls        int64
+	rows         int64
+	scanErrors   int64
+	emittedSpans int64
+	dryRunSpans  int64
+	// lastPoll is the end time of the last poll in nanoseconds since the
+	// epoch, 0 until a poll completes.
+	lastPoll int64
+}
+
+func (c *receiverCounters) pollDone(rows int64, end time.Time) {
+	atomic.AddInt64(&c.polls, 1)
+	atomic.AddInt64(&c.rows, rows)
+	atomic.StoreInt64(&c.lastPoll, end.UnixNano())
+}
+
+// ServeHTTP writes the counters in the Prometheus text exposition format.
+func (c *receiverCounters) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics := []struct {
+		name, help, typ string
+		value           float64
+	}{
+		{"postgresreceiver_polls_total", "Number of completed polls of the pull command.", "counter", float64(atomic.LoadInt64(&c.polls))},
+		{"postgresreceiver_rows_total", "Number of rows returned by the pull command.", "counter", float64(atomic.LoadInt64(&c.rows))},
+		{"postgresreceiver_scan_errors_total", "Number of rows that could not be scanned.", "counter", float64(atomic.LoadInt64(&c.scanErrors))},
+		{"postgresreceiver_emitted_spans_total", "Number of spans sent to the next processor.", "counter", float64(atomic.LoadInt64(&c.emittedSpans))},
+		{"postgresreceiver_dry_run_spans_total", "Number of spans parsed but not sent to the next processor, with dry_run.", "counter", float64(atomic.LoadInt64(&c.dryRunSpans))},
+		{"postgresreceiver_last_poll_timestamp_seconds", "End time of the last completed poll, in seconds since the epoch.", "gauge", float64(atomic.LoadInt64(&c.lastPoll)) / float64(time.Second)},
+	}
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", m.name, m.help, m.name, m.typ, m.name, m.value)
+	}
+}
+
+// MetricsHandler returns an http.Handler exposing the internals of the
+// receiver in the Prometheus text format, for embedding in another server.
+// See Config.MetricsEndpoint to have the receiver serve it on its own.
+func (pgr *PostgresReceiver) MetricsHandler() http.Handler {
+	return &pgr.counters
+}
+
+// serveMetrics serves the metrics handler on the endpoint and path of config
+// until the returned server is closed.
+func (pgr *PostgresReceiver) serveMetrics(config *Config) (*http.Server, error) {
+	path := config.MetricsPath
+	if path == "" {
+		path = defaultMetricsPath
+	}
+	// Listening synchronously reports an unavailable endpoint to the caller.
+	ln, err := net.Listen("tcp", config.MetricsEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, pgr.MetricsHandler())
+	srv := &http.Server{Handler: mux}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			pgr.logger.Printf("Metrics endpoint failed: %v", err)
+		}
+	}()
+	return srv, nil
+}
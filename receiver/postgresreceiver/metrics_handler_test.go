@@ -0,0 +1,86 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres",
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	pgr := &PostgresReceiver{parser: defaultPlanParser}
+	rows := &faultyRows{plans: []string{plan, plan}}
+	rowsProcessed, err := pgr.processRows(context.Background(), &connection{}, rows, &slowProcessor{})
+	if err != nil {
+		t.Fatalf("Failed to process rows: %v", err)
+	}
+	pgr.counters.pollDone(rowsProcessed, time.Unix(1546300800, 0))
+
+	rec := httptest.NewRecorder()
+	pgr.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		"# TYPE postgresreceiver_polls_total counter\npostgresreceiver_polls_total 1\n",
+		"postgresreceiver_rows_total 2\n",
+		"postgresreceiver_scan_errors_total 0\n",
+		"postgresreceiver_emitted_spans_total 4\n",
+		"# TYPE postgresreceiver_last_poll_timestamp_seconds gauge\npostgresreceiver_last_poll_timestamp_seconds 1.5463008e+09\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Metrics do not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsHandlerDryRun(t *testing.T) {
+	pgr := &PostgresReceiver{config: Config{DryRun: true}, parser: defaultPlanParser}
+	rp := &recordingProcessor{}
+	rows := &faultyRows{plans: []string{resultPlan, resultPlan}}
+	if _, err := pgr.processRows(context.Background(), &connection{}, rows, rp); err != nil {
+		t.Fatalf("Failed to process rows: %v", err)
+	}
+	if len(rp.traces) != 0 {
+		t.Errorf("Got %d traces sent with dry_run, want none", len(rp.traces))
+	}
+
+	rec := httptest.NewRecorder()
+	pgr.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	for _, want := range []string{
+		"postgresreceiver_emitted_spans_total 0\n",
+		"postgresreceiver_dry_run_spans_total 4\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Metrics do not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestValidateConfigDryRun(t *testing.T) {
+	config := &Config{PullCommand: "select 1", PullInterval: time.Second, DryRun: true, AckCommand: "select ack($1)"}
+	if err := validateConfig(config); err == nil {
+		t.Error("Got no error for ack_command with dry_run")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"strings"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// modifyTableAttributes returns the modify_operation and affected_rows
+// attributes of a ModifyTable node, which runs the writes of an INSERT,
+// UPDATE or DELETE, e.g.:
+//
+//   {"Node Type": "ModifyTable", "Operation": "Update", "Actual Rows": 0,
+//    "Plans": [{"Node Type": "Seq Scan", "Actual Rows": 42, ...}]}
+//
+// Unless the statement has a RETURNING clause the node itself returns no
+// rows, so the affected rows are the tuple count the node reports for its
+// operation, e.g. "Tuples Inserted" for an INSERT ON CONFLICT, or otherwise
+// the rows produced by its child. It returns nil for the other nodes.
+func modifyTableAttributes(plan map[string]interface{}) map[string]*tracepb.AttributeValue {
+	if nodeType, _ := plan["Node Type"].(string); nodeType != "ModifyTable" {
+		return nil
+	}
+	operation, _ := plan["Operation"].(string)
+	attributes := make(map[string]*tracepb.AttributeValue)
+	if operation != "" {
+		attributes["modify_operation"] = stringToAttributeValue(strings.ToUpper(operation))
+	}
+	if rows, ok := modifiedRows(plan, operation); ok {
+		attributes["affected_rows"] = int64ToAttributeValue(rows)
+	}
+	return attributes
+}
+
+// tupleCountKeys are the keys of the tuple counts of the ModifyTable nodes by
+// operation.
+var tupleCountKeys = map[string]string{
+	"Insert": "Tuples Inserted",
+	"Update": "Tuples Updated",
+	"Delete": "Tuples Deleted",
+}
+
+// modifiedRows returns the number of rows written by a ModifyTable node.
+func modifiedRows(plan map[string]interface{}, operation string) (int64, bool) {
+	if tuples, ok := plan[tupleCountKeys[operation]].(float64); ok {
+		return int64(tuples), true
+	}
+	children, _ := plan["Plans"].([]interface{})
+	if len(children) == 0 {
+		return 0, false
+	}
+	child, _ := children[0].(map[string]interface{})
+	rows, ok := child["Actual Rows"].(float64)
+	return int64(rows), ok
+}
@@ -0,0 +1,68 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	listenerMinReconnectInterval = time.Second
+	listenerMaxReconnectInterval = time.Minute
+)
+
+// newListener returns a listener on the database of connStr, which reconnects
+// by itself when the connection is lost.
+func newListener(logger logger, connStr, serviceName string) *pq.Listener {
+	return pq.NewListener(connStr, listenerMinReconnectInterval, listenerMaxReconnectInterval, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			logger.Printf("Lost the notification connection for service %q: %v", serviceName, err)
+		case pq.ListenerEventConnectionAttemptFailed:
+			logger.Printf("Failed to reconnect the notification connection for service %q: %v", serviceName, err)
+		case pq.ListenerEventReconnected:
+			logger.Printf("Reconnected the notification connection for service %q", serviceName)
+		}
+	})
+}
+
+// listen listens on channel with the listener of conn and hands conn over to
+// notifications every time a plan is available, until the listener is closed.
+// It does not wait for the poll loop: when a poll of the connection is
+// already pending the notification is dropped, the pending poll reads the
+// new plans too.
+func (conn *connection) listen(channel string, notifications chan<- *connection) {
+	// Listen waits for the first connection.
+	if err := conn.listener.Listen(channel); err != nil {
+		conn.logger.Printf("Failed to listen on %q for service %q: %v", channel, conn.config.ServiceName, err)
+		return
+	}
+	forwardNotifications(conn.listener.Notify, conn, notifications)
+}
+
+// forwardNotifications hands conn over to notifications for every
+// notification of notify, until notify is closed.
+func forwardNotifications(notify <-chan *pq.Notification, conn *connection, notifications chan<- *connection) {
+	// A nil notification follows a reconnection, notifications may have
+	// been lost meanwhile so it triggers a poll too.
+	for range notify {
+		select {
+		case notifications <- conn:
+		default:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"database/sql"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestForwardNotifications(t *testing.T) {
+	conn := &connection{}
+	notify := make(chan *pq.Notification, 3)
+	// The nil notification follows a reconnection.
+	notify <- &pq.Notification{Channel: "plans_available"}
+	notify <- nil
+	notify <- &pq.Notification{Channel: "plans_available"}
+	close(notify)
+
+	// Notifications are not queued while a poll is pending.
+	notifications := make(chan *connection, 1)
+	forwardNotifications(notify, conn, notifications)
+	if got := len(notifications); got != 1 {
+		t.Fatalf("Got %d pending polls, want 1", got)
+	}
+	if got := <-notifications; got != conn {
+		t.Errorf("Got connection %p, want %p", got, conn)
+	}
+}
+
+func TestPollLoopPollsNotifiedConnection(t *testing.T) {
+	// The database is unreachable, the poll fails but is still counted.
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	pgr := &PostgresReceiver{
+		pullCommand:   "select 1",
+		notifications: make(chan *connection, 1),
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go pgr.pollLoop(time.Hour, stopCh, &slowProcessor{})
+
+	pgr.notifications <- &connection{db: db, querier: dbQuerier{db}}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&pgr.counters.polls) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := atomic.LoadInt64(&pgr.counters.polls); got != 1 {
+		t.Errorf("Got %d polls after a notification, want 1", got)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+// Option is an option to New.
+type Option func(*PostgresReceiver)
+
+// WithPlanParser makes the receiver convert the plans returned by the pull
+// command with parser instead of the built-in parser of PostgreSQL JSON
+// plans, to support other plan formats. The configuration of the built-in
+// parser, such as min_node_duration, does not apply to parser.
+func WithPlanParser(parser PlanParser) Option {
+	return func(pgr *PostgresReceiver) {
+		pgr.parser = parser
+		pgr.customParser = true
+	}
+}
+
+// WithErrorHandler makes the receiver call onError with every error of the
+// polls, such as a failed pull command, an unreadable row, an invalid plan or
+// traces rejected by the next processor, in addition to logging them. The
+// errors are *PollError values telling what failed. onError is called
+// concurrently by the polls of the databases and must not block them.
+func WithErrorHandler(onError func(error)) Option {
+	return func(pgr *PostgresReceiver) {
+		pgr.onError = onError
+	}
+}
+
+// WithClock makes the receiver read the time from clock, pace its polls with
+// the tickers of clock and time its waits with the timers of clock instead of
+// the time package, for deterministic tests of the polls and of their timing,
+// see FakeClock.
+func WithClock(clock Clock) Option {
+	return func(pgr *PostgresReceiver) {
+		pgr.clock = clock
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+const (
+	// startedState is the value of the "state" field of the payloads
+	// reporting that a query started, see Config.PartialSpans.
+	startedState = "started"
+
+	defaultCorrelationField = "correlation_id"
+
+	// maxStartedQueries bounds the number of started queries waiting for
+	// their completion, the oldest ones are forgotten first.
+	maxStartedQueries = 10000
+)
+
+// startedQuery holds the IDs of the root span emitted when a query started,
+// which are reused by the span of its completion.
+type startedQuery struct {
+	correlationID string
+	traceID       []byte
+	spanID        []byte
+	parentSpanID  []byte
+}
+
+// startedQueries are the started queries by correlation ID, in the order they
+// started. They are shared by the polls of all the databases.
+type startedQueries struct {
+	mu      sync.Mutex
+	queries map[string]*list.Element
+	order   *list.List
+}
+
+func newStartedQueries() *startedQueries {
+	return &startedQueries{queries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (sq *startedQueries) add(query startedQuery) {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	if element, ok := sq.queries[query.correlationID]; ok {
+		sq.order.Remove(element)
+	}
+	sq.queries[query.correlationID] = sq.order.PushBack(query)
+	for sq.order.Len() > maxStartedQueries {
+		oldest := sq.order.Remove(sq.order.Front()).(startedQuery)
+		delete(sq.queries, oldest.correlationID)
+	}
+}
+
+// take returns and forgets the started query with the correlation ID.
+func (sq *startedQueries) take(correlationID string) (startedQuery, bool) {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	element, ok := sq.queries[correlationID]
+	if !ok {
+		return startedQuery{}, false
+	}
+	delete(sq.queries, correlationID)
+	return sq.order.Remove(element).(startedQuery), true
+}
+
+// isStartedPayload reports whether plan only reports that its query started.
+func isStartedPayload(plan map[string]interface{}) bool {
+	state, _ := plan["state"].(string)
+	return state == startedState
+}
+
+// correlationID returns the value of the correlation field of plan, or "" if
+// partial spans are disabled or the plan has none.
+func (pp *planParser) correlationID(plan map[string]interface{}) string {
+	if pp.started == nil {
+		return ""
+	}
+	switch id := plan[pp.correlationField].(type) {
+	case string:
+		return id
+	case float64:
+		return fmt.Sprint(int64(id))
+	default:
+		return ""
+	}
+}
+
+// parseStartedPlan returns the zero length root span of a query which just
+// started, with a "started" annotation, and remembers its IDs for the span
+// of the completion of the query.
+func (pp *planParser) parseStartedPlan(plan map[string]interface{}, correlationID string) ([]*tracepb.Span, error) {
+	start_time, err := pp.planStartTime(plan)
+	if err != nil {
+		return nil, err
+	}
+	query := startedQuery{correlationID: correlationID, traceID: generateTraceId(), spanID: generateSpanId()}
+	if parent_trace_id, parent_span_id, ok := pp.parentOfPlan(plan); ok {
+		query.traceID, query.parentSpanID = parent_trace_id, parent_span_id
+	}
+
+	attributes := map[string]*tracepb.AttributeValue{
+		pp.correlationField: stringToAttributeValue(correlationID),
+		"in_progress":       boolToAttributeValue(true),
+	}
+	if query_text, ok := plan["Query Text"].(string); ok {
+		attributes["query"] = stringToAttributeValue(query_text)
+	}
+	if database_name, ok := plan["database_name"].(string); ok {
+		attributes["database_name"] = stringToAttributeValue(database_name)
+	}
+	pp.started.add(query)
+	return []*tracepb.Span{{
+		TraceId:      query.traceID,
+		SpanId:       query.spanID,
+		ParentSpanId: query.parentSpanID,
+		Name:         &tracepb.TruncatableString{Value: "CloudSQLQuery"},
+		StartTime:    internal.TimeToTimestamp(start_time),
+		EndTime:      internal.TimeToTimestamp(start_time),
+		Attributes:   &tracepb.Span_Attributes{AttributeMap: attributes},
+		TimeEvents:   startedTimeEvents(start_time),
+	}}, nil
+}
+
+func startedTimeEvents(at time.Time) *tracepb.Span_TimeEvents {
+	return &tracepb.Span_TimeEvents{
+		TimeEvent: []*tracepb.Span_TimeEvent{{
+			Time: internal.TimeToTimestamp(at),
+			Value: &tracepb.Span_TimeEvent_Annotation_{
+				Annotation: &tracepb.Span_TimeEvent_Annotation{
+					Description: &tracepb.TruncatableString{Value: startedState},
+				},
+			},
+		}},
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+const startedPayload = `{
+	"state": "started", "correlation_id": "q-42",
+	"start timestamp": 1546300800, "Query Text": "select pg_sleep(600)"
+}`
+
+const completedPayload = `{
+	"correlation_id": "q-42",
+	"start timestamp": 1546300800, "duration": 600, "Query Text": "select pg_sleep(600)",
+	"username": "postgres", "session_username": "postgres", "connection_id": 42,
+	"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 600000, "Actual Rows": 1}
+}`
+
+func TestParsePartialSpans(t *testing.T) {
+	pp := newPlanParser(&Config{PartialSpans: true, ValidatePlans: true})
+
+	spans, err := pp.Parse([]byte(startedPayload))
+	if err != nil {
+		t.Fatalf("Failed to parse the started payload: %v", err)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("Got %d spans for the started payload, want 1", len(spans))
+	}
+	started := spans[0]
+	if !started.Attributes.AttributeMap["in_progress"].GetBoolValue() {
+		t.Error("Got no in_progress attribute on the started span")
+	}
+	if got := started.TimeEvents.GetTimeEvent()[0].GetAnnotation().GetDescription().GetValue(); got != "started" {
+		t.Errorf("Got annotation %q, want %q", got, "started")
+	}
+	if start, end := internal.TimestampToTime(started.StartTime), internal.TimestampToTime(started.EndTime); !start.Equal(end) {
+		t.Errorf("Got a started span lasting %v, want 0", end.Sub(start))
+	}
+
+	spans, err = pp.Parse([]byte(completedPayload))
+	if err != nil {
+		t.Fatalf("Failed to parse the completed payload: %v", err)
+	}
+	completed := spanByName(spans, "CloudSQLQuery")
+	if !bytes.Equal(completed.TraceId, started.TraceId) || !bytes.Equal(completed.SpanId, started.SpanId) {
+		t.Errorf("Got the completed span %x/%x, want the IDs of the started span %x/%x", completed.TraceId, completed.SpanId, started.TraceId, started.SpanId)
+	}
+	if got := completed.Attributes.AttributeMap["correlation_id"].GetStringValue().GetValue(); got != "q-42" {
+		t.Errorf("Got correlation_id %q, want %q", got, "q-42")
+	}
+	if _, ok := completed.Attributes.AttributeMap["in_progress"]; ok {
+		t.Error("Got in_progress on the completed span")
+	}
+	result := spanByName(spans, "Result")
+	if !bytes.Equal(result.ParentSpanId, started.SpanId) || !bytes.Equal(result.TraceId, started.TraceId) {
+		t.Error("Got the plan node spans outside the trace of the started span")
+	}
+	if want := time.Unix(1546300800, 0).Add(600 * time.Second); !internal.TimestampToTime(completed.EndTime).Equal(want) {
+		t.Errorf("Got end time %v, want %v", internal.TimestampToTime(completed.EndTime), want)
+	}
+
+	// The started query is forgotten once completed.
+	spans, err = pp.Parse([]byte(completedPayload))
+	if err != nil {
+		t.Fatalf("Failed to parse the completed payload: %v", err)
+	}
+	if bytes.Equal(spanByName(spans, "CloudSQLQuery").SpanId, started.SpanId) {
+		t.Error("Got the IDs of an already completed query reused")
+	}
+}
+
+func TestParsePartialSpansDisabled(t *testing.T) {
+	// Without partial spans the started payloads are invalid plans.
+	if _, err := newPlanParser(&Config{ValidatePlans: true}).Parse([]byte(startedPayload)); err == nil {
+		t.Error("Got no error for a started payload with partial spans disabled")
+	}
+}
+
+func TestStartedQueriesBounded(t *testing.T) {
+	sq := newStartedQueries()
+	for i := 0; i < maxStartedQueries+1; i++ {
+		sq.add(startedQuery{correlationID: fmt.Sprint(i)})
+	}
+	if _, ok := sq.take("0"); ok {
+		t.Error("Got the oldest started query kept past the limit")
+	}
+	if _, ok := sq.take(fmt.Sprint(maxStartedQueries)); !ok {
+		t.Error("Got the newest started query forgotten")
+	}
+}
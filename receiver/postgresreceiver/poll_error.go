@@ -0,0 +1,73 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"fmt"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// The operations of a poll that can fail, see PollError.
+const (
+	// OpLookup is the lookup of the current database or of its role.
+	OpLookup = "lookup"
+	// OpPull is the run of the pull command.
+	OpPull = "pull"
+	// OpScan is the scan of a row returned by the pull command.
+	OpScan = "scan"
+	// OpParse is the unmarshaling and conversion of a plan into spans.
+	OpParse = "parse"
+	// OpRead is the iteration over the rows returned by the pull command.
+	OpRead = "read"
+	// OpPush is the processing of the traces by the next processor.
+	OpPush = "push"
+	// OpAck is the acknowledgement of the processed rows, see
+	// Config.AckCommand.
+	OpAck = "ack"
+)
+
+// PollError is an error of a poll, as passed to the handler set by
+// WithErrorHandler.
+type PollError struct {
+	// Op is the operation that failed, one of the Op constants.
+	Op string
+	// ServiceName is the service name of the database, empty when it has
+	// none.
+	ServiceName string
+	Err         error
+}
+
+func (e *PollError) Error() string {
+	return fmt.Sprintf("postgres receiver %s failed for service %q: %v", e.Op, e.ServiceName, e.Err)
+}
+
+// handleError passes the error err of op to the error handler, if any.
+func (pgr *PostgresReceiver) handleError(op, serviceName string, err error) {
+	if pgr.onError != nil {
+		pgr.onError(&PollError{Op: op, ServiceName: serviceName, Err: err})
+	}
+}
+
+// handlePushError logs the error err of the push of td, if any, and passes
+// it to the error handler.
+func (pgr *PostgresReceiver) handlePushError(td data.TraceData, err error) {
+	if err == nil {
+		return
+	}
+	serviceName := td.Node.GetServiceInfo().GetName()
+	pgr.logger.Printf("Next processor failed for service %q: %v", serviceName, err)
+	pgr.handleError(OpPush, serviceName, err)
+}
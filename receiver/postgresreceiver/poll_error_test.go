@@ -0,0 +1,102 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// failingQuerier fails every query with err.
+type failingQuerier struct {
+	err error
+}
+
+func (fq failingQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (planRows, error) {
+	return nil, fq.err
+}
+
+// failingProcessor rejects every TraceData with err.
+type failingProcessor struct {
+	err error
+}
+
+func (fp failingProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	return fp.err
+}
+
+// recordErrors returns an error handler appending the errors to errs.
+func recordErrors(errs *[]*PollError) func(error) {
+	return func(err error) {
+		*errs = append(*errs, err.(*PollError))
+	}
+}
+
+func TestErrorHandlerPullErrors(t *testing.T) {
+	connRefused := errors.New("connection refused")
+	var errs []*PollError
+	pgr := &PostgresReceiver{pullCommand: "select * from google_trace()", parser: defaultPlanParser}
+	WithErrorHandler(recordErrors(&errs))(pgr)
+	conn := &connection{config: ConnectionConfig{ServiceName: "orders", Role: RoleAuto}, querier: failingQuerier{connRefused}}
+
+	pgr.processExecutionPlan(context.Background(), conn, &recordingProcessor{})
+
+	want := []*PollError{
+		{Op: OpLookup, ServiceName: "orders", Err: connRefused},
+		{Op: OpLookup, ServiceName: "orders", Err: connRefused},
+		{Op: OpLookup, ServiceName: "orders", Err: connRefused},
+		{Op: OpPull, ServiceName: "orders", Err: connRefused},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("Got errors %v, want %v", errs, want)
+	}
+}
+
+func TestErrorHandlerRowErrors(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	queueFull := errors.New("queue full")
+	var errs []*PollError
+	pgr := &PostgresReceiver{parser: defaultPlanParser}
+	WithErrorHandler(recordErrors(&errs))(pgr)
+	conn := &connection{config: ConnectionConfig{ServiceName: "orders"}}
+	rows := &faultyRows{plans: []string{"{not json", plan}}
+
+	if _, err := pgr.processRows(context.Background(), conn, rows, failingProcessor{queueFull}); err != nil {
+		t.Fatalf("processRows() error = %v", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Got %d errors, want 2: %v", len(errs), errs)
+	}
+	if errs[0].Op != OpParse || errs[0].ServiceName != "orders" {
+		t.Errorf("Got error %v, want a parse error for service orders", errs[0])
+	}
+	if want := (&PollError{Op: OpPush, ServiceName: "orders", Err: queueFull}); !reflect.DeepEqual(errs[1], want) {
+		t.Errorf("Got error %v, want %v", errs[1], want)
+	}
+}
+
+func TestErrorHandlerUnset(t *testing.T) {
+	pgr := &PostgresReceiver{pullCommand: "select 1", parser: defaultPlanParser}
+	// The errors are only logged.
+	pgr.processExecutionPlan(context.Background(), &connection{querier: failingQuerier{errors.New("connection refused")}}, &recordingProcessor{})
+}
@@ -0,0 +1,698 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/observability"
+	"github.com/census-instrumentation/opencensus-service/observability/observabilitytest"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// slowProcessor takes delay to process every TraceData and tracks the
+// maximum number of concurrent calls.
+type slowProcessor struct {
+	delay         time.Duration
+	inFlight      int32
+	maxInFlight   int32
+	processedData int32
+}
+
+func (sp *slowProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	inFlight := atomic.AddInt32(&sp.inFlight, 1)
+	defer atomic.AddInt32(&sp.inFlight, -1)
+	for {
+		maxSoFar := atomic.LoadInt32(&sp.maxInFlight)
+		if inFlight <= maxSoFar || atomic.CompareAndSwapInt32(&sp.maxInFlight, maxSoFar, inFlight) {
+			break
+		}
+	}
+	time.Sleep(sp.delay)
+	atomic.AddInt32(&sp.processedData, 1)
+	return nil
+}
+
+func TestRunExclusiveSkipsOverlappingPolls(t *testing.T) {
+	conn := &connection{}
+	sp := &slowProcessor{delay: 50 * time.Millisecond}
+	poll := func() {
+		sp.ProcessTraceData(context.Background(), data.TraceData{})
+	}
+
+	done := make(chan bool)
+	go func() {
+		done <- conn.runExclusive(context.Background(), poll)
+	}()
+
+	// Wait for the first poll to be in progress, then fire overlapping ticks.
+	for atomic.LoadInt32(&sp.inFlight) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		if conn.runExclusive(context.Background(), poll) {
+			t.Fatal("Got an overlapping poll run")
+		}
+	}
+
+	if !<-done {
+		t.Fatal("Got the first poll skipped")
+	}
+	if got := atomic.LoadInt32(&sp.maxInFlight); got != 1 {
+		t.Errorf("Got %d concurrent polls, want 1", got)
+	}
+	if got := atomic.LoadInt32(&sp.processedData); got != 1 {
+		t.Errorf("Got %d processed polls, want 1", got)
+	}
+
+	// Once the slow poll is over, the next one runs.
+	if !conn.runExclusive(context.Background(), poll) {
+		t.Error("Got the poll following a finished one skipped")
+	}
+}
+
+// faultyRows returns plans, then fails with err as a broken connection would.
+type faultyRows struct {
+	plans []string
+	err   error
+	next  int
+}
+
+func (fr *faultyRows) Next() bool {
+	if fr.next >= len(fr.plans) {
+		return false
+	}
+	fr.next++
+	return true
+}
+
+func (fr *faultyRows) Scan(dest ...interface{}) error {
+	*dest[0].(*int) = fr.next
+	*dest[1].(*[]byte) = []byte(fr.plans[fr.next-1])
+	return nil
+}
+
+func (fr *faultyRows) Err() error {
+	return fr.err
+}
+
+func (fr *faultyRows) Close() error {
+	return nil
+}
+
+func TestProcessRowsReportsRowsError(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres",
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	connReset := errors.New("read: connection reset by peer")
+
+	for _, batchRows := range []bool{false, true} {
+		pgr := &PostgresReceiver{config: Config{BatchRows: batchRows}, parser: defaultPlanParser}
+		sp := &slowProcessor{}
+		rows := &faultyRows{plans: []string{plan, plan}, err: connReset}
+		rowsProcessed, err := pgr.processRows(context.Background(), &connection{}, rows, sp)
+		if err != connReset {
+			t.Errorf("batchRows=%v: got error %v, want %v", batchRows, err, connReset)
+		}
+		if rowsProcessed != 2 {
+			t.Errorf("batchRows=%v: got %d rows processed, want 2", batchRows, rowsProcessed)
+		}
+		// The plans read before the error are still sent.
+		if got := atomic.LoadInt32(&sp.processedData); got == 0 {
+			t.Errorf("batchRows=%v: got no plan sent", batchRows)
+		}
+	}
+}
+
+// upperCaseParser is a PlanParser for plans made of a span name only.
+type upperCaseParser struct{}
+
+func (upperCaseParser) Parse(raw []byte) ([]*tracepb.Span, error) {
+	return []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: strings.ToUpper(string(raw))}}}, nil
+}
+
+func TestProcessRowsWithPlanParser(t *testing.T) {
+	pgr := &PostgresReceiver{
+		config:          Config{BatchRows: true},
+		conns:           []*connection{{}},
+		pullInterval:    time.Second,
+		intervalChanges: make(chan time.Duration, 1),
+	}
+	WithPlanParser(upperCaseParser{})(pgr)
+	// The custom parser is kept when the receiver is reconfigured.
+	if err := pgr.Reconfigure(&Config{PullCommand: "select 1", PullInterval: time.Second, BatchRows: true}); err != nil {
+		t.Fatalf("Reconfigure() error = %v", err)
+	}
+
+	rp := &recordingProcessor{}
+	rows := &faultyRows{plans: []string{"seq scan", "sort"}}
+	if _, err := pgr.processRows(context.Background(), &connection{}, rows, rp); err != nil {
+		t.Fatalf("processRows() error = %v", err)
+	}
+	if len(rp.traces) != 1 || len(rp.traces[0].Spans) != 2 {
+		t.Fatalf("Got traces %v, want a single one with 2 spans", rp.traces)
+	}
+	for i, want := range []string{"SEQ SCAN", "SORT"} {
+		if got := rp.traces[0].Spans[i].Name.GetValue(); got != want {
+			t.Errorf("Got span name %q, want %q", got, want)
+		}
+	}
+}
+
+func TestNewWithPlanParser(t *testing.T) {
+	// Without init command, no connection is made until the first poll.
+	pgr, err := New(&Config{ConnStr: "host=localhost sslmode=disable", PullCommand: "select 1", PullInterval: time.Second}, WithPlanParser(upperCaseParser{}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer pgr.StopTraceReception(context.Background())
+	if _, ok := pgr.parser.(upperCaseParser); !ok {
+		t.Errorf("Got parser %T, want upperCaseParser", pgr.parser)
+	}
+}
+
+type recordingProcessor struct {
+	traces []data.TraceData
+}
+
+func (rp *recordingProcessor) ProcessTraceData(ctx context.Context, td data.TraceData) error {
+	rp.traces = append(rp.traces, td)
+	return nil
+}
+
+func TestReconfigure(t *testing.T) {
+	// Without init command, opening a connection pool does not connect.
+	config := &Config{ConnStr: "dbname=orders sslmode=disable", PullCommand: "select 1", PullInterval: time.Hour}
+	pgr, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer pgr.StopTraceReception(context.Background())
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go pgr.pollLoop(config.PullInterval, stopCh, &slowProcessor{})
+	orders := pgr.conns[0]
+
+	// Only the interval changes, the pool is kept.
+	newConfig := *config
+	newConfig.PullInterval = 2 * time.Hour
+	if err := pgr.Reconfigure(&newConfig); err != nil {
+		t.Fatalf("Reconfigure() error = %v", err)
+	}
+	if len(pgr.conns) != 1 || pgr.conns[0] != orders {
+		t.Errorf("Got connections %v, want the existing one", pgr.conns)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(pgr.intervalChanges) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if len(pgr.intervalChanges) > 0 {
+		t.Error("Got the new interval not picked up by the poll loop")
+	}
+
+	// The database changes, the pool is replaced and the old one closed.
+	newConfig.ConnStr = "dbname=users sslmode=disable"
+	if err := pgr.Reconfigure(&newConfig); err != nil {
+		t.Fatalf("Reconfigure() error = %v", err)
+	}
+	if len(pgr.conns) != 1 || pgr.conns[0] == orders || pgr.conns[0].config.ConnStr != newConfig.ConnStr {
+		t.Errorf("Got connections %v, want a new one for %q", pgr.conns, newConfig.ConnStr)
+	}
+	if err := orders.db.Ping(); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("Got ping error %v on the replaced pool, want it closed", err)
+	}
+}
+
+func TestReconfigureCredentials(t *testing.T) {
+	config := &Config{
+		ConnStr: "dbname=orders sslmode=disable", PullCommand: "select 1", PullInterval: time.Hour,
+		AuthMethod: AuthPassword, User: "collector", Password: "old-secret",
+	}
+	pgr, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer pgr.StopTraceReception(context.Background())
+	orders := pgr.conns[0]
+
+	// Only the password is rotated, the pool must reconnect with it.
+	newConfig := *config
+	newConfig.Password = "new-secret"
+	if err := pgr.Reconfigure(&newConfig); err != nil {
+		t.Fatalf("Reconfigure() error = %v", err)
+	}
+	if len(pgr.conns) != 1 || pgr.conns[0] == orders {
+		t.Errorf("Got connections %v, want a new one for the rotated password", pgr.conns)
+	}
+	if err := orders.db.Ping(); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("Got ping error %v on the replaced pool, want it closed", err)
+	}
+}
+
+func TestProcessRowsBatchRows(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres",
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	conn := &connection{config: ConnectionConfig{ServiceName: "orders"}}
+
+	tests := []struct {
+		name           string
+		batchRows      bool
+		wantSpansPerTD []int
+	}{
+		{name: "per row", wantSpansPerTD: []int{2, 2, 2}},
+		{name: "batch", batchRows: true, wantSpansPerTD: []int{6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pgr := &PostgresReceiver{config: Config{BatchRows: tt.batchRows}, parser: defaultPlanParser}
+			rp := &recordingProcessor{}
+			rows := &faultyRows{plans: []string{plan, plan, plan}}
+			if _, err := pgr.processRows(context.Background(), conn, rows, rp); err != nil {
+				t.Fatalf("processRows() error = %v", err)
+			}
+
+			if len(rp.traces) != len(tt.wantSpansPerTD) {
+				t.Fatalf("Got %d TraceData, want %d", len(rp.traces), len(tt.wantSpansPerTD))
+			}
+			for i, td := range rp.traces {
+				if got := len(td.Spans); got != tt.wantSpansPerTD[i] {
+					t.Errorf("Got %d spans in TraceData %d, want %d", got, i, tt.wantSpansPerTD[i])
+				}
+				if got := td.Node.GetServiceInfo().GetName(); got != "orders" {
+					t.Errorf("Got service name %q, want %q", got, "orders")
+				}
+			}
+		})
+	}
+}
+
+func TestProcessRowsDefaultDatabaseName(t *testing.T) {
+	withoutDatabase := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	withDatabase := strings.Replace(withoutDatabase, `"connection_id": 42,`, `"connection_id": 42, "database_name": "users",`, 1)
+
+	pgr := &PostgresReceiver{parser: defaultPlanParser}
+	rp := &recordingProcessor{}
+	rows := &faultyRows{plans: []string{withoutDatabase, withDatabase}}
+	if _, err := pgr.processRows(context.Background(), &connection{databaseName: "orders"}, rows, rp); err != nil {
+		t.Fatalf("processRows() error = %v", err)
+	}
+	if len(rp.traces) != 2 {
+		t.Fatalf("Got %d traces, want 2", len(rp.traces))
+	}
+	for i, want := range []string{"orders", "users"} {
+		root := rp.traces[i].Spans[len(rp.traces[i].Spans)-1]
+		if got := root.Attributes.AttributeMap["database_name"].GetStringValue().GetValue(); got != want {
+			t.Errorf("Got database_name %q, want %q", got, want)
+		}
+		for _, span := range rp.traces[i].Spans[:len(rp.traces[i].Spans)-1] {
+			if _, ok := span.Attributes.AttributeMap["database_name"]; ok {
+				t.Errorf("Got database_name on the plan node span %q", span.Name.GetValue())
+			}
+		}
+	}
+}
+
+func TestProcessRowsDistinctQueries(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select * from users where id = 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	sameFingerprint := strings.Replace(plan, "id = 1", "id = 2", 1)
+	otherQuery := strings.Replace(plan, "from users", "from orders", 1)
+
+	if err := view.Register(ViewDistinctQueries); err != nil {
+		t.Fatalf("view.Register() error = %v", err)
+	}
+	defer view.Unregister(ViewDistinctQueries)
+
+	pgr := &PostgresReceiver{parser: defaultPlanParser}
+	rows := &faultyRows{plans: []string{plan, sameFingerprint, otherQuery}}
+	if _, err := pgr.processRows(context.Background(), &connection{}, rows, &recordingProcessor{}); err != nil {
+		t.Fatalf("processRows() error = %v", err)
+	}
+	rowData, err := view.RetrieveData(ViewDistinctQueries.Name)
+	if err != nil {
+		t.Fatalf("view.RetrieveData() error = %v", err)
+	}
+	if len(rowData) != 1 {
+		t.Fatalf("Got %d rows, want 1", len(rowData))
+	}
+	if got := rowData[0].Data.(*view.LastValueData).Value; got != 2 {
+		t.Errorf("Got %v distinct queries, want 2", got)
+	}
+}
+
+func TestProcessRowsMaxPlanBytes(t *testing.T) {
+	small := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	large := strings.Replace(small, "select 1", "select '"+strings.Repeat("x", 1024)+"'", 1)
+
+	if err := view.Register(ViewOversizedPlans); err != nil {
+		t.Fatalf("view.Register() error = %v", err)
+	}
+	defer view.Unregister(ViewOversizedPlans)
+
+	pgr := &PostgresReceiver{config: Config{MaxPlanBytes: 1024}, parser: defaultPlanParser}
+	rp := &recordingProcessor{}
+	rows := &faultyRows{plans: []string{small, large}}
+	if _, err := pgr.processRows(context.Background(), &connection{}, rows, rp); err != nil {
+		t.Fatalf("processRows() error = %v", err)
+	}
+	if len(rp.traces) != 1 {
+		t.Fatalf("Got %d traces, want the one of the small plan", len(rp.traces))
+	}
+	rowData, err := view.RetrieveData(ViewOversizedPlans.Name)
+	if err != nil {
+		t.Fatalf("view.RetrieveData() error = %v", err)
+	}
+	if len(rowData) != 1 || rowData[0].Data.(*view.SumData).Value != 1 {
+		t.Errorf("Got oversized plans %v, want a count of 1", rowData)
+	}
+}
+
+// clockQuerier advances clock by delay while running the pull command.
+type clockQuerier struct {
+	*fakeQuerier
+	clock       *FakeClock
+	pullCommand string
+	delay       time.Duration
+}
+
+func (cq *clockQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (planRows, error) {
+	if query == cq.pullCommand {
+		cq.clock.Advance(cq.delay)
+	}
+	return cq.fakeQuerier.QueryContext(ctx, query, args...)
+}
+
+func TestPollConnectionMetrics(t *testing.T) {
+	defer observabilitytest.SetupRecordedMetricsTest(t)()
+	if err := view.Register(ViewPollDuration, ViewRowsProcessed); err != nil {
+		t.Fatalf("view.Register() error = %v", err)
+	}
+	defer view.Unregister(ViewPollDuration, ViewRowsProcessed)
+
+	clock := NewFakeClock(time.Unix(1546300800, 0))
+	pgr := &PostgresReceiver{
+		name:        "orders",
+		pullCommand: "select * from google_trace()",
+		parser:      defaultPlanParser,
+		clock:       clock,
+	}
+	fq := &fakeQuerier{databaseName: "orders", serverVersion: "160002", plans: []string{resultPlan, resultPlan, resultPlan}}
+	conn := &connection{querier: &clockQuerier{fakeQuerier: fq, clock: clock, pullCommand: pgr.pullCommand, delay: 25 * time.Millisecond}}
+	pgr.pollConnection(conn, &recordingProcessor{})
+
+	// A query span and a plan node span per row.
+	observabilitytest.CheckValueViewReceiverReceivedSpans(t, receiverName, 6)
+	observabilitytest.CheckValueViewReceiverDroppedSpans(t, receiverName, 0)
+
+	wantTags := []tag.Tag{
+		{Key: observability.TagKeyReceiver, Value: receiverName},
+		{Key: TagKeyReceiverName, Value: "orders"},
+	}
+	rowData, err := view.RetrieveData(ViewPollDuration.Name)
+	if err != nil {
+		t.Fatalf("view.RetrieveData() error = %v", err)
+	}
+	if len(rowData) != 1 || !reflect.DeepEqual(sortedTags(rowData[0].Tags), wantTags) {
+		t.Fatalf("Got poll duration rows %v, want one tagged %v", rowData, wantTags)
+	}
+	if dist := rowData[0].Data.(*view.DistributionData); dist.Count != 1 || dist.Mean != 25 {
+		t.Errorf("Got %d polls with a mean duration of %vms, want 1 poll of 25ms", dist.Count, dist.Mean)
+	}
+
+	rowData, err = view.RetrieveData(ViewRowsProcessed.Name)
+	if err != nil {
+		t.Fatalf("view.RetrieveData() error = %v", err)
+	}
+	if len(rowData) != 1 || !reflect.DeepEqual(sortedTags(rowData[0].Tags), wantTags) {
+		t.Fatalf("Got rows processed rows %v, want one tagged %v", rowData, wantTags)
+	}
+	if got := rowData[0].Data.(*view.SumData).Value; got != 3 {
+		t.Errorf("Got %v rows processed, want 3", got)
+	}
+}
+
+// sortedTags returns tags sorted by key name, as wantTags are.
+func sortedTags(tags []tag.Tag) []tag.Tag {
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key.Name() < tags[j].Key.Name() })
+	return tags
+}
+
+func TestValidateConfigMaxPlanBytes(t *testing.T) {
+	config := &Config{PullCommand: "select 1", PullInterval: time.Second, MaxPlanBytes: -1}
+	if err := validateConfig(config); err == nil {
+		t.Error("Got no error for a negative max_plan_bytes")
+	}
+}
+
+// planDriver is a database/sql driver whose queries return a single plan row.
+// The data source name is the type of the plan column: with "text" the plan
+// is returned as a string, with "jsonb" as bytes, as lib/pq does.
+type planDriver struct{}
+
+type planConn struct{ columnType string }
+
+type planStmt struct{ columnType string }
+
+type planResultRows struct {
+	columnType string
+	done       bool
+}
+
+func (planDriver) Open(name string) (driver.Conn, error) { return planConn{columnType: name}, nil }
+
+func (pc planConn) Prepare(query string) (driver.Stmt, error) { return planStmt(pc), nil }
+func (planConn) Close() error                                 { return nil }
+func (planConn) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+
+func (planStmt) Close() error  { return nil }
+func (planStmt) NumInput() int { return -1 }
+func (planStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (ps planStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &planResultRows{columnType: ps.columnType}, nil
+}
+
+func (*planResultRows) Columns() []string { return []string{"counter", "plan"} }
+func (*planResultRows) Close() error      { return nil }
+func (pr *planResultRows) Next(dest []driver.Value) error {
+	if pr.done {
+		return io.EOF
+	}
+	pr.done = true
+	dest[0] = int64(1)
+	if pr.columnType == "jsonb" {
+		dest[1] = []byte(resultPlan)
+	} else {
+		dest[1] = resultPlan
+	}
+	return nil
+}
+
+const resultPlan = `{
+	"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 'é'",
+	"username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres",
+	"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+}`
+
+func init() {
+	sql.Register("planrows", planDriver{})
+}
+
+func TestProcessRowsPlanColumnTypes(t *testing.T) {
+	for _, columnType := range []string{"text", "jsonb"} {
+		db, err := sql.Open("planrows", columnType)
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		rows, err := db.Query("select * from google_trace()")
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+
+		pgr := &PostgresReceiver{parser: defaultPlanParser}
+		rp := &recordingProcessor{}
+		if _, err := pgr.processRows(context.Background(), &connection{}, rows, rp); err != nil {
+			t.Fatalf("%s: processRows() error = %v", columnType, err)
+		}
+		rows.Close()
+		db.Close()
+
+		if len(rp.traces) != 1 {
+			t.Fatalf("%s: got %d traces, want 1", columnType, len(rp.traces))
+		}
+		root := spanByName(rp.traces[0].Spans, "CloudSQLQuery")
+		if got := root.Attributes.AttributeMap["query"].GetStringValue().GetValue(); got != "select 'é'" {
+			t.Errorf("%s: got query %q, want %q", columnType, got, "select 'é'")
+		}
+	}
+}
+
+// databaseNameRows is the result of select current_database(), or of another
+// query returning a single text value.
+type databaseNameRows struct {
+	name string
+	read bool
+}
+
+func (dr *databaseNameRows) Next() bool {
+	if dr.read {
+		return false
+	}
+	dr.read = true
+	return true
+}
+
+func (dr *databaseNameRows) Scan(dest ...interface{}) error {
+	*dest[0].(*string) = dr.name
+	return nil
+}
+
+func (dr *databaseNameRows) Err() error   { return nil }
+func (dr *databaseNameRows) Close() error { return nil }
+
+// fakeQuerier answers the current database lookup with databaseName, the
+// server version lookup with serverVersion and every other query with plans.
+type fakeQuerier struct {
+	databaseName  string
+	serverVersion string
+	plans         []string
+	queries       []string
+}
+
+func (fq *fakeQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (planRows, error) {
+	fq.queries = append(fq.queries, query)
+	if query == "select current_database()" {
+		return &databaseNameRows{name: fq.databaseName}, nil
+	}
+	if query == "show server_version_num" {
+		return &databaseNameRows{name: fq.serverVersion}, nil
+	}
+	return &faultyRows{plans: fq.plans}, nil
+}
+
+func TestProcessExecutionPlanWithFakeQuerier(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	fq := &fakeQuerier{databaseName: "orders", serverVersion: "160002", plans: []string{plan, plan}}
+	pgr := &PostgresReceiver{pullCommand: "select * from google_trace()", parser: defaultPlanParser}
+	conn := &connection{querier: fq}
+	rp := &recordingProcessor{}
+
+	pgr.processExecutionPlan(context.Background(), conn, rp)
+	// The current database and the server version are only looked up once.
+	pgr.processExecutionPlan(context.Background(), conn, rp)
+
+	wantQueries := []string{"select current_database()", "show server_version_num", pgr.pullCommand, pgr.pullCommand}
+	if strings.Join(fq.queries, "; ") != strings.Join(wantQueries, "; ") {
+		t.Errorf("Got queries %q, want %q", fq.queries, wantQueries)
+	}
+	if len(rp.traces) != 4 {
+		t.Fatalf("Got %d traces, want 4", len(rp.traces))
+	}
+	root := spanByName(rp.traces[0].Spans, "CloudSQLQuery")
+	if got := root.Attributes.AttributeMap["database_name"].GetStringValue().GetValue(); got != "orders" {
+		t.Errorf("Got database_name %q, want %q", got, "orders")
+	}
+	if got := rp.traces[0].Resource.GetLabels()[serverVersionLabel]; got != "16.2" {
+		t.Errorf("Got %s %q, want %q", serverVersionLabel, got, "16.2")
+	}
+	if got := atomic.LoadInt64(&pgr.counters.rows); got != 4 {
+		t.Errorf("Got %d rows counted, want 4", got)
+	}
+}
+
+func TestProcessExecutionPlanMaxConcurrentConnections(t *testing.T) {
+	config := &Config{PullCommand: "select * from google_trace()", MaxConcurrentConnections: 2}
+	pgr := &PostgresReceiver{pullCommand: config.PullCommand, parser: defaultPlanParser, pollSlots: newPollSlots(config)}
+	for i := 0; i < 5; i++ {
+		fq := &fakeQuerier{databaseName: "orders", serverVersion: "160002", plans: []string{resultPlan}}
+		pgr.conns = append(pgr.conns, &connection{querier: fq})
+	}
+	sp := &slowProcessor{delay: 20 * time.Millisecond}
+
+	pgr.ProcessExecutionPlan(sp)
+
+	if got := atomic.LoadInt32(&sp.processedData); got != 5 {
+		t.Errorf("Got %d processed plans, want 5", got)
+	}
+	if got := atomic.LoadInt32(&sp.maxInFlight); got != 2 {
+		t.Errorf("Got %d concurrent polls, want 2", got)
+	}
+}
+
+func TestValidateConfigMaxConcurrentConnections(t *testing.T) {
+	config := &Config{PullCommand: "select 1", PullInterval: time.Second, MaxConcurrentConnections: -1}
+	if err := validateConfig(config); err == nil {
+		t.Error("Got no error for a negative max_concurrent_connections")
+	}
+}
+
+// BenchmarkProcessRows measures the row by row reading of the pull command
+// result, the baseline for a bulk read. lib/pq supports neither COPY TO STDOUT
+// nor binary COPY, which rules out streaming the plans with COPY.
+func BenchmarkProcessRows(b *testing.B) {
+	const rowsPerPoll = 1000
+	plans := make([]string, rowsPerPoll)
+	for i := range plans {
+		plans[i] = resultPlan
+	}
+	pgr := &PostgresReceiver{config: Config{BatchRows: true}, parser: defaultPlanParser}
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pgr.processRows(context.Background(), &connection{}, &faultyRows{plans: plans}, &slowProcessor{}); err != nil {
+			b.Fatalf("processRows() error = %v", err)
+		}
+	}
+}
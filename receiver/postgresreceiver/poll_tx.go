@@ -0,0 +1,74 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// pollTx is the transaction a poll runs in, see Config.UseSnapshot and
+// Config.AckCommand.
+type pollTx interface {
+	querier
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Commit() error
+	Rollback() error
+}
+
+// pollTxBeginner is implemented by the queriers that can run a poll in a
+// pollTx.
+type pollTxBeginner interface {
+	beginPollTx(ctx context.Context, opts *sql.TxOptions) (pollTx, error)
+}
+
+// beginPollTx begins the transaction of a poll on q.
+func beginPollTx(ctx context.Context, q querier, opts *sql.TxOptions) (pollTx, error) {
+	beginner, ok := q.(pollTxBeginner)
+	if !ok {
+		return nil, errors.New("the connection does not support transactions")
+	}
+	return beginner.beginPollTx(ctx, opts)
+}
+
+func (q dbQuerier) beginPollTx(ctx context.Context, opts *sql.TxOptions) (pollTx, error) {
+	tx, err := q.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return txQuerier{tx}, nil
+}
+
+// txQuerier is the pollTx of a transaction of a connection pool.
+type txQuerier struct {
+	tx *sql.Tx
+}
+
+func (q txQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (planRows, error) {
+	rows, err := q.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		// Keep the interface nil.
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (q txQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return q.tx.ExecContext(ctx, query, args...)
+}
+
+func (q txQuerier) Commit() error   { return q.tx.Commit() }
+func (q txQuerier) Rollback() error { return q.tx.Rollback() }
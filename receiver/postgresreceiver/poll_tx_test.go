@@ -0,0 +1,105 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// fakePollTx answers the pull command with plans, failing with rowsErr, and
+// records the acknowledgements and the outcome of the transaction.
+type fakePollTx struct {
+	plans      []string
+	rowsErr    error
+	ackErr     error
+	acked      []interface{}
+	committed  bool
+	rolledBack bool
+}
+
+func (ft *fakePollTx) QueryContext(ctx context.Context, query string, args ...interface{}) (planRows, error) {
+	return &faultyRows{plans: ft.plans, err: ft.rowsErr}, nil
+}
+
+func (ft *fakePollTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if ft.ackErr != nil {
+		return nil, ft.ackErr
+	}
+	ft.acked = append(ft.acked, args...)
+	return nil, nil
+}
+
+func (ft *fakePollTx) Commit() error {
+	ft.committed = true
+	return nil
+}
+
+func (ft *fakePollTx) Rollback() error {
+	if !ft.committed {
+		ft.rolledBack = true
+	}
+	return nil
+}
+
+// fakeTxQuerier runs the polls in tx.
+type fakeTxQuerier struct {
+	fakeQuerier
+	tx   *fakePollTx
+	opts *sql.TxOptions
+}
+
+func (fq *fakeTxQuerier) beginPollTx(ctx context.Context, opts *sql.TxOptions) (pollTx, error) {
+	fq.opts = opts
+	return fq.tx, nil
+}
+
+func TestProcessExecutionPlanUseSnapshot(t *testing.T) {
+	tx := &fakePollTx{plans: []string{resultPlan}}
+	fq := &fakeTxQuerier{tx: tx}
+	pgr := &PostgresReceiver{
+		config:      Config{UseSnapshot: true},
+		pullCommand: "select * from google_trace()",
+		parser:      defaultPlanParser,
+	}
+	rp := &recordingProcessor{}
+	pgr.processExecutionPlan(context.Background(), &connection{querier: fq}, rp)
+
+	if fq.opts == nil || fq.opts.Isolation != sql.LevelRepeatableRead {
+		t.Errorf("Got transaction options %+v, want REPEATABLE READ", fq.opts)
+	}
+	if !tx.committed {
+		t.Error("Got the transaction of the poll not committed")
+	}
+	if len(rp.traces) != 1 {
+		t.Errorf("Got %d traces, want 1", len(rp.traces))
+	}
+}
+
+func TestProcessExecutionPlanUseSnapshotRollsBack(t *testing.T) {
+	tx := &fakePollTx{plans: []string{resultPlan}, rowsErr: errors.New("connection reset")}
+	pgr := &PostgresReceiver{
+		config:      Config{UseSnapshot: true},
+		pullCommand: "select * from google_trace()",
+		parser:      defaultPlanParser,
+	}
+	pgr.processExecutionPlan(context.Background(), &connection{querier: &fakeTxQuerier{tx: tx}}, &recordingProcessor{})
+
+	if tx.committed || !tx.rolledBack {
+		t.Errorf("Got committed %v and rolled back %v, want a rollback", tx.committed, tx.rolledBack)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"sync"
+)
+
+// pushPool runs the pushes of the traces of a poll, up to concurrency of them
+// at a time, and collects their errors.
+type pushPool struct {
+	// slots holds a value per running push, it is nil when the pushes run
+	// sequentially.
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mu   sync.Mutex
+	errs []error
+}
+
+func newPushPool(concurrency int) *pushPool {
+	p := &pushPool{}
+	if concurrency > 1 {
+		p.slots = make(chan struct{}, concurrency)
+	}
+	return p
+}
+
+// run runs push, in the background unless the pushes are sequential. It
+// waits for a push to finish when concurrency of them are already running.
+func (p *pushPool) run(push func() error) {
+	if p.slots == nil {
+		p.collect(push())
+		return
+	}
+	p.slots <- struct{}{}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.collect(push())
+		<-p.slots
+	}()
+}
+
+func (p *pushPool) collect(err error) {
+	if err == nil {
+		return
+	}
+	p.mu.Lock()
+	p.errs = append(p.errs, err)
+	p.mu.Unlock()
+}
+
+// wait waits for the running pushes and returns the errors of all the pushes.
+func (p *pushPool) wait() []error {
+	p.wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.errs
+}
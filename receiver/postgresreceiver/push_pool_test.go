@@ -0,0 +1,76 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProcessRowsPushConcurrency(t *testing.T) {
+	plans := make([]string, 8)
+	for i := range plans {
+		plans[i] = resultPlan
+	}
+	tests := []struct {
+		concurrency     int
+		wantMaxInFlight int32
+	}{
+		{concurrency: 0, wantMaxInFlight: 1},
+		{concurrency: 1, wantMaxInFlight: 1},
+		{concurrency: 4, wantMaxInFlight: 4},
+	}
+	for _, tt := range tests {
+		pgr := &PostgresReceiver{config: Config{PushConcurrency: tt.concurrency}, parser: defaultPlanParser}
+		sp := &slowProcessor{delay: 20 * time.Millisecond}
+		if _, err := pgr.processRows(context.Background(), &connection{}, &faultyRows{plans: plans}, sp); err != nil {
+			t.Fatalf("concurrency=%d: processRows() error = %v", tt.concurrency, err)
+		}
+		// processRows returns once every trace is pushed.
+		if got := atomic.LoadInt32(&sp.processedData); got != int32(len(plans)) {
+			t.Errorf("concurrency=%d: got %d traces processed, want %d", tt.concurrency, got, len(plans))
+		}
+		if got := atomic.LoadInt32(&sp.maxInFlight); got != tt.wantMaxInFlight {
+			t.Errorf("concurrency=%d: got %d concurrent pushes, want %d", tt.concurrency, got, tt.wantMaxInFlight)
+		}
+	}
+}
+
+func TestPushPoolErrors(t *testing.T) {
+	queueFull := errors.New("queue full")
+	p := newPushPool(2)
+	for i := 0; i < 5; i++ {
+		i := i
+		p.run(func() error {
+			if i%2 == 0 {
+				return queueFull
+			}
+			return nil
+		})
+	}
+	if errs := p.wait(); len(errs) != 3 {
+		t.Errorf("Got errors %v, want 3 of them", errs)
+	}
+}
+
+func TestValidateConfigPushConcurrency(t *testing.T) {
+	config := &Config{PullCommand: "select 1", PullInterval: time.Second, PushConcurrency: -1}
+	if err := validateConfig(config); err == nil {
+		t.Error("Got no error for a negative push_concurrency")
+	}
+}
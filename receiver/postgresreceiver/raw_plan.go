@@ -0,0 +1,45 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"unicode/utf8"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// rawPlanAttribute returns the raw_plan attribute holding planJSON, truncated
+// to maxBytes on a character boundary. The TruncatedByteCount of the value
+// is the number of bytes removed.
+func (pp *planParser) rawPlanAttribute(planJSON []byte) *tracepb.AttributeValue {
+	raw := planJSON
+	if len(raw) > pp.rawPlanMaxBytes {
+		// Do not cut a character in two.
+		cut := pp.rawPlanMaxBytes
+		for cut > 0 && !utf8.RuneStart(planJSON[cut]) {
+			cut--
+		}
+		raw = planJSON[:cut]
+		pp.logger.Printf("Truncating the raw plan of %d bytes to %d bytes", len(planJSON), len(raw))
+	}
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_StringValue{
+			StringValue: &tracepb.TruncatableString{
+				Value:              string(raw),
+				TruncatedByteCount: int32(len(planJSON) - len(raw)),
+			},
+		},
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// DatabaseRole is the role of a database in a replicated setup. It is
+// reported in the db.role attribute of the root spans of the queries, to tell
+// the latency of the primary from the one of its read replicas.
+type DatabaseRole string
+
+const (
+	// RolePrimary is the role of a database accepting writes.
+	RolePrimary DatabaseRole = "primary"
+	// RoleReplica is the role of a read replica.
+	RoleReplica DatabaseRole = "replica"
+	// RoleAuto looks the role up with pg_is_in_recovery() on the first poll
+	// that reaches the database. The role is not looked up again after a
+	// failover, until the connection pool is reopened.
+	RoleAuto DatabaseRole = "auto"
+)
+
+// roleAttributeKey is the attribute of the root spans reporting the role of
+// the database.
+const roleAttributeKey = "db.role"
+
+func (role DatabaseRole) validate() error {
+	switch role {
+	case "", RolePrimary, RoleReplica, RoleAuto:
+		return nil
+	default:
+		return fmt.Errorf("unknown role %q, must be %q, %q or %q", role, RolePrimary, RoleReplica, RoleAuto)
+	}
+}
+
+// detectRole returns the role of the database q is connected to.
+func detectRole(ctx context.Context, q querier) (DatabaseRole, error) {
+	rows, err := q.QueryContext(ctx, "select pg_is_in_recovery()")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var inRecovery bool
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", sql.ErrNoRows
+	}
+	if err := rows.Scan(&inRecovery); err != nil {
+		return "", err
+	}
+	if inRecovery {
+		return RoleReplica, nil
+	}
+	return RolePrimary, nil
+}
+
+// setRole sets the db.role attribute of the root spans of the queries, which
+// are the spans carrying the query attribute, to role.
+func setRole(spans []*tracepb.Span, role DatabaseRole) {
+	if role == "" {
+		return
+	}
+	for _, span := range spans {
+		attributes := span.GetAttributes().GetAttributeMap()
+		if _, ok := attributes["query"]; ok {
+			attributes[roleAttributeKey] = stringToAttributeValue(string(role))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// inRecoveryRows is the result of select pg_is_in_recovery().
+type inRecoveryRows struct {
+	inRecovery bool
+	read       bool
+}
+
+func (ir *inRecoveryRows) Next() bool {
+	if ir.read {
+		return false
+	}
+	ir.read = true
+	return true
+}
+
+func (ir *inRecoveryRows) Scan(dest ...interface{}) error {
+	*dest[0].(*bool) = ir.inRecovery
+	return nil
+}
+
+func (ir *inRecoveryRows) Err() error   { return nil }
+func (ir *inRecoveryRows) Close() error { return nil }
+
+// roleQuerier answers the role lookup with inRecovery and the other queries
+// like fakeQuerier.
+type roleQuerier struct {
+	fakeQuerier
+	inRecovery bool
+}
+
+func (rq *roleQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (planRows, error) {
+	if query == "select pg_is_in_recovery()" {
+		rq.queries = append(rq.queries, query)
+		return &inRecoveryRows{inRecovery: rq.inRecovery}, nil
+	}
+	return rq.fakeQuerier.QueryContext(ctx, query, args...)
+}
+
+func TestProcessExecutionPlanRole(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	tests := []struct {
+		name        string
+		role        DatabaseRole
+		inRecovery  bool
+		want        string
+		wantLookups int
+	}{
+		{name: "unset"},
+		{name: "primary", role: RolePrimary, inRecovery: true, want: "primary"},
+		{name: "auto primary", role: RoleAuto, want: "primary", wantLookups: 1},
+		{name: "auto replica", role: RoleAuto, inRecovery: true, want: "replica", wantLookups: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rq := &roleQuerier{fakeQuerier: fakeQuerier{plans: []string{plan}}, inRecovery: tt.inRecovery}
+			pgr := &PostgresReceiver{pullCommand: "select * from google_trace()", parser: defaultPlanParser}
+			conn := &connection{config: ConnectionConfig{Role: tt.role}, querier: rq}
+			rp := &recordingProcessor{}
+
+			pgr.processExecutionPlan(context.Background(), conn, rp)
+			// The role is only looked up once.
+			pgr.processExecutionPlan(context.Background(), conn, rp)
+
+			var lookups int
+			for _, query := range rq.queries {
+				if query == "select pg_is_in_recovery()" {
+					lookups++
+				}
+			}
+			if lookups != tt.wantLookups {
+				t.Errorf("Got %d role lookups, want %d", lookups, tt.wantLookups)
+			}
+			if len(rp.traces) != 2 {
+				t.Fatalf("Got %d traces, want 2", len(rp.traces))
+			}
+			for _, td := range rp.traces {
+				for _, span := range td.Spans {
+					role, ok := span.Attributes.AttributeMap[roleAttributeKey]
+					if span.Name.GetValue() != "CloudSQLQuery" || tt.want == "" {
+						if ok {
+							t.Errorf("Got %s %q on span %q, want none", roleAttributeKey, role.GetStringValue().GetValue(), span.Name.GetValue())
+						}
+						continue
+					}
+					if got := role.GetStringValue().GetValue(); got != tt.want {
+						t.Errorf("Got %s %q, want %q", roleAttributeKey, got, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConnectionConfigsRole(t *testing.T) {
+	config := &Config{
+		Role: RoleAuto,
+		Connections: []ConnectionConfig{
+			{ConnStr: "dbname=orders"},
+			{ConnStr: "dbname=orders host=replica", Role: RoleReplica},
+		},
+	}
+	ccs := connectionConfigs(config)
+	if ccs[0].Role != RoleAuto || ccs[1].Role != RoleReplica {
+		t.Errorf("Got roles %q and %q, want %q and %q", ccs[0].Role, ccs[1].Role, RoleAuto, RoleReplica)
+	}
+	if config.Connections[0].Role != "" {
+		t.Errorf("connectionConfigs() modified the configuration")
+	}
+}
+
+func TestValidateConfigRole(t *testing.T) {
+	for _, config := range []*Config{
+		{PullCommand: "select 1", PullInterval: time.Second, Role: "standby"},
+		{PullCommand: "select 1", PullInterval: time.Second, Connections: []ConnectionConfig{{ConnStr: "dbname=orders", Role: "standby"}}},
+	} {
+		if err := validateConfig(config); err == nil {
+			t.Errorf("Got no error for an unknown role in %+v", config)
+		}
+	}
+}
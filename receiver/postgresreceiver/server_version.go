@@ -0,0 +1,132 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// serverVersionLabel is the resource label reporting the version of the
+// PostgreSQL server, e.g. "16.2".
+const serverVersionLabel = "db.server_version"
+
+// versionedPlanParser is implemented by the parsers handling the differences
+// between the plans of the PostgreSQL versions.
+type versionedPlanParser interface {
+	PlanParser
+	// parseVersion converts a plan of a server of version serverVersion,
+	// as in server_version_num, into spans. A zero serverVersion means
+	// that the version is unknown.
+	parseVersion(raw []byte, serverVersion int) ([]*tracepb.Span, error)
+}
+
+// planKeyRenames are the plan node keys renamed by PostgreSQL, by the
+// server_version_num of the first version using the new names, mapped from
+// their new name to the one the parser reads. PostgreSQL 17 split the
+// I/O timings of the shared blocks from those of the local blocks.
+var planKeyRenames = []struct {
+	sinceVersion int
+	renames      map[string]string
+}{
+	{
+		sinceVersion: 170000,
+		renames: map[string]string{
+			"Shared I/O Read Time":  "I/O Read Time",
+			"Shared I/O Write Time": "I/O Write Time",
+		},
+	},
+}
+
+// normalizePlanKeys renames the keys of the plan nodes of plan, a payload of
+// the pull command, produced by a server of version serverVersion to the
+// names the parser reads.
+func normalizePlanKeys(plan map[string]interface{}, serverVersion int) {
+	root, _ := plan["Plan"].(map[string]interface{})
+	if root == nil {
+		return
+	}
+	for _, pkr := range planKeyRenames {
+		if serverVersion >= pkr.sinceVersion {
+			renamePlanKeys(root, pkr.renames)
+		}
+	}
+}
+
+// renamePlanKeys renames the keys of node and of its descendants according to
+// renames, unless the node already has the new key.
+func renamePlanKeys(node map[string]interface{}, renames map[string]string) {
+	for from, to := range renames {
+		value, ok := node[from]
+		if _, exists := node[to]; !ok || exists {
+			continue
+		}
+		node[to] = value
+		delete(node, from)
+	}
+	children, _ := node["Plans"].([]interface{})
+	for _, child := range children {
+		if childNode, ok := child.(map[string]interface{}); ok {
+			renamePlanKeys(childNode, renames)
+		}
+	}
+}
+
+// serverVersion returns the server_version_num of the server q is connected
+// to, e.g. 160002 for PostgreSQL 16.2.
+func serverVersion(ctx context.Context, q querier) (int, error) {
+	rows, err := q.QueryContext(ctx, "show server_version_num")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var versionNum string
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, sql.ErrNoRows
+	}
+	if err := rows.Scan(&versionNum); err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(versionNum)
+}
+
+// formatServerVersion formats a server_version_num as the version it stands
+// for, e.g. "16.2" for 160002 and "9.6.24" for 90624.
+func formatServerVersion(versionNum int) string {
+	if versionNum >= 100000 {
+		return fmt.Sprintf("%d.%d", versionNum/10000, versionNum%10000)
+	}
+	return fmt.Sprintf("%d.%d.%d", versionNum/10000, versionNum/100%100, versionNum%100)
+}
+
+// resource returns the Resource the traces of the database are reported
+// under, nil when the version of the server is unknown.
+func (conn *connection) resource() *resourcepb.Resource {
+	if conn.serverVersion == 0 {
+		return nil
+	}
+	return &resourcepb.Resource{
+		Labels: map[string]string{serverVersionLabel: formatServerVersion(conn.serverVersion)},
+	}
+}
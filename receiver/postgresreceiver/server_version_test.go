@@ -0,0 +1,78 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatServerVersion(t *testing.T) {
+	for versionNum, want := range map[int]string{
+		110022: "11.22",
+		160002: "16.2",
+		90624:  "9.6.24",
+	} {
+		if got := formatServerVersion(versionNum); got != want {
+			t.Errorf("formatServerVersion(%d) = %q, want %q", versionNum, got, want)
+		}
+	}
+}
+
+func TestNormalizePlanKeys(t *testing.T) {
+	plan := `{
+		"Query Text": "select 1",
+		"Plan": {"Node Type": "Hash Join", "Shared I/O Read Time": 1.5,
+			"Plans": [{"Node Type": "Seq Scan", "Shared I/O Read Time": 0.5, "Shared I/O Write Time": 0.1}]}
+	}`
+	tests := []struct {
+		name          string
+		serverVersion int
+		wantRoot      map[string]interface{}
+	}{
+		{
+			name:          "unknown version",
+			serverVersion: 0,
+			wantRoot:      map[string]interface{}{"Node Type": "Hash Join", "Shared I/O Read Time": 1.5},
+		},
+		{
+			name:          "PostgreSQL 16",
+			serverVersion: 160002,
+			wantRoot:      map[string]interface{}{"Node Type": "Hash Join", "Shared I/O Read Time": 1.5},
+		},
+		{
+			name:          "PostgreSQL 17",
+			serverVersion: 170000,
+			wantRoot:      map[string]interface{}{"Node Type": "Hash Join", "I/O Read Time": 1.5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := unmarshalPlan(t, plan).(map[string]interface{})
+			normalizePlanKeys(message, tt.serverVersion)
+			root := message["Plan"].(map[string]interface{})
+			children := root["Plans"].([]interface{})
+			delete(root, "Plans")
+			if !reflect.DeepEqual(root, tt.wantRoot) {
+				t.Errorf("Got root node %v, want %v", root, tt.wantRoot)
+			}
+			child := children[0].(map[string]interface{})
+			_, renamed := child["I/O Write Time"]
+			if renamed != (tt.serverVersion >= 170000) {
+				t.Errorf("Got child node %v", child)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"fmt"
+	"strings"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// spanKinds maps the span kind names accepted in Config.NodeKindMap to span
+// kinds. OpenCensus has no internal span kind, the spans of the nodes running
+// within the database are left unspecified.
+var spanKinds = map[string]tracepb.Span_SpanKind{
+	"INTERNAL":    tracepb.Span_SPAN_KIND_UNSPECIFIED,
+	"UNSPECIFIED": tracepb.Span_SPAN_KIND_UNSPECIFIED,
+	"SERVER":      tracepb.Span_SERVER,
+	"CLIENT":      tracepb.Span_CLIENT,
+}
+
+// defaultNodeKindMap is the span kind of the node types calling out of the
+// database. The other node types are INTERNAL.
+var defaultNodeKindMap = map[string]string{
+	"Foreign Scan": "CLIENT",
+}
+
+// spanKind returns the span kind named name, case insensitively.
+func spanKind(name string) (tracepb.Span_SpanKind, error) {
+	kind, ok := spanKinds[strings.ToUpper(name)]
+	if !ok {
+		return tracepb.Span_SPAN_KIND_UNSPECIFIED, fmt.Errorf("unknown span kind %q, must be INTERNAL, SERVER or CLIENT", name)
+	}
+	return kind, nil
+}
+
+// nodeKinds returns the span kinds of the node types of nodeKindMap, on top
+// of the default ones.
+func nodeKinds(nodeKindMap map[string]string) (map[string]tracepb.Span_SpanKind, error) {
+	kinds := make(map[string]tracepb.Span_SpanKind)
+	for _, m := range []map[string]string{defaultNodeKindMap, nodeKindMap} {
+		for nodeType, name := range m {
+			kind, err := spanKind(name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid node_kind_map entry for %q: %v", nodeType, err)
+			}
+			kinds[nodeType] = kind
+		}
+	}
+	return kinds, nil
+}
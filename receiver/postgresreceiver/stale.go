@@ -0,0 +1,85 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/processor"
+	"go.opencensus.io/stats"
+)
+
+// staleSpanName is the name of the span emitted when a database stops
+// returning plans, see Config.StaleAfter.
+const staleSpanName = "postgres_receiver.stale"
+
+// checkStale tracks how long the polls of conn have returned no rows, and
+// sends a single stale span to nextProcessor once that exceeds staleAfter. The
+// next poll returning rows resets the tracking. It is only called by polls.
+func (pgr *PostgresReceiver) checkStale(ctx context.Context, conn *connection, rowsProcessed int64, now time.Time, staleAfter time.Duration, nextProcessor processor.TraceDataProcessor) {
+	if rowsProcessed > 0 || conn.lastRows.IsZero() {
+		// The first poll starts the tracking, a database which never
+		// returned a row is only stale after staleAfter too.
+		conn.lastRows = now
+		conn.staleReported = false
+		return
+	}
+	if staleAfter <= 0 || conn.staleReported {
+		return
+	}
+	staleFor := now.Sub(conn.lastRows)
+	if staleFor < staleAfter {
+		return
+	}
+
+	conn.staleReported = true
+	stats.Record(ctx, mStaleDatabases.M(1))
+	conn.logger.Printf("No rows returned for service %q since %v", conn.config.ServiceName, conn.lastRows.Format(time.RFC3339))
+	td := data.TraceData{
+		Node:  conn.node(),
+		Spans: []*tracepb.Span{staleSpan(conn.lastRows, now, conn.databaseName)},
+	}
+	pgr.handlePushError(td, pgr.push(ctx, nextProcessor, td))
+}
+
+// staleSpan returns the span reporting that no rows were returned between
+// lastRows and now.
+func staleSpan(lastRows, now time.Time, databaseName string) *tracepb.Span {
+	staleFor := now.Sub(lastRows)
+	attributes := map[string]*tracepb.AttributeValue{
+		"stale_for_ms": int64ToAttributeValue(int64(staleFor / time.Millisecond)),
+	}
+	if databaseName != "" {
+		attributes["database_name"] = stringToAttributeValue(databaseName)
+	}
+	return &tracepb.Span{
+		TraceId:   generateTraceId(),
+		SpanId:    generateSpanId(),
+		Name:      &tracepb.TruncatableString{Value: staleSpanName},
+		StartTime: internal.TimeToTimestamp(lastRows),
+		EndTime:   internal.TimeToTimestamp(now),
+		Status: &tracepb.Status{
+			// UNKNOWN, the diagnostic spans are errors.
+			Code:    2,
+			Message: fmt.Sprintf("no rows returned by the pull command for %v", staleFor),
+		},
+		Attributes: &tracepb.Span_Attributes{AttributeMap: attributes},
+	}
+}
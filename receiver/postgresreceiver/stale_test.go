@@ -0,0 +1,85 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckStale(t *testing.T) {
+	pgr := &PostgresReceiver{}
+	conn := &connection{config: ConnectionConfig{ServiceName: "orders"}, databaseName: "orders"}
+	rp := &recordingProcessor{}
+	start := time.Unix(1546300800, 0)
+	poll := func(rows int64, after time.Duration) {
+		pgr.checkStale(context.Background(), conn, rows, start.Add(after), time.Minute, rp)
+	}
+
+	// The first poll starts the tracking even without rows.
+	poll(0, 0)
+	poll(0, 30*time.Second)
+	if len(rp.traces) != 0 {
+		t.Fatalf("Got %d traces before stale_after elapsed, want 0", len(rp.traces))
+	}
+	poll(0, 61*time.Second)
+	// The staleness is only reported once.
+	poll(0, 2*time.Minute)
+	if len(rp.traces) != 1 {
+		t.Fatalf("Got %d traces once stale, want 1", len(rp.traces))
+	}
+	td := rp.traces[0]
+	if got := td.Node.GetServiceInfo().GetName(); got != "orders" {
+		t.Errorf("Got service name %q, want %q", got, "orders")
+	}
+	span := td.Spans[0]
+	if got := span.GetName().GetValue(); got != staleSpanName {
+		t.Errorf("Got span name %q, want %q", got, staleSpanName)
+	}
+	if span.GetStatus().GetCode() == 0 {
+		t.Error("Got an OK status, want an error")
+	}
+	attributes := span.GetAttributes().GetAttributeMap()
+	if got := attributes["stale_for_ms"].GetIntValue(); got != 61000 {
+		t.Errorf("Got stale_for_ms %d, want 61000", got)
+	}
+	if got := attributes["database_name"].GetStringValue().GetValue(); got != "orders" {
+		t.Errorf("Got database_name %q, want %q", got, "orders")
+	}
+
+	// Rows reset the tracking, the database can be reported stale again.
+	poll(3, 3*time.Minute)
+	poll(0, 3*time.Minute+59*time.Second)
+	if len(rp.traces) != 1 {
+		t.Fatalf("Got %d traces after rows, want 1", len(rp.traces))
+	}
+	poll(0, 4*time.Minute)
+	if len(rp.traces) != 2 {
+		t.Fatalf("Got %d traces once stale again, want 2", len(rp.traces))
+	}
+}
+
+func TestCheckStaleDisabled(t *testing.T) {
+	pgr := &PostgresReceiver{}
+	conn := &connection{}
+	rp := &recordingProcessor{}
+	start := time.Unix(1546300800, 0)
+	pgr.checkStale(context.Background(), conn, 0, start, 0, rp)
+	pgr.checkStale(context.Background(), conn, 0, start.Add(24*time.Hour), 0, rp)
+	if len(rp.traces) != 0 {
+		t.Errorf("Got %d traces with stale_after unset, want 0", len(rp.traces))
+	}
+}
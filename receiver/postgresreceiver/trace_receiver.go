@@ -1,119 +1,1241 @@
 package postgresreceiver
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/observability"
 	"github.com/census-instrumentation/opencensus-service/processor"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+const (
+	receiverName = "postgres"
+
+	defaultMaxPlanDepth = 64
+
+	defaultConnectRetryBackoff = time.Second
+
+	defaultRawPlanMaxBytes = 16 * 1024
 )
 
 type Config struct {
+	// The name of the receiver, which prefixes its logs and tags its metrics
+	// so that several receivers of a process can be told apart. Defaults to
+	// postgres/<host>/<database> of ConnStr, or postgres when polling several
+	// databases. It is not changed by Reconfigure.
+	Name string `mapstructure:"name"`
 	// The connect string for PostgreSQL
 	ConnStr string `mapstructure:"conn_str"`
+	// The databases to poll, each with its own connection pool. When empty,
+	// the receiver polls the single database of ConnStr.
+	Connections []ConnectionConfig `mapstructure:"connections"`
 	// The SQL query to execute for initialization.
 	InitCommand string `mapstructure:"init_command"`
 	// The SQL query to execute for pulling traces
 	PullCommand string `mapstructure:"pull_command"`
+	// The SQL statement marking a row returned by the pull command as
+	// processed, e.g. "delete from plans where counter = $1", run with the
+	// counter column of the row. When set, a poll runs the pull command and
+	// the acknowledgements in a transaction, which is committed once the
+	// next processor accepted all the traces of the poll, and rolled back
+	// otherwise so that the rows are pulled again by the next poll. The rows
+	// of a poll exported before a failure are then exported again. It
+	// requires the block backpressure policy.
+	AckCommand string `mapstructure:"ack_command"`
+	// Run the pull command of every poll in a REPEATABLE READ transaction,
+	// so that a pull command joining several views reads them from a
+	// consistent snapshot.
+	UseSnapshot bool `mapstructure:"use_snapshot"`
+	// The number of databases polled at the same time, the polls of the
+	// others waiting for one of them to finish. It bounds the connections
+	// opened at once when many databases are configured, including on the
+	// first polls, which open the connections of the pools. Zero means no
+	// limit.
+	MaxConcurrentConnections int `mapstructure:"max_concurrent_connections"`
+	// Derive the trace and span IDs from the rows instead of generating
+	// random ones, so that pulling a row again, e.g. after a failed
+	// acknowledgement, gives the same spans that the backends can
+	// deduplicate. See setDeterministicIDs. It cannot be used with
+	// PartialSpans.
+	DeterministicIDs bool `mapstructure:"deterministic_ids"`
+	// The order of the spans of a query, "children-first" (default), the
+	// root span of the query last, or "parents-first", the root span first.
+	// See EmitOrder.
+	EmitOrder EmitOrder `mapstructure:"emit_order"`
+	// Run the polls and parse the plans without sending the traces to the
+	// next processor, to check the pull command and the parsing against a
+	// new database. The metrics are still recorded, the spans that would
+	// have been sent in postgresreceiver_dry_run_spans_total. It cannot be
+	// used with AckCommand.
+	DryRun bool `mapstructure:"dry_run"`
+	// Whether the queries are sampled when their payload has no "sampled"
+	// field. When set, every span of a query gets a sampled attribute, from
+	// the "sampled" field of its payload if any or else from this setting,
+	// for the sampling-aware systems downstream. The payloads with a
+	// "sampled" field set to false are dropped in any case. Unset by
+	// default, the spans then get no sampled attribute.
+	SampledByDefault *bool `mapstructure:"sampled_by_default"`
 	// How frequent should the command be executed
 	PullInterval time.Duration `mapstructure:"pull_interval"`
+	// How the receiver authenticates, see AuthMethod. When set, the
+	// credentials below apply to all the databases and override the ones of
+	// the connect strings. They are checked by New.
+	AuthMethod AuthMethod `mapstructure:"auth_method"`
+	// The user to connect as, defaults to the one of the connect strings.
+	User string `mapstructure:"user"`
+	// The password of the password and scram authentication methods.
+	Password string `mapstructure:"password"`
+	// The client certificate and private key files of the cert
+	// authentication method. The key must not be readable by others.
+	SSLCert string `mapstructure:"ssl_cert"`
+	SSLKey  string `mapstructure:"ssl_key"`
+	// The file of the certificate authorities the server certificate is
+	// verified against with the cert authentication method. When empty, the
+	// server certificate is not verified.
+	SSLRootCert string `mapstructure:"ssl_root_cert"`
+	// The application_name reported by the receiver's connections, which
+	// makes them identifiable in pg_stat_activity.
+	ApplicationName string `mapstructure:"application_name"`
+	// The server side statement_timeout for the receiver's connections,
+	// rounded up to the millisecond. Zero means no timeout.
+	StatementTimeout time.Duration `mapstructure:"statement_timeout"`
+	// What to do when the next processor cannot keep up, "block" (default)
+	// or "drop". See BackpressurePolicy.
+	BackpressurePolicy BackpressurePolicy `mapstructure:"backpressure_policy"`
+	// How long to wait for the next processor before dropping a trace when
+	// the backpressure policy is "drop", in which case it must be positive.
+	PushTimeout time.Duration `mapstructure:"push_timeout"`
+	// Plan nodes faster than this are not emitted as spans, their children
+	// are attached to their nearest emitted ancestor instead. The root span
+	// of the query is always emitted.
+	MinNodeDuration time.Duration `mapstructure:"min_node_duration"`
+	// Send the spans of all the rows returned by a poll in a single TraceData,
+	// instead of one TraceData per row, which reduces the number of calls to
+	// the next processor.
+	BatchRows bool `mapstructure:"batch_rows"`
+	// The layout, as understood by time.Parse, of the start timestamp of the
+	// plans when it is a string rather than a number of seconds since the
+	// epoch. Defaults to RFC 3339.
+	TimestampLayout string `mapstructure:"timestamp_layout"`
+	// The maximum depth of the plan nodes emitted as spans, deeper nodes are
+	// dropped and their deepest ancestor gets a plan_truncated attribute.
+	// Defaults to 64.
+	MaxPlanDepth int `mapstructure:"max_plan_depth"`
+	// Check that every plan has the keys required to convert it before
+	// parsing it, so that invalid plans are reported with all their missing
+	// keys and counted in the invalid plans metric.
+	ValidatePlans bool `mapstructure:"validate_plans"`
+	// The span kind, INTERNAL, SERVER or CLIENT, of the spans of the plan
+	// nodes by node type, e.g. "Seq Scan". It overrides the defaults, which
+	// make Foreign Scan a CLIENT span. Unmapped node types are INTERNAL.
+	NodeKindMap map[string]string `mapstructure:"node_kind_map"`
+	// The unit of the duration of the plans, "seconds" (default) or
+	// "milliseconds".
+	DurationUnit string `mapstructure:"duration_unit"`
+	// How many more times New tries to open a connection and run the init
+	// command after a failure, so that the receiver waits for a database
+	// that is still starting up.
+	ConnectRetries int `mapstructure:"connect_retries"`
+	// How long to wait before the first connection retry, the wait doubles
+	// after each retry. Defaults to 1s.
+	ConnectRetryBackoff time.Duration `mapstructure:"connect_retry_backoff"`
+	// The host:port on which the receiver serves its internal counters in
+	// the Prometheus text format, see MetricsHandler. The endpoint is only
+	// served when set, and is not changed by Reconfigure.
+	MetricsEndpoint string `mapstructure:"metrics_endpoint"`
+	// The path of the metrics endpoint. Defaults to /metrics.
+	MetricsPath string `mapstructure:"metrics_path"`
+	// The channel on which the database notifies, with NOTIFY, that plans
+	// are available. When set, every database is polled as soon as it
+	// notifies, and PullInterval is only a safety net against lost
+	// notifications.
+	NotifyChannel string `mapstructure:"notify_channel"`
+	// When a database returns no rows for this long, the receiver sends a
+	// single span with an error status and counts it in the stale databases
+	// metric, until the database returns rows again. Zero disables the check.
+	StaleAfter time.Duration `mapstructure:"stale_after"`
+	// The bounds of the durations of the spans of the plan nodes. The end
+	// time of the spans outside the bounds is moved to bring their duration
+	// within them, and the spans get a duration_clamped attribute. Zero means
+	// no bound. The nodes that never executed keep a zero duration.
+	MinSpanDuration time.Duration `mapstructure:"min_span_duration"`
+	MaxSpanDuration time.Duration `mapstructure:"max_span_duration"`
+	// The node types, e.g. "Seq Scan" or "Hash Join", of the plan nodes
+	// emitted as spans. When set, the other nodes are omitted like the nodes
+	// faster than MinNodeDuration. The root span of the query is always
+	// emitted.
+	NodeTypeAllowlist []string `mapstructure:"node_type_allowlist"`
+	// Keep the plan, as returned by the pull command, in a raw_plan attribute
+	// of the root span of the query.
+	IncludeRawPlan bool `mapstructure:"include_raw_plan"`
+	// The size past which the raw plans are truncated, with a warning.
+	// Defaults to 16KiB.
+	RawPlanMaxBytes int `mapstructure:"raw_plan_max_bytes"`
+	// Emit the root span of long running queries as soon as they start.
+	// The pull command then returns a first payload with a "state" field set
+	// to "started", the start timestamp, the query and a correlation field,
+	// and later the plan of the completed query with the same correlation
+	// field. The root span of the started query has no duration, a "started"
+	// annotation and an in_progress attribute, and the root span of the
+	// completed query reuses its trace and span IDs.
+	//
+	// Spans cannot be updated once exported, so the backends get two spans
+	// with the same IDs, which not all of them merge. The started queries are
+	// kept in memory, at most 10000 of them: a completion received after a
+	// restart, a Reconfigure, or too many other started queries gets new IDs.
+	PartialSpans bool `mapstructure:"partial_spans"`
+	// The field of the payloads correlating the start and the completion of
+	// a query when PartialSpans is set. Defaults to "correlation_id".
+	CorrelationField string `mapstructure:"correlation_field"`
+	// The size past which the plans returned by the pull command are skipped,
+	// with a warning, before they are parsed. Caps the memory used by the
+	// plans of pathological queries. Zero means no limit.
+	MaxPlanBytes int `mapstructure:"max_plan_bytes"`
+	// The number of traces of a database pushed concurrently to the next
+	// processor when BatchRows is not set, so that a slow next processor
+	// does not slow down the polls as much. The traces may then reach the
+	// next processor out of order. The backpressure policy still applies to
+	// every push. Defaults to 1, pushing the traces one at a time.
+	PushConcurrency int `mapstructure:"push_concurrency"`
+	// The role of the databases, "primary", "replica" or "auto" to look it
+	// up, reported in the db.role attribute of the root spans of the
+	// queries. See DatabaseRole. The connections can override it.
+	Role DatabaseRole `mapstructure:"role"`
+	// Keep the Actual Startup Time and Actual Total Time of the plan nodes,
+	// relative to the start of the query, in the startup_offset_ms and
+	// total_time_ms attributes of their spans. The span times are derived
+	// from them but also depend on the children of the nodes and on the
+	// clamping of the durations, these attributes help reconcile the two.
+	IncludeRelativeTimings bool `mapstructure:"include_relative_timings"`
+}
+
+// ConnectionConfig describes one of the databases polled by the receiver.
+type ConnectionConfig struct {
+	// The connect string for PostgreSQL
+	ConnStr string `mapstructure:"conn_str"`
+	// The service name the traces of this database are reported under.
+	ServiceName string `mapstructure:"service_name"`
+	// The role of this database, defaults to the one of the receiver.
+	Role DatabaseRole `mapstructure:"role"`
+}
+
+// connection is one of the databases polled by the receiver.
+type connection struct {
+	config ConnectionConfig
+	db     *sql.DB
+	// querier runs the queries of the polls, on db unless replaced in tests.
+	querier querier
+	// listener waits for the notifications of the database, it is nil
+	// unless a notify channel is configured.
+	listener *pq.Listener
+	logger   logger
+	// databaseName is the current database of the connection, used when a
+	// plan does not report its own. It is looked up on the first poll that
+	// reaches the database, and only accessed by polls.
+	databaseName string
+	// role is the role of the database, from its configuration or looked up
+	// on the first poll that reaches the database when it is RoleAuto. It is
+	// only accessed by polls.
+	role DatabaseRole
+	// serverVersion is the server_version_num of the database, looked up on
+	// the first poll that reaches the database, zero until then. It is only
+	// accessed by polls.
+	serverVersion int
+	// lastRows is when a poll last returned rows, and staleReported whether
+	// the database was reported stale since, see checkStale. They are only
+	// accessed by polls.
+	lastRows      time.Time
+	staleReported bool
+
+	// polling is 1 while a poll is running, it guards against overlapping polls.
+	polling int32
 }
 
 type PostgresReceiver struct {
-	db           *sql.DB
+	// counters is first to be 64-bit aligned.
+	counters receiverCounters
+
+	// reconfigureMu serializes Reconfigure calls, which open connections
+	// without holding mu.
+	reconfigureMu sync.Mutex
+
+	// name is the name of the receiver, see Config.Name.
+	name   string
+	logger logger
+
+	mu           sync.Mutex
+	config       Config
+	conns        []*connection
 	pullCommand  string
 	pullInterval time.Duration
+	parser       PlanParser
+	// customParser is true when parser was set by WithPlanParser, in which
+	// case it is kept on Reconfigure.
+	customParser bool
+	// onError is called with the errors of the polls, see WithErrorHandler.
+	onError func(error)
+	// pollSlots holds a value per running poll when the polls are limited by
+	// MaxConcurrentConnections, it is nil otherwise.
+	pollSlots chan struct{}
+	// clock tells the time and paces the polls, see WithClock. It is only
+	// set when the receiver is created.
+	clock Clock
+
+	// intervalChanges notifies the poll loop about a new pull interval.
+	intervalChanges chan time.Duration
+	// notifications hands the connections whose database notified that plans
+	// are available over to the poll loop.
+	notifications chan *connection
+	// pushes hands traces over to the push loop, see BackpressureDrop.
+	pushes chan pushRequest
+	stopCh chan struct{}
+	// metricsServer serves the metrics endpoint while the receiver is
+	// started, if one is configured.
+	metricsServer *http.Server
 }
 
-func New(config *Config) (*PostgresReceiver, error) {
-	db, err := sql.Open( /* driver = */ "postgres", config.ConnStr)
-	if err != nil {
+func New(config *Config, opts ...Option) (*PostgresReceiver, error) {
+	if err := validateConfig(config); err != nil {
 		log.Println(err)
 		return nil, err
 	}
+	logger := newLogger(config)
+	pgr := &PostgresReceiver{
+		name:            instanceName(config),
+		logger:          logger,
+		config:          *config,
+		pullCommand:     config.PullCommand,
+		pullInterval:    config.PullInterval,
+		parser:          newPlanParser(config),
+		pollSlots:       newPollSlots(config),
+		intervalChanges: make(chan time.Duration, 1),
+		notifications:   make(chan *connection, 1),
+		pushes:          make(chan pushRequest),
+	}
+	// The options are applied first, the clock paces the connection retries.
+	for _, opt := range opts {
+		opt(pgr)
+	}
+
+	clock := pgr.timeSource()
+	sleep := func(d time.Duration) {
+		<-clock.NewTimer(d).C()
+	}
+	backoff := config.ConnectRetryBackoff
+	if backoff == 0 {
+		backoff = defaultConnectRetryBackoff
+	}
+	for _, cc := range connectionConfigs(config) {
+		var conn *connection
+		err := connectWithRetry(logger, config.ConnectRetries, backoff, sleep, func() (err error) {
+			conn, err = openConnection(config, cc, pgr.notifications)
+			return err
+		})
+		if err != nil {
+			logger.Println(err)
+			closeConnections(pgr.conns)
+			return nil, err
+		}
+		pgr.conns = append(pgr.conns, conn)
+	}
+	logger.Println("Connected to postgres. Extension created.")
+	return pgr, nil
+}
+
+func validateConfig(config *Config) error {
+	if config == nil {
+		return errors.New("nil PostgreSQL receiver config")
+	}
+	if config.PullCommand == "" {
+		return errors.New("pull_command must be set")
+	}
+	if config.PullInterval <= 0 {
+		return fmt.Errorf("pull_interval must be positive, got %v", config.PullInterval)
+	}
+	if _, err := authParams(config); err != nil {
+		return err
+	}
+	if err := config.BackpressurePolicy.validate(); err != nil {
+		return err
+	}
+	if err := config.EmitOrder.validate(); err != nil {
+		return err
+	}
+	if config.AckCommand != "" && config.BackpressurePolicy == BackpressureDrop {
+		return errors.New("ack_command requires the block backpressure_policy, the dropped traces would be acknowledged")
+	}
+	if config.AckCommand != "" && config.DryRun {
+		return errors.New("ack_command cannot be used with dry_run, the rows would be acknowledged without being exported")
+	}
+	if config.DeterministicIDs && config.PartialSpans {
+		return errors.New("deterministic_ids cannot be used with partial_spans, the completed queries reuse the IDs of the started ones")
+	}
+	if config.BackpressurePolicy == BackpressureDrop && config.PushTimeout <= 0 {
+		return fmt.Errorf("push_timeout must be positive with the %q backpressure policy, got %v", BackpressureDrop, config.PushTimeout)
+	}
+	if _, err := nodeKinds(config.NodeKindMap); err != nil {
+		return err
+	}
+	if _, err := durationUnit(config.DurationUnit); err != nil {
+		return err
+	}
+	if config.ConnectRetries < 0 {
+		return fmt.Errorf("connect_retries must not be negative, got %d", config.ConnectRetries)
+	}
+	if config.ConnectRetryBackoff < 0 {
+		return fmt.Errorf("connect_retry_backoff must not be negative, got %v", config.ConnectRetryBackoff)
+	}
+	if config.StaleAfter < 0 {
+		return fmt.Errorf("stale_after must not be negative, got %v", config.StaleAfter)
+	}
+	if config.MinSpanDuration < 0 || config.MaxSpanDuration < 0 {
+		return fmt.Errorf("min_span_duration and max_span_duration must not be negative, got %v and %v", config.MinSpanDuration, config.MaxSpanDuration)
+	}
+	if config.RawPlanMaxBytes < 0 {
+		return fmt.Errorf("raw_plan_max_bytes must not be negative, got %d", config.RawPlanMaxBytes)
+	}
+	if err := config.Role.validate(); err != nil {
+		return err
+	}
+	for _, cc := range config.Connections {
+		if err := cc.Role.validate(); err != nil {
+			return err
+		}
+	}
+	if config.MaxConcurrentConnections < 0 {
+		return fmt.Errorf("max_concurrent_connections must not be negative, got %d", config.MaxConcurrentConnections)
+	}
+	if config.PushConcurrency < 0 {
+		return fmt.Errorf("push_concurrency must not be negative, got %d", config.PushConcurrency)
+	}
+	if config.MaxPlanBytes < 0 {
+		return fmt.Errorf("max_plan_bytes must not be negative, got %d", config.MaxPlanBytes)
+	}
+	if config.MaxSpanDuration > 0 && config.MaxSpanDuration < config.MinSpanDuration {
+		return fmt.Errorf("max_span_duration %v is less than min_span_duration %v", config.MaxSpanDuration, config.MinSpanDuration)
+	}
+	return nil
+}
+
+// openDB opens the connection pool described by config and runs the init
+// command, if any, on it.
+func openDB(config *Config) (*sql.DB, error) {
+	connStr, err := connStrFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open( /* driver = */ "postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+	if config.InitCommand == "" {
+		return db, nil
+	}
 	if _, err = db.Exec(config.InitCommand); err != nil {
-		log.Println(err)
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// connectionConfigs returns the databases to poll according to config.
+func connectionConfigs(config *Config) []ConnectionConfig {
+	if len(config.Connections) == 0 {
+		return []ConnectionConfig{{ConnStr: config.ConnStr, Role: config.Role}}
+	}
+	ccs := make([]ConnectionConfig, len(config.Connections))
+	for i, cc := range config.Connections {
+		if cc.Role == "" {
+			cc.Role = config.Role
+		}
+		ccs[i] = cc
+	}
+	return ccs
+}
+
+// openConnection opens the connection pool of the database described by cc,
+// using the connection settings of config. When config has a notify channel,
+// it also starts listening on it and hands the connection over to
+// notifications when the database notifies.
+func openConnection(config *Config, cc ConnectionConfig, notifications chan<- *connection) (*connection, error) {
+	dbConfig := *config
+	dbConfig.ConnStr = cc.ConnStr
+	db, err := openDB(&dbConfig)
+	if err != nil {
 		return nil, err
 	}
-	log.Println("Connected to postgres. Extension created.")
-	return &PostgresReceiver{
-		db:           db,
-		pullCommand:  config.PullCommand,
-		pullInterval: config.PullInterval,
-	}, nil
+	conn := &connection{config: cc, db: db, querier: dbQuerier{db}, logger: newLogger(config)}
+	if config.NotifyChannel != "" {
+		connStr, err := connStrFromConfig(&dbConfig)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		conn.listener = newListener(conn.logger, connStr, cc.ServiceName)
+		go conn.listen(config.NotifyChannel, notifications)
+	}
+	return conn, nil
+}
+
+// closeConnections closes the connection pools of conns, returning the first error.
+func closeConnections(conns []*connection) error {
+	var firstErr error
+	for _, conn := range conns {
+		if err := conn.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if conn.listener != nil {
+			if err := conn.listener.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// connectionChanged reports whether the settings shared by all the connection
+// pools, including the credentials added to their connect strings, differ
+// between the two configs.
+func connectionChanged(oldConfig, newConfig *Config) bool {
+	return oldConfig.ApplicationName != newConfig.ApplicationName ||
+		oldConfig.StatementTimeout != newConfig.StatementTimeout ||
+		oldConfig.NotifyChannel != newConfig.NotifyChannel ||
+		oldConfig.AuthMethod != newConfig.AuthMethod ||
+		oldConfig.User != newConfig.User ||
+		oldConfig.Password != newConfig.Password ||
+		oldConfig.SSLCert != newConfig.SSLCert ||
+		oldConfig.SSLKey != newConfig.SSLKey ||
+		oldConfig.SSLRootCert != newConfig.SSLRootCert
+}
+
+// reopenConnections returns the connections to poll according to config,
+// given the connections currently polled according to oldConfig. The current
+// connections whose settings did not change are reused, the others are
+// returned as unused and must be closed by the caller. The new connections
+// hand themselves over to notifications, see openConnection.
+func reopenConnections(oldConfig *Config, oldConns []*connection, config *Config, notifications chan<- *connection) (conns, unused []*connection, err error) {
+	current := make(map[ConnectionConfig]*connection)
+	if !connectionChanged(oldConfig, config) {
+		for _, conn := range oldConns {
+			current[conn.config] = conn
+		}
+	}
+
+	var opened []*connection
+	reused := make(map[*connection]bool)
+	for _, cc := range connectionConfigs(config) {
+		conn := current[cc]
+		if conn != nil {
+			delete(current, cc)
+			if config.InitCommand != "" && oldConfig.InitCommand != config.InitCommand {
+				if _, err := conn.db.Exec(config.InitCommand); err != nil {
+					closeConnections(opened)
+					return nil, nil, err
+				}
+			}
+			reused[conn] = true
+		} else {
+			if conn, err = openConnection(config, cc, notifications); err != nil {
+				closeConnections(opened)
+				return nil, nil, err
+			}
+			opened = append(opened, conn)
+		}
+		conns = append(conns, conn)
+	}
+
+	for _, conn := range oldConns {
+		if !reused[conn] {
+			unused = append(unused, conn)
+		}
+	}
+	return conns, unused, nil
+}
+
+// Reconfigure applies config to a running receiver. The existing connection
+// pools are kept unless their connection settings changed, in which case new
+// pools are opened and the old ones are closed once their in-flight queries
+// finish. Polls keep running on the existing pools while the new ones are
+// opened.
+func (pgr *PostgresReceiver) Reconfigure(config *Config) error {
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+
+	pgr.reconfigureMu.Lock()
+	defer pgr.reconfigureMu.Unlock()
+
+	pgr.mu.Lock()
+	oldConfig, oldConns := pgr.config, pgr.conns
+	pgr.mu.Unlock()
+
+	conns, unused, err := reopenConnections(&oldConfig, oldConns, config, pgr.notifications)
+	if err != nil {
+		return err
+	}
+
+	pgr.mu.Lock()
+	intervalChanged := pgr.pullInterval != config.PullInterval
+	pgr.config = *config
+	pgr.conns = conns
+	pgr.pullCommand = config.PullCommand
+	pgr.pullInterval = config.PullInterval
+	if !pgr.customParser {
+		pgr.parser = newPlanParser(config)
+	}
+	if config.MaxConcurrentConnections != oldConfig.MaxConcurrentConnections {
+		// The running polls release their slot in the previous channel.
+		pgr.pollSlots = newPollSlots(config)
+	}
+	pgr.mu.Unlock()
+
+	if intervalChanged {
+		// Drop any interval that the poll loop did not pick up yet, only the
+		// latest one matters.
+		select {
+		case <-pgr.intervalChanges:
+		default:
+		}
+		pgr.intervalChanges <- config.PullInterval
+	}
+	return closeConnections(unused)
 }
 
 func (pgr *PostgresReceiver) StartTraceReception(ctx context.Context, nextProcessor processor.TraceDataProcessor) error {
-	go func() {
-		for range time.Tick(pgr.pullInterval) {
-			pgr.ProcessExecutionPlan(nextProcessor)
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.stopCh != nil {
+		return errors.New("PostgreSQL receiver already started")
+	}
+	if pgr.config.MetricsEndpoint != "" {
+		srv, err := pgr.serveMetrics(&pgr.config)
+		if err != nil {
+			return fmt.Errorf("failed to serve metrics on %q: %v", pgr.config.MetricsEndpoint, err)
 		}
+		pgr.metricsServer = srv
+	}
+	pgr.stopCh = make(chan struct{})
+	go pgr.pollLoop(pgr.pullInterval, pgr.stopCh, nextProcessor)
+	go pgr.pushLoop(pgr.stopCh)
+	return nil
+}
 
+func (pgr *PostgresReceiver) pollLoop(interval time.Duration, stopCh <-chan struct{}, nextProcessor processor.TraceDataProcessor) {
+	clock := pgr.timeSource()
+	ticker := clock.NewTicker(interval)
+	defer func() {
+		ticker.Stop()
 	}()
-	return nil
+
+	for {
+		select {
+		case <-ticker.C():
+			// Polls run in their own goroutine so that ticks keep being
+			// observed, and counted as skipped, while a slow poll is running.
+			go pgr.ProcessExecutionPlan(nextProcessor)
+		case conn := <-pgr.notifications:
+			go pgr.pollConnection(conn, nextProcessor)
+		case interval := <-pgr.intervalChanges:
+			ticker.Stop()
+			ticker = clock.NewTicker(interval)
+		case <-stopCh:
+			return
+		}
+	}
 }
 
 func (pgr *PostgresReceiver) StopTraceReception(ctx context.Context) error {
-	return pgr.db.Close()
+	pgr.mu.Lock()
+	defer pgr.mu.Unlock()
+
+	if pgr.stopCh != nil {
+		close(pgr.stopCh)
+		pgr.stopCh = nil
+	}
+	if pgr.metricsServer != nil {
+		pgr.metricsServer.Close()
+		pgr.metricsServer = nil
+	}
+	return closeConnections(pgr.conns)
 }
 
+// ProcessExecutionPlan runs the pull command on every database and sends the
+// execution plans it returns to nextProcessor. The databases are polled
+// concurrently, up to MaxConcurrentConnections at a time, and the poll of a
+// database is skipped, and counted in the skipped polls metric, if its
+// previous one is still running or waiting.
 func (pgr *PostgresReceiver) ProcessExecutionPlan(nextProcessor processor.TraceDataProcessor) {
-	rows, err := pgr.db.Query(pgr.pullCommand)
+	pgr.mu.Lock()
+	conns := pgr.conns
+	pgr.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn *connection) {
+			defer wg.Done()
+			pgr.pollConnection(conn, nextProcessor)
+		}(conn)
+	}
+	wg.Wait()
+}
+
+// pollConnection runs the pull command on the database of conn, unless its
+// previous poll is still running, and sends the execution plans it returns to
+// nextProcessor.
+func (pgr *PostgresReceiver) pollConnection(conn *connection, nextProcessor processor.TraceDataProcessor) {
+	ctx := observability.ContextWithReceiverName(context.Background(), receiverName)
+	ctx, _ = tag.New(ctx, tag.Upsert(TagKeyReceiverName, pgr.name))
+	pgr.mu.Lock()
+	slots := pgr.pollSlots
+	pgr.mu.Unlock()
+	conn.runExclusive(ctx, func() {
+		// A poll waiting for a slot counts as running, the next ones are
+		// skipped rather than queued.
+		if slots != nil {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+		}
+		pgr.processExecutionPlan(ctx, conn, nextProcessor)
+	})
+}
+
+// newPollSlots returns the pollSlots of a receiver configured with config.
+func newPollSlots(config *Config) chan struct{} {
+	if config.MaxConcurrentConnections <= 0 {
+		return nil
+	}
+	return make(chan struct{}, config.MaxConcurrentConnections)
+}
+
+// runExclusive calls poll unless another poll of the database is already
+// running, in which case it returns false without waiting.
+func (conn *connection) runExclusive(ctx context.Context, poll func()) bool {
+	if !atomic.CompareAndSwapInt32(&conn.polling, 0, 1) {
+		conn.logger.Println("Skipping poll, the previous one is still running")
+		stats.Record(ctx, mSkippedPolls.M(1))
+		return false
+	}
+	defer atomic.StoreInt32(&conn.polling, 0)
+	poll()
+	return true
+}
+
+func (pgr *PostgresReceiver) processExecutionPlan(ctx context.Context, conn *connection, nextProcessor processor.TraceDataProcessor) {
+	pgr.mu.Lock()
+	pullCommand, ackCommand, staleAfter := pgr.pullCommand, pgr.config.AckCommand, pgr.config.StaleAfter
+	useSnapshot := pgr.config.UseSnapshot
+	pgr.mu.Unlock()
+
+	clock := pgr.timeSource()
+	pollStart := clock.Now()
+	var rowsProcessed int64
+	defer func() {
+		pollEnd := clock.Now()
+		pollDurationMs := float64(pollEnd.Sub(pollStart)) / float64(time.Millisecond)
+		stats.Record(ctx, mPollDuration.M(pollDurationMs), mRowsProcessed.M(rowsProcessed))
+		pgr.counters.pollDone(rowsProcessed, pollEnd)
+		pgr.checkStale(ctx, conn, rowsProcessed, pollEnd, staleAfter, nextProcessor)
+	}()
+
+	if conn.databaseName == "" {
+		databaseName, err := currentDatabase(ctx, conn.querier)
+		if err != nil {
+			conn.logger.Printf("Looking up the current database failed for service %q: %v", conn.config.ServiceName, err)
+			pgr.handleError(OpLookup, conn.config.ServiceName, err)
+		}
+		conn.databaseName = databaseName
+	}
+	if conn.serverVersion == 0 {
+		version, err := serverVersion(ctx, conn.querier)
+		if err != nil {
+			conn.logger.Printf("Looking up the server version failed for service %q: %v", conn.config.ServiceName, err)
+			pgr.handleError(OpLookup, conn.config.ServiceName, err)
+		}
+		conn.serverVersion = version
+	}
+	if conn.role == "" {
+		conn.role = conn.config.Role
+	}
+	if conn.role == RoleAuto {
+		role, err := detectRole(ctx, conn.querier)
+		if err != nil {
+			conn.logger.Printf("Looking up the role of the database failed for service %q: %v", conn.config.ServiceName, err)
+			pgr.handleError(OpLookup, conn.config.ServiceName, err)
+		} else {
+			conn.role = role
+		}
+	}
+
+	q := conn.querier
+	var tx pollTx
+	var acks *rowAcks
+	if ackCommand != "" || useSnapshot {
+		opts := &sql.TxOptions{}
+		if useSnapshot {
+			opts.Isolation = sql.LevelRepeatableRead
+		}
+		var err error
+		if tx, err = beginPollTx(ctx, conn.querier, opts); err != nil {
+			conn.logger.Printf("Beginning the transaction of the poll failed for service %q: %v", conn.config.ServiceName, err)
+			pgr.handleError(OpPull, conn.config.ServiceName, err)
+			return
+		}
+		// The transaction is rolled back on any error, nothing is
+		// acknowledged unless it is committed.
+		defer tx.Rollback()
+		q = tx
+		if ackCommand != "" {
+			acks = &rowAcks{}
+		}
+	}
+
+	rows, err := q.QueryContext(ctx, pullCommand)
+	if err != nil {
+		// Only this database is affected, the others keep being polled.
+		conn.logger.Printf("Pull command failed for service %q: %v", conn.config.ServiceName, err)
+		pgr.handleError(OpPull, conn.config.ServiceName, err)
+		return
+	}
+	defer rows.Close()
+
+	rowsProcessed, err = pgr.readRows(ctx, conn, rows, nextProcessor, acks)
+	if err != nil {
+		// The connection pool discards the broken connection, the next poll
+		// runs on a new one.
+		conn.logger.Printf("Reading the result of the pull command failed for service %q: %v", conn.config.ServiceName, err)
+		pgr.handleError(OpRead, conn.config.ServiceName, err)
+		return
+	}
+	if tx == nil {
+		return
+	}
+	// The connection of the transaction cannot run other statements while
+	// the result of the pull command is being read.
+	rows.Close()
+	if acks != nil {
+		pgr.ackRows(ctx, conn, tx, ackCommand, acks)
+	} else if err := tx.Commit(); err != nil {
+		conn.logger.Printf("Committing the transaction of the poll failed for service %q: %v", conn.config.ServiceName, err)
+		pgr.handleError(OpPull, conn.config.ServiceName, err)
+	}
+}
+
+// planRows is the part of *sql.Rows used to read the result of the pull command.
+type planRows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+}
+
+// querier runs the queries of the polls. It is implemented by dbQuerier on
+// top of the connection pools, and faked in tests to poll canned rows.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (planRows, error)
+}
+
+// dbQuerier is the querier of a connection pool.
+type dbQuerier struct {
+	db *sql.DB
+}
+
+func (q dbQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (planRows, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		// Keep the interface nil.
+		return nil, err
+	}
+	return rows, nil
+}
+
+// currentDatabase returns the name of the database q is connected to.
+func currentDatabase(ctx context.Context, q querier) (string, error) {
+	rows, err := q.QueryContext(ctx, "select current_database()")
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	defer rows.Close()
 
+	var databaseName string
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", sql.ErrNoRows
+	}
+	if err := rows.Scan(&databaseName); err != nil {
+		return "", err
+	}
+	return databaseName, nil
+}
+
+// processRows sends the execution plans of rows to nextProcessor. It returns
+// the number of rows read, and the error that interrupted the iteration, if any.
+func (pgr *PostgresReceiver) processRows(ctx context.Context, conn *connection, rows planRows, nextProcessor processor.TraceDataProcessor) (int64, error) {
+	return pgr.readRows(ctx, conn, rows, nextProcessor, nil)
+}
+
+// readRows is processRows collecting the rows to acknowledge in acks, unless
+// it is nil.
+func (pgr *PostgresReceiver) readRows(ctx context.Context, conn *connection, rows planRows, nextProcessor processor.TraceDataProcessor, acks *rowAcks) (int64, error) {
+	pgr.mu.Lock()
+	parser, batchRows, maxPlanBytes := pgr.parser, pgr.config.BatchRows, pgr.config.MaxPlanBytes
+	deterministicIDs := pgr.config.DeterministicIDs
+	pushes := newPushPool(pgr.config.PushConcurrency)
+	pgr.mu.Unlock()
+
+	var rowsProcessed int64
+	node, resource := conn.node(), conn.resource()
+	versionedParser, _ := parser.(versionedPlanParser)
+	var batch []*tracepb.Span
+	// The fingerprints of the queries of the poll, to measure the diversity
+	// of the workload.
+	fingerprints := make(map[string]bool)
 	for rows.Next() {
+		rowsProcessed++
 		var counter int
-		var plan_str string
-		if err := rows.Scan(&counter, &plan_str); err != nil {
-			log.Println("Scan row failed: ", err)
+		// Scanning into bytes reads text and jsonb plan columns alike,
+		// without converting the plan to a string.
+		var plan []byte
+		if err := rows.Scan(&counter, &plan); err != nil {
+			atomic.AddInt64(&pgr.counters.scanErrors, 1)
+			conn.logger.Println("Scan row failed: ", err)
+			pgr.handleError(OpScan, conn.config.ServiceName, err)
+			continue
+		}
+		// The rows skipped below are acknowledged too, they would be
+		// skipped again.
+		if acks != nil {
+			acks.counters = append(acks.counters, counter)
+		}
+		if maxPlanBytes > 0 && len(plan) > maxPlanBytes {
+			stats.Record(ctx, mOversizedPlans.M(1))
+			conn.logger.Printf("Skipping plan %d of %d bytes, more than max_plan_bytes %d", counter, len(plan), maxPlanBytes)
 			continue
 		}
-		log.Println(counter)
-		log.Println(plan_str)
+		conn.logger.Println(counter)
+		conn.logger.Println(string(plan))
 
-		var message interface{}
-		err := json.Unmarshal([]byte(plan_str), &message)
+		var spans []*tracepb.Span
+		var err error
+		if versionedParser != nil {
+			spans, err = versionedParser.parseVersion(plan, conn.serverVersion)
+		} else {
+			spans, err = parser.Parse(plan)
+		}
 		if err != nil {
-			log.Println("Unmarshal execution plan failed: ", err)
+			if _, ok := err.(*invalidPlanError); ok {
+				stats.Record(ctx, mInvalidPlans.M(1))
+			}
+			conn.logger.Println("Parse execution plan failed: ", err)
+			pgr.handleError(OpParse, conn.config.ServiceName, err)
+			continue
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		if deterministicIDs {
+			setDeterministicIDs(spans, counter)
+		}
+		setDefaultDatabaseName(spans, conn.databaseName)
+		if conn.role != RoleAuto {
+			setRole(spans, conn.role)
+		}
+		addQueryFingerprints(fingerprints, spans)
+		if batchRows {
+			batch = append(batch, spans...)
 			continue
 		}
-		spans := parseExecutionPlan(message)
 		td := data.TraceData{
-			Node: &commonpb.Node{
-				Identifier: &commonpb.ProcessIdentifier{
-					HostName: "PostgreSQL",
-					Pid:      uint32(os.Getpid()),
-				},
-			},
-			Spans: spans,
+			Node:     node,
+			Resource: resource,
+			Spans:    spans,
 		}
-		nextProcessor.ProcessTraceData(context.Background(), td)
+		pushes.run(func() error { return pgr.push(ctx, nextProcessor, td) })
+	}
+
+	// The rows read before an error are still sent.
+	if len(batch) > 0 {
+		pushes.run(func() error {
+			return pgr.push(ctx, nextProcessor, data.TraceData{Node: node, Resource: resource, Spans: batch})
+		})
 	}
+	if errs := pushes.wait(); len(errs) > 0 {
+		conn.logger.Printf("Next processor failed %d times for service %q: %v", len(errs), conn.config.ServiceName, internal.CombineErrors(errs))
+		for _, err := range errs {
+			pgr.handleError(OpPush, conn.config.ServiceName, err)
+		}
+		if acks != nil {
+			acks.pushFailed = true
+		}
+	}
+	stats.Record(ctx, mDistinctQueries.M(int64(len(fingerprints))))
+	return rowsProcessed, rows.Err()
 }
 
-func parseExecutionPlan(message interface{}) []*tracepb.Span {
+// setDefaultDatabaseName sets the database_name attribute of the root spans of
+// the queries, which are the spans carrying the query attribute, to
+// databaseName unless the plan reported one.
+func setDefaultDatabaseName(spans []*tracepb.Span, databaseName string) {
+	if databaseName == "" {
+		return
+	}
+	for _, span := range spans {
+		attributes := span.GetAttributes().GetAttributeMap()
+		if _, ok := attributes["query"]; !ok {
+			continue
+		}
+		if _, ok := attributes["database_name"]; !ok {
+			attributes["database_name"] = stringToAttributeValue(databaseName)
+		}
+	}
+}
+
+// addQueryFingerprints adds to fingerprints the fingerprints of the queries of
+// the root spans of the queries, which are the spans carrying the query
+// attribute.
+func addQueryFingerprints(fingerprints map[string]bool, spans []*tracepb.Span) {
+	for _, span := range spans {
+		if query, ok := span.GetAttributes().GetAttributeMap()["query"]; ok {
+			fingerprints[processor.FingerprintQuery(query.GetStringValue().GetValue())] = true
+		}
+	}
+}
+
+// node returns the Node the traces of the database are reported under.
+func (conn *connection) node() *commonpb.Node {
+	node := &commonpb.Node{
+		Identifier: &commonpb.ProcessIdentifier{
+			HostName: "PostgreSQL",
+			Pid:      uint32(os.Getpid()),
+		},
+	}
+	if conn.config.ServiceName != "" {
+		node.ServiceInfo = &commonpb.ServiceInfo{Name: conn.config.ServiceName}
+	}
+	return node
+}
+
+// ParseExecutionPlan converts the JSON representation of an execution plan,
+// as produced by the pull command, into spans: a root span for the query and
+// one child span per plan node. It returns no span when the plan has a
+// "sampled" field set to false.
+func ParseExecutionPlan(planJSON []byte) (spans []*tracepb.Span, err error) {
+	return defaultPlanParser.Parse(planJSON)
+}
+
+// PlanParser converts the plans returned by the pull command into spans. The
+// receiver uses a parser of PostgreSQL JSON plans by default, see
+// WithPlanParser to support other formats.
+type PlanParser interface {
+	Parse(raw []byte) ([]*tracepb.Span, error)
+}
+
+// planParser converts execution plans into spans according to the receiver
+// configuration.
+type planParser struct {
+	// minNodeDuration is the duration under which plan nodes are not emitted
+	// as spans.
+	minNodeDuration time.Duration
+	// timestampLayout is the layout of the start timestamps given as strings.
+	timestampLayout string
+	// maxPlanDepth is the depth past which plan nodes are dropped, the root
+	// plan node being at depth 1.
+	maxPlanDepth int
+	// validate is true when the plans are checked by validatePlan.
+	validate bool
+	// nodeKinds is the span kind of the plan nodes by node type.
+	nodeKinds map[string]tracepb.Span_SpanKind
+	// durationUnit is the unit of the duration of the plans.
+	durationUnit time.Duration
+	// minSpanDuration and maxSpanDuration bound the durations of the spans of
+	// the plan nodes, zero meaning no bound.
+	minSpanDuration time.Duration
+	maxSpanDuration time.Duration
+	// allowedNodeTypes are the node types of the nodes emitted as spans, all
+	// of them when nil.
+	allowedNodeTypes map[string]bool
+	// includeRawPlan is true when the plans are kept in the root spans, up to
+	// rawPlanMaxBytes.
+	includeRawPlan  bool
+	rawPlanMaxBytes int
+	// includeRelativeTimings is true when the plan node timings are kept in
+	// the spans.
+	includeRelativeTimings bool
+	// emitOrder is the order of the spans of a query.
+	emitOrder EmitOrder
+	// sampledByDefault is whether the queries without a "sampled" field are
+	// sampled, nil when the spans get no sampled attribute.
+	sampledByDefault *bool
+	// started are the started queries waiting for their completion, it is
+	// nil unless partial spans are enabled.
+	started          *startedQueries
+	correlationField string
+	logger           logger
+}
+
+var defaultPlanParser = newPlanParser(&Config{})
+
+var _ PlanParser = (*planParser)(nil)
+
+func newPlanParser(config *Config) *planParser {
+	maxPlanDepth := config.MaxPlanDepth
+	if maxPlanDepth <= 0 {
+		maxPlanDepth = defaultMaxPlanDepth
+	}
+	// The node kind map is checked by validateConfig.
+	kinds, _ := nodeKinds(config.NodeKindMap)
+	// So is the duration unit.
+	durationUnit, _ := durationUnit(config.DurationUnit)
+	var allowedNodeTypes map[string]bool
+	if len(config.NodeTypeAllowlist) > 0 {
+		allowedNodeTypes = make(map[string]bool, len(config.NodeTypeAllowlist))
+		for _, nodeType := range config.NodeTypeAllowlist {
+			allowedNodeTypes[nodeType] = true
+		}
+	}
+	var started *startedQueries
+	if config.PartialSpans {
+		started = newStartedQueries()
+	}
+	correlationField := config.CorrelationField
+	if correlationField == "" {
+		correlationField = defaultCorrelationField
+	}
+	rawPlanMaxBytes := config.RawPlanMaxBytes
+	if rawPlanMaxBytes <= 0 {
+		rawPlanMaxBytes = defaultRawPlanMaxBytes
+	}
+	return &planParser{
+		minNodeDuration:        config.MinNodeDuration,
+		timestampLayout:        config.TimestampLayout,
+		maxPlanDepth:           maxPlanDepth,
+		validate:               config.ValidatePlans,
+		nodeKinds:              kinds,
+		durationUnit:           durationUnit,
+		minSpanDuration:        config.MinSpanDuration,
+		maxSpanDuration:        config.MaxSpanDuration,
+		allowedNodeTypes:       allowedNodeTypes,
+		includeRawPlan:         config.IncludeRawPlan,
+		rawPlanMaxBytes:        rawPlanMaxBytes,
+		includeRelativeTimings: config.IncludeRelativeTimings,
+		emitOrder:              config.EmitOrder,
+		sampledByDefault:       config.SampledByDefault,
+		started:                started,
+		correlationField:       correlationField,
+		logger:                 newLogger(config),
+	}
+}
+
+// Parse implements PlanParser for the JSON plans of PostgreSQL, i.e. the
+// output of EXPLAIN (ANALYZE, FORMAT JSON) with the query details added by
+// the pull command.
+func (pp *planParser) Parse(planJSON []byte) ([]*tracepb.Span, error) {
+	return pp.parseVersion(planJSON, 0)
+}
+
+func (pp *planParser) parseVersion(planJSON []byte, serverVersion int) (spans []*tracepb.Span, err error) {
+	var message interface{}
+	if err := json.Unmarshal(planJSON, &message); err != nil {
+		return nil, err
+	}
+	if plan, ok := message.(map[string]interface{}); ok && plan["start_timestamp_nanos"] != nil {
+		// The generic decoding made a float64 of it, which cannot hold a
+		// nanosecond timestamp exactly.
+		nanos, err := startTimestampNanos(planJSON)
+		if err != nil {
+			return nil, err
+		}
+		plan["start_timestamp_nanos"] = nanos
+	}
+	plan, _ := message.(map[string]interface{})
+	if plan != nil {
+		normalizePlanKeys(plan, serverVersion)
+	}
+	// The payloads of started queries have no plan yet.
+	if pp.validate && !(pp.started != nil && isStartedPayload(plan)) {
+		if err := validatePlan(message); err != nil {
+			return nil, err
+		}
+	}
+
+	// The parsing asserts the types of the plan fields, turn a mismatch into
+	// an error instead of crashing the receiver.
+	defer func() {
+		if r := recover(); r != nil {
+			spans, err = nil, fmt.Errorf("malformed execution plan: %v", r)
+		}
+	}()
+	if spans, err = pp.parseExecutionPlan(message); err != nil || len(spans) == 0 {
+		return spans, err
+	}
+	if pp.includeRawPlan && !isStartedPayload(plan) {
+		// The root span of the query is the last one.
+		root := spans[len(spans)-1]
+		root.Attributes.AttributeMap["raw_plan"] = pp.rawPlanAttribute(planJSON)
+	}
+	pp.setSampled(spans, plan)
+	if pp.emitOrder == EmitParentsFirst {
+		spans = parentsFirst(spans)
+	}
+	return spans, nil
+}
+
+func (pp *planParser) parseExecutionPlan(message interface{}) ([]*tracepb.Span, error) {
 	plan := message.(map[string]interface{})
 
+	// The application decided not to sample the trace of the query, keep the
+	// database spans consistent with it.
+	if sampled, ok := plan["sampled"].(bool); ok && !sampled {
+		return nil, nil
+	}
+
+	correlation_id := pp.correlationID(plan)
+	if correlation_id != "" && isStartedPayload(plan) {
+		return pp.parseStartedPlan(plan, correlation_id)
+	}
+
 	trace_id := generateTraceId()
 	span_id := generateSpanId()
+	parent_trace_id, parent_span_id, ok := pp.parentOfPlan(plan)
+	if ok {
+		trace_id = parent_trace_id
+	}
+	if correlation_id != "" {
+		// The root span replaces the one emitted when the query started.
+		if started, ok := pp.started.take(correlation_id); ok {
+			trace_id, span_id, parent_span_id = started.traceID, started.spanID, started.parentSpanID
+		}
+	}
 
-	start_timestamp := plan["start timestamp"].(float64)
+	start_time, err := pp.planStartTime(plan)
+	if err != nil {
+		return nil, err
+	}
 	duration := plan["duration"].(float64)
-	start_time := timestampToTime(start_timestamp)
-	end_time := timestampToTime(start_timestamp + duration)
+	end_time := start_time.Add(time.Duration(duration * float64(pp.durationUnit)))
 
 	attributes := make(map[string]*tracepb.AttributeValue)
 	attributes["query"] = stringToAttributeValue(plan["Query Text"].(string))
@@ -122,21 +1244,177 @@ func parseExecutionPlan(message interface{}) []*tracepb.Span {
 
 	backend_pid := int64(plan["connection_id"].(float64))
 	attributes["connection_id"] = int64ToAttributeValue(backend_pid)
-	attributes["database_name"] = stringToAttributeValue(plan["database_name"].(string))
+	// The receiver falls back to the current database of its connection.
+	if database_name, ok := plan["database_name"].(string); ok {
+		attributes["database_name"] = stringToAttributeValue(database_name)
+	}
+	// The shape of the whole plan, including the nodes that are not emitted
+	// as spans, gives its complexity at a glance.
+	node_count, max_depth := planShape(plan["Plan"])
+	attributes["plan_node_count"] = int64ToAttributeValue(int64(node_count))
+	attributes["plan_max_depth"] = int64ToAttributeValue(int64(max_depth))
+	if correlation_id != "" {
+		attributes[pp.correlationField] = stringToAttributeValue(correlation_id)
+	}
 
 	root_span := &tracepb.Span{
 		TraceId:      trace_id,
 		SpanId:       span_id,
-		ParentSpanId: nil,
+		ParentSpanId: parent_span_id,
 		Name:         &tracepb.TruncatableString{Value: "CloudSQLQuery"},
 		StartTime:    internal.TimeToTimestamp(start_time),
 		EndTime:      internal.TimeToTimestamp(end_time),
 		Attributes:   &tracepb.Span_Attributes{AttributeMap: attributes},
+		TimeEvents:   warningsToTimeEvents(plan["warnings"], start_time),
 	}
+	addLockWaits(root_span, plan["lock_waits"], start_time)
+	addWaitEvents(root_span, plan["wait_events"])
 
-	_, spans := parseChildPlan(plan["Plan"], start_time, trace_id, span_id)
+	_, spans := pp.parseChildPlan(plan["Plan"], start_time, trace_id, span_id, 1)
+	spans = append(spans, triggersToSpans(plan["Triggers"], end_time, trace_id, span_id)...)
 	spans = append(spans, root_span)
-	return spans
+	return spans, nil
+}
+
+// setSampled sets the sampled attribute on the spans of plan, a payload of the
+// pull command, from its "sampled" field or else from sampledByDefault.
+func (pp *planParser) setSampled(spans []*tracepb.Span, plan map[string]interface{}) {
+	if pp.sampledByDefault == nil {
+		return
+	}
+	sampled, ok := plan["sampled"].(bool)
+	if !ok {
+		sampled = *pp.sampledByDefault
+	}
+	for _, span := range spans {
+		span.Attributes.AttributeMap["sampled"] = boolToAttributeValue(sampled)
+	}
+}
+
+// durationUnit returns the unit named by the duration_unit setting.
+func durationUnit(name string) (time.Duration, error) {
+	switch name {
+	case "", "seconds":
+		return time.Second, nil
+	case "milliseconds":
+		return time.Millisecond, nil
+	default:
+		return 0, fmt.Errorf("unknown duration_unit %q, must be %q or %q", name, "seconds", "milliseconds")
+	}
+}
+
+// planShape returns the number of nodes of a plan and the depth of its
+// deepest node, the root node being at depth 1. It walks the plan with an
+// explicit stack, so that it is not bounded by the maximum plan depth.
+func planShape(plan interface{}) (nodeCount, maxDepth int) {
+	type node struct {
+		plan  interface{}
+		depth int
+	}
+	stack := []node{{plan, 1}}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		plan_map, ok := n.plan.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		nodeCount++
+		if n.depth > maxDepth {
+			maxDepth = n.depth
+		}
+		children, _ := plan_map["Plans"].([]interface{})
+		for _, child := range children {
+			stack = append(stack, node{child, n.depth + 1})
+		}
+	}
+	return nodeCount, maxDepth
+}
+
+// startTimestampNanos decodes the optional "start_timestamp_nanos" field of a
+// plan, the start time in nanoseconds since the epoch, as an integer.
+func startTimestampNanos(planJSON []byte) (int64, error) {
+	var fields struct {
+		StartTimestampNanos json.Number `json:"start_timestamp_nanos"`
+	}
+	if err := json.Unmarshal(planJSON, &fields); err != nil {
+		return 0, fmt.Errorf("invalid start_timestamp_nanos: %v", err)
+	}
+	nanos, err := fields.StartTimestampNanos.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("invalid start_timestamp_nanos: %v", err)
+	}
+	return nanos, nil
+}
+
+// planStartTime returns the start time of a plan, from its
+// "start_timestamp_nanos" field if any or else its "start timestamp".
+func (pp *planParser) planStartTime(plan map[string]interface{}) (time.Time, error) {
+	if nanos, ok := plan["start_timestamp_nanos"].(int64); ok {
+		return time.Unix(0, nanos), nil
+	}
+	return pp.startTime(plan["start timestamp"])
+}
+
+// startTime converts the start timestamp of a plan, either a number of
+// seconds since the epoch or a string in the configured layout.
+func (pp *planParser) startTime(timestamp interface{}) (time.Time, error) {
+	switch ts := timestamp.(type) {
+	case float64:
+		return timestampToTime(ts), nil
+	case string:
+		layout := pp.timestampLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		t, err := time.Parse(layout, ts)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid start timestamp: %v", err)
+		}
+		return t, nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid start timestamp %v", timestamp)
+	}
+}
+
+// parentOfPlan returns the trace ID and span ID of the optional
+// "parent_trace_id" and "parent_span_id" fields of a plan, hex encoded, which
+// link the query to the span of the application that ran it. It returns false
+// unless both are present and valid.
+func (pp *planParser) parentOfPlan(plan map[string]interface{}) (traceID, spanID []byte, ok bool) {
+	spanHex, hasSpan := plan["parent_span_id"].(string)
+	traceHex, hasTrace := plan["parent_trace_id"].(string)
+	if !hasSpan || !hasTrace {
+		return nil, nil, false
+	}
+	spanID, spanErr := hex.DecodeString(spanHex)
+	traceID, traceErr := hex.DecodeString(traceHex)
+	if spanErr != nil || traceErr != nil || len(spanID) != 8 || len(traceID) != 16 || isZero(spanID) || isZero(traceID) {
+		pp.logger.Printf("Ignoring invalid parent trace ID %q and span ID %q", traceHex, spanHex)
+		return nil, nil, false
+	}
+	return traceID, spanID, true
+}
+
+func isZero(id []byte) bool {
+	for _, b := range id {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// clampDuration returns the duration of a span brought within the configured
+// bounds, and whether it had to be changed.
+func (pp *planParser) clampDuration(duration time.Duration) (time.Duration, bool) {
+	if pp.minSpanDuration > 0 && duration < pp.minSpanDuration {
+		return pp.minSpanDuration, true
+	}
+	if pp.maxSpanDuration > 0 && duration > pp.maxSpanDuration {
+		return pp.maxSpanDuration, true
+	}
+	return duration, false
 }
 
 func generateTraceId() []byte {
@@ -176,7 +1454,36 @@ func int64ToAttributeValue(val int64) *tracepb.AttributeValue {
 	}
 }
 
-func parseChildPlan(plan interface{}, trace_start_time time.Time, trace_id []byte, parent_span_id []byte) (time.Time, []*tracepb.Span) {
+func doubleToAttributeValue(val float64) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_DoubleValue{
+			DoubleValue: val,
+		},
+	}
+}
+
+func boolToAttributeValue(val bool) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_BoolValue{
+			BoolValue: val,
+		},
+	}
+}
+
+// isNeverExecuted reports whether the plan node carries the "Never Executed"
+// flag, e.g. for pruned partitions or short-circuited branches.
+func isNeverExecuted(plan interface{}) bool {
+	plan_map, ok := plan.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	never_executed, _ := plan_map["Never Executed"].(bool)
+	return never_executed
+}
+
+// parseChildPlan converts the plan node at depth, the root plan node being at
+// depth 1, and its descendants into spans.
+func (pp *planParser) parseChildPlan(plan interface{}, trace_start_time time.Time, trace_id []byte, parent_span_id []byte, depth int) (time.Time, []*tracepb.Span) {
 	plan_map := plan.(map[string]interface{})
 
 	var spans []*tracepb.Span
@@ -189,32 +1496,71 @@ func parseChildPlan(plan interface{}, trace_start_time time.Time, trace_id []byt
 
 	node_type := plan_map["Node Type"].(string)
 	span.Name = &tracepb.TruncatableString{Value: node_type}
+	span.Kind = pp.nodeKinds[node_type]
 
 	// Note that actual start time is the time when all the children has returned and this plan is ready to work.
 	// It is different with the google's way of a span start time.
 	start_offset_ms := plan_map["Actual Startup Time"].(float64)
 	span_start_time := trace_start_time.Add(time.Duration(start_offset_ms * float64(time.Millisecond)))
-	if plans := plan_map["Plans"]; plans != nil {
+	var never_executed_spans []*tracepb.Span
+	plans := plan_map["Plans"]
+	// Stop at the maximum depth so that a pathological plan cannot exhaust
+	// the stack.
+	truncated := plans != nil && depth >= pp.maxPlanDepth
+	if plans != nil && !truncated {
 		for _, child_plan := range plans.([]interface{}) {
-			child_span_start_time, child_spans := parseChildPlan(child_plan, trace_start_time, trace_id, span_id)
-			if span_start_time.After(child_span_start_time) {
+			child_span_start_time, child_spans := pp.parseChildPlan(child_plan, trace_start_time, trace_id, span_id, depth+1)
+			// Nodes that never executed did not delay their parent, so they
+			// must not move its start time.
+			if isNeverExecuted(child_plan) {
+				never_executed_spans = append(never_executed_spans, child_spans...)
+			} else if span_start_time.After(child_span_start_time) {
 				span_start_time = child_span_start_time
 			}
 			spans = append(spans, child_spans...)
 		}
 	}
 	span.StartTime = internal.TimeToTimestamp(span_start_time)
+	// The timing of the nodes that never executed, and of their descendants,
+	// is meaningless, anchor their zero length spans at the start of this one.
+	for _, never_executed_span := range never_executed_spans {
+		never_executed_span.StartTime = internal.TimeToTimestamp(span_start_time)
+		never_executed_span.EndTime = internal.TimeToTimestamp(span_start_time)
+	}
 
+	never_executed := isNeverExecuted(plan_map)
 	end_offset_ms := plan_map["Actual Total Time"].(float64)
 	span_end_time := trace_start_time.Add(time.Duration(end_offset_ms * float64(time.Millisecond)))
-	if span_end_time.Equal(span_start_time) {
+	if never_executed {
+		// Keep a zero duration so that pruned nodes are not mistaken for very fast ones.
+		span_end_time = span_start_time
+	} else if span_end_time.Equal(span_start_time) {
 		span_end_time = span_end_time.Add(time.Nanosecond)
 	}
+	// The node is filtered on its actual duration, before clamping.
+	node_duration := span_end_time.Sub(span_start_time)
+	clamped := false
+	if !never_executed {
+		var clamped_duration time.Duration
+		if clamped_duration, clamped = pp.clampDuration(node_duration); clamped {
+			span_end_time = span_start_time.Add(clamped_duration)
+		}
+	}
 	span.EndTime = internal.TimeToTimestamp(span_end_time)
 
 	attributes := make(map[string]*tracepb.AttributeValue)
 	rows := plan_map["Actual Rows"].(float64)
 	attributes["Rows Fetched"] = int64ToAttributeValue(int64(rows))
+	if never_executed {
+		attributes["never_executed"] = boolToAttributeValue(true)
+	}
+	if removed, ok := plan_map["Rows Removed by Filter"].(float64); ok {
+		attributes["rows_removed_by_filter"] = int64ToAttributeValue(int64(removed))
+		// The fraction of the rows read by the node that passed its filter.
+		if scanned := rows + removed; scanned > 0 {
+			attributes["filter_selectivity"] = doubleToAttributeValue(rows / scanned)
+		}
+	}
 
 	if operation := plan_map["Operation"]; operation != nil {
 		attributes["Operation"] = stringToAttributeValue(operation.(string))
@@ -223,7 +1569,55 @@ func parseChildPlan(plan interface{}, trace_start_time time.Time, trace_id []byt
 	if table := plan_map["Relation Name"]; table != nil {
 		attributes["Table Name"] = stringToAttributeValue(table.(string))
 	}
+	// The index used by index scans, and by the index only scans which skip
+	// the table.
+	if index_name, ok := plan_map["Index Name"].(string); ok {
+		attributes["index_name"] = stringToAttributeValue(index_name)
+	}
+	// Tells apart the subplans of correlated subqueries, e.g. "SubPlan 1",
+	// which share their node type.
+	if subplan_name, ok := plan_map["Subplan Name"].(string); ok {
+		attributes["subplan_name"] = stringToAttributeValue(subplan_name)
+	}
+	// The time spent reading and writing blocks, reported with
+	// track_io_timing on, tells the nodes waiting on the disk.
+	if io_read_time, ok := plan_map["I/O Read Time"].(float64); ok {
+		attributes["io_read_time_ms"] = doubleToAttributeValue(io_read_time)
+	}
+	if io_write_time, ok := plan_map["I/O Write Time"].(float64); ok {
+		attributes["io_write_time_ms"] = doubleToAttributeValue(io_write_time)
+	}
+	for key, value := range modifyTableAttributes(plan_map) {
+		attributes[key] = value
+	}
+	for key, value := range cacheStatisticsAttributes(plan_map) {
+		attributes[key] = value
+	}
+	if truncated {
+		attributes["plan_truncated"] = boolToAttributeValue(true)
+	}
+	if clamped {
+		attributes["duration_clamped"] = boolToAttributeValue(true)
+	}
+	if pp.includeRelativeTimings {
+		attributes["startup_offset_ms"] = doubleToAttributeValue(start_offset_ms)
+		attributes["total_time_ms"] = doubleToAttributeValue(end_offset_ms)
+	}
 	span.Attributes = &tracepb.Span_Attributes{AttributeMap: attributes}
+	span.TimeEvents = warningsToTimeEvents(plan_map["warnings"], span_start_time)
+
+	// A truncated node is always emitted, to report the truncation.
+	omitted := node_duration < pp.minNodeDuration || (pp.allowedNodeTypes != nil && !pp.allowedNodeTypes[node_type])
+	if !truncated && omitted {
+		// Omit the node and attach its children to its nearest ancestor. Its
+		// start time is still returned, so the ancestors keep their timing.
+		for _, child_span := range spans {
+			if bytes.Equal(child_span.ParentSpanId, span_id) {
+				child_span.ParentSpanId = parent_span_id
+			}
+		}
+		return span_start_time, spans
+	}
 
 	spans = append(spans, &span)
 	return span_start_time, spans
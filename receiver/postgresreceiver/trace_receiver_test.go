@@ -0,0 +1,1157 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// partitionPrunedPlan is the plan of a query on a partitioned table where
+// the second partition was pruned at execution time.
+const partitionPrunedPlan = `{
+	"Node Type": "Append",
+	"Actual Startup Time": 0.010,
+	"Actual Total Time": 2.500,
+	"Actual Rows": 10,
+	"Plans": [
+		{
+			"Node Type": "Seq Scan",
+			"Relation Name": "measurements_2019_01",
+			"Actual Startup Time": 0.020,
+			"Actual Total Time": 2.400,
+			"Actual Rows": 10
+		},
+		{
+			"Node Type": "Seq Scan",
+			"Relation Name": "measurements_2019_02",
+			"Actual Startup Time": 0.000,
+			"Actual Total Time": 0.000,
+			"Actual Rows": 0,
+			"Never Executed": true
+		}
+	]
+}`
+
+func unmarshalPlan(t *testing.T, planStr string) interface{} {
+	var plan interface{}
+	if err := json.Unmarshal([]byte(planStr), &plan); err != nil {
+		t.Fatalf("Failed to unmarshal plan: %v", err)
+	}
+	return plan
+}
+
+func spanByTableName(spans []*tracepb.Span, tableName string) *tracepb.Span {
+	for _, span := range spans {
+		if attr := span.Attributes.AttributeMap["Table Name"]; attr != nil && attr.GetStringValue().GetValue() == tableName {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestParseChildPlanNeverExecuted(t *testing.T) {
+	traceStart := time.Unix(1546300800, 0)
+	parentStart, spans := defaultPlanParser.parseChildPlan(unmarshalPlan(t, partitionPrunedPlan), traceStart, generateTraceId(), generateSpanId(), 1)
+	if len(spans) != 3 {
+		t.Fatalf("Got %d spans, want 3", len(spans))
+	}
+
+	// The pruned partition must not pull the start of the Append node back to
+	// the start of the trace.
+	if want := traceStart.Add(10 * time.Microsecond); !parentStart.Equal(want) {
+		t.Errorf("Got parent start %v, want %v", parentStart, want)
+	}
+
+	pruned := spanByTableName(spans, "measurements_2019_02")
+	if pruned == nil {
+		t.Fatal("Missing span for the pruned partition")
+	}
+	if got := pruned.Attributes.AttributeMap["never_executed"].GetBoolValue(); !got {
+		t.Errorf("Got never_executed %v, want true", got)
+	}
+	if start, end := pruned.StartTime, pruned.EndTime; start.Seconds != end.Seconds || start.Nanos != end.Nanos {
+		t.Errorf("Got start %v and end %v, want zero duration", start, end)
+	}
+	// The pruned partition sits at the start of its parent, not of the trace.
+	if got := internal.TimestampToTime(pruned.StartTime); !got.Equal(parentStart) {
+		t.Errorf("Got pruned partition start %v, want the parent start %v", got, parentStart)
+	}
+
+	scanned := spanByTableName(spans, "measurements_2019_01")
+	if scanned == nil {
+		t.Fatal("Missing span for the scanned partition")
+	}
+	if _, ok := scanned.Attributes.AttributeMap["never_executed"]; ok {
+		t.Error("Got never_executed attribute on an executed node")
+	}
+	if want := internal.TimeToTimestamp(traceStart.Add(2400 * time.Microsecond)); scanned.EndTime.Nanos != want.Nanos {
+		t.Errorf("Got end %v, want %v", scanned.EndTime, want)
+	}
+}
+
+// planWithWarnings is a complete payload, as returned by the pull command,
+// carrying warnings for the query and for one of its plan nodes.
+const planWithWarnings = `{
+	"start timestamp": 1546300800.5,
+	"duration": 12.5,
+	"Query Text": "select * from t order by v",
+	"username": "postgres",
+	"session_username": "postgres",
+	"connection_id": 42,
+	"database_name": "postgres",
+	"warnings": [
+		"sort spilled to disk",
+		{"message": "external merge", "Sort Space Used": 4096}
+	],
+	"Plan": {
+		"Node Type": "Sort",
+		"Actual Startup Time": 10.0,
+		"Actual Total Time": 12.0,
+		"Actual Rows": 100,
+		"warnings": ["work_mem exceeded"]
+	}
+}`
+
+func TestParseExecutionPlanWarnings(t *testing.T) {
+	spans, err := ParseExecutionPlan([]byte(planWithWarnings))
+	if err != nil {
+		t.Fatalf("Failed to parse plan: %v", err)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("Got %d spans, want 2", len(spans))
+	}
+	sortSpan, rootSpan := spans[0], spans[1]
+
+	rootEvents := rootSpan.GetTimeEvents().GetTimeEvent()
+	if len(rootEvents) != 2 {
+		t.Fatalf("Got %d time events on the root span, want 2", len(rootEvents))
+	}
+	if got := rootEvents[0].GetAnnotation().GetDescription().GetValue(); got != "sort spilled to disk" {
+		t.Errorf("Got annotation %q, want %q", got, "sort spilled to disk")
+	}
+	second := rootEvents[1].GetAnnotation()
+	if got := second.GetDescription().GetValue(); got != "external merge" {
+		t.Errorf("Got annotation %q, want %q", got, "external merge")
+	}
+	if got := second.GetAttributes().GetAttributeMap()["Sort Space Used"].GetIntValue(); got != 4096 {
+		t.Errorf("Got Sort Space Used %d, want 4096", got)
+	}
+	if got, want := rootEvents[0].Time, rootSpan.StartTime; got.Seconds != want.Seconds || got.Nanos != want.Nanos {
+		t.Errorf("Got annotation time %v, want the root start time %v", got, want)
+	}
+
+	sortEvents := sortSpan.GetTimeEvents().GetTimeEvent()
+	if len(sortEvents) != 1 || sortEvents[0].GetAnnotation().GetDescription().GetValue() != "work_mem exceeded" {
+		t.Errorf("Got time events %v on the plan node span, want a single work_mem exceeded annotation", sortEvents)
+	}
+}
+
+func TestParseExecutionPlanWithoutWarnings(t *testing.T) {
+	spans, err := ParseExecutionPlan([]byte(`{
+		"start timestamp": 1546300800.5, "duration": 1, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres",
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to parse plan: %v", err)
+	}
+	for _, span := range spans {
+		if span.TimeEvents != nil {
+			t.Errorf("Got time events %v, want none", span.TimeEvents)
+		}
+	}
+}
+
+func TestParseChildPlanRowsRemovedByFilter(t *testing.T) {
+	plan := unmarshalPlan(t, `{
+		"Node Type": "Seq Scan",
+		"Relation Name": "orders",
+		"Actual Startup Time": 0.1,
+		"Actual Total Time": 5.0,
+		"Actual Rows": 25,
+		"Plans": [
+			{
+				"Node Type": "Seq Scan",
+				"Relation Name": "customers",
+				"Actual Startup Time": 0.1,
+				"Actual Total Time": 1.0,
+				"Actual Rows": 0,
+				"Rows Removed by Filter": 0
+			}
+		],
+		"Rows Removed by Filter": 75
+	}`)
+	_, spans := defaultPlanParser.parseChildPlan(plan, time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+
+	orders := spanByTableName(spans, "orders").Attributes.AttributeMap
+	if got := orders["rows_removed_by_filter"].GetIntValue(); got != 75 {
+		t.Errorf("Got rows_removed_by_filter %d, want 75", got)
+	}
+	if got := orders["filter_selectivity"].GetDoubleValue(); got != 0.25 {
+		t.Errorf("Got filter_selectivity %v, want 0.25", got)
+	}
+
+	// Without any row read there is no selectivity to compute.
+	customers := spanByTableName(spans, "customers").Attributes.AttributeMap
+	if _, ok := customers["rows_removed_by_filter"]; !ok {
+		t.Error("Missing rows_removed_by_filter attribute")
+	}
+	if _, ok := customers["filter_selectivity"]; ok {
+		t.Error("Got filter_selectivity attribute without any row read")
+	}
+
+	_, spans = defaultPlanParser.parseChildPlan(unmarshalPlan(t, partitionPrunedPlan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+	for _, span := range spans {
+		if _, ok := span.Attributes.AttributeMap["rows_removed_by_filter"]; ok {
+			t.Errorf("Got rows_removed_by_filter attribute on a node without filter")
+		}
+	}
+}
+
+func spanByName(spans []*tracepb.Span, name string) *tracepb.Span {
+	for _, span := range spans {
+		if span.Name.GetValue() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestParseChildPlanMinNodeDuration(t *testing.T) {
+	plan := unmarshalPlan(t, `{
+		"Node Type": "Hash Join",
+		"Actual Startup Time": 5.0,
+		"Actual Total Time": 20.0,
+		"Actual Rows": 10,
+		"Plans": [
+			{
+				"Node Type": "Hash",
+				"Actual Startup Time": 9.9,
+				"Actual Total Time": 10.0,
+				"Actual Rows": 10,
+				"Plans": [
+					{
+						"Node Type": "Seq Scan",
+						"Actual Startup Time": 9.95,
+						"Actual Total Time": 12.0,
+						"Actual Rows": 10
+					}
+				]
+			}
+		]
+	}`)
+	// Timings of looped nodes are averages per loop, so a child can outlast
+	// its parent. Here the Hash node is faster than the threshold while its
+	// child is not.
+	pp := newPlanParser(&Config{MinNodeDuration: time.Millisecond})
+	traceStart := time.Unix(1546300800, 0)
+	parentSpanID := generateSpanId()
+	start, spans := pp.parseChildPlan(plan, traceStart, generateTraceId(), parentSpanID, 1)
+
+	if len(spans) != 2 {
+		t.Fatalf("Got %d spans, want 2", len(spans))
+	}
+	if spanByName(spans, "Hash") != nil {
+		t.Error("Got a span for the fast Hash node")
+	}
+	join, scan := spanByName(spans, "Hash Join"), spanByName(spans, "Seq Scan")
+	if join == nil || scan == nil {
+		t.Fatalf("Missing spans for the slow nodes in %v", spans)
+	}
+	if !bytes.Equal(scan.ParentSpanId, join.SpanId) {
+		t.Errorf("Got Seq Scan parent %x, want the Hash Join span %x", scan.ParentSpanId, join.SpanId)
+	}
+	if !bytes.Equal(join.ParentSpanId, parentSpanID) {
+		t.Errorf("Got Hash Join parent %x, want %x", join.ParentSpanId, parentSpanID)
+	}
+	// The Hash Join keeps its own timing.
+	if want := traceStart.Add(5 * time.Millisecond); !start.Equal(want) {
+		t.Errorf("Got start %v, want %v", start, want)
+	}
+}
+
+func TestReopenConnectionsReusesUnchanged(t *testing.T) {
+	orders := &connection{config: ConnectionConfig{ConnStr: "dbname=orders", ServiceName: "orders"}}
+	users := &connection{config: ConnectionConfig{ConnStr: "dbname=users", ServiceName: "users"}}
+	pgr := &PostgresReceiver{
+		config: Config{Connections: []ConnectionConfig{orders.config, users.config}},
+		conns:  []*connection{orders, users},
+	}
+
+	config := pgr.config
+	config.Connections = []ConnectionConfig{users.config}
+	conns, unused, err := reopenConnections(&pgr.config, pgr.conns, &config, pgr.notifications)
+	if err != nil {
+		t.Fatalf("reopenConnections() error = %v", err)
+	}
+	if len(conns) != 1 || conns[0] != users {
+		t.Errorf("Got connections %v, want the existing users connection", conns)
+	}
+	if len(unused) != 1 || unused[0] != orders {
+		t.Errorf("Got unused connections %v, want the orders connection", unused)
+	}
+}
+
+func TestConnectionNode(t *testing.T) {
+	conn := &connection{config: ConnectionConfig{ConnStr: "dbname=orders", ServiceName: "orders"}}
+	if got := conn.node().GetServiceInfo().GetName(); got != "orders" {
+		t.Errorf("Got service name %q, want %q", got, "orders")
+	}
+	conn = &connection{config: ConnectionConfig{ConnStr: "dbname=orders"}}
+	if got := conn.node().GetServiceInfo(); got != nil {
+		t.Errorf("Got service info %v, want none", got)
+	}
+}
+
+func TestParseExecutionPlanTriggers(t *testing.T) {
+	spans, err := ParseExecutionPlan([]byte(`{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "insert into orders values (1)",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres",
+		"Plan": {"Node Type": "ModifyTable", "Operation": "Insert", "Relation Name": "orders", "Actual Startup Time": 0.1, "Actual Total Time": 2.0, "Actual Rows": 0},
+		"Triggers": [
+			{"Trigger Name": "audit_orders", "Relation": "orders", "Time": 3.0, "Calls": 1},
+			{"Trigger Name": "RI_ConstraintTrigger_c_16400", "Relation": "orders", "Time": 5.0, "Calls": 1}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to parse plan: %v", err)
+	}
+	if len(spans) != 4 {
+		t.Fatalf("Got %d spans, want 4", len(spans))
+	}
+	root := spans[len(spans)-1]
+
+	audit, ri := spanByName(spans, "audit_orders"), spanByName(spans, "RI_ConstraintTrigger_c_16400")
+	if audit == nil || ri == nil {
+		t.Fatalf("Missing trigger spans in %v", spans)
+	}
+	for _, trigger := range []*tracepb.Span{audit, ri} {
+		if !bytes.Equal(trigger.ParentSpanId, root.SpanId) {
+			t.Errorf("Got %q parent %x, want the root span %x", trigger.Name.GetValue(), trigger.ParentSpanId, root.SpanId)
+		}
+		if got := trigger.Attributes.AttributeMap["calls"].GetIntValue(); got != 1 {
+			t.Errorf("Got %q calls %d, want 1", trigger.Name.GetValue(), got)
+		}
+	}
+
+	// The triggers run back to back at the end of the query.
+	traceStart := time.Unix(1546300800, 0)
+	if got, want := internal.TimestampToTime(audit.StartTime), traceStart.Add(492*time.Millisecond); !got.Equal(want) {
+		t.Errorf("Got audit_orders start %v, want %v", got, want)
+	}
+	if got, want := internal.TimestampToTime(audit.EndTime), internal.TimestampToTime(ri.StartTime); !got.Equal(want) {
+		t.Errorf("Got audit_orders end %v, want the next trigger start %v", got, want)
+	}
+	if got, want := internal.TimestampToTime(ri.EndTime), internal.TimestampToTime(root.EndTime); !got.Equal(want) {
+		t.Errorf("Got RI trigger end %v, want the query end %v", got, want)
+	}
+}
+
+func TestParseExecutionPlanStringTimestamp(t *testing.T) {
+	const planFormat = `{
+		"start timestamp": %s, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres",
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	want := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		layout    string
+		timestamp string
+		wantErr   bool
+	}{
+		{name: "epoch", timestamp: "1546300800"},
+		{name: "rfc3339 by default", timestamp: `"2019-01-01T00:00:00Z"`},
+		{name: "custom layout", layout: "2006-01-02 15:04:05", timestamp: `"2019-01-01 00:00:00"`},
+		{name: "invalid", timestamp: `"yesterday"`, wantErr: true},
+		{name: "wrong type", timestamp: "true", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pp := newPlanParser(&Config{TimestampLayout: tt.layout})
+			spans, err := pp.Parse([]byte(fmt.Sprintf(planFormat, tt.timestamp)))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Got spans %v, want an error", spans)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse plan: %v", err)
+			}
+			root := spans[len(spans)-1]
+			if got := internal.TimestampToTime(root.StartTime); !got.Equal(want) {
+				t.Errorf("Got start %v, want %v", got, want)
+			}
+			if got := internal.TimestampToTime(root.EndTime); !got.Equal(want.Add(500 * time.Millisecond)) {
+				t.Errorf("Got end %v, want %v", got, want.Add(500*time.Millisecond))
+			}
+		})
+	}
+}
+
+func TestParseExecutionPlanParentLink(t *testing.T) {
+	const planFormat = `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres",
+		%s
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	wantTraceID := []byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	wantParentSpanID := []byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+
+	tests := []struct {
+		name       string
+		fields     string
+		wantLinked bool
+	}{
+		{
+			name:       "linked",
+			fields:     `"parent_trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "parent_span_id": "00f067aa0ba902b7",`,
+			wantLinked: true,
+		},
+		{
+			name:   "missing span",
+			fields: `"parent_trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",`,
+		},
+		{
+			name:   "short trace",
+			fields: `"parent_trace_id": "4bf92f3577b34da6", "parent_span_id": "00f067aa0ba902b7",`,
+		},
+		{
+			name:   "not hex",
+			fields: `"parent_trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "parent_span_id": "not a span id!!",`,
+		},
+		{
+			name:   "zero span",
+			fields: `"parent_trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "parent_span_id": "0000000000000000",`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans, err := ParseExecutionPlan([]byte(fmt.Sprintf(planFormat, tt.fields)))
+			if err != nil {
+				t.Fatalf("Failed to parse plan: %v", err)
+			}
+			root := spans[len(spans)-1]
+			if !tt.wantLinked {
+				if root.ParentSpanId != nil || bytes.Equal(root.TraceId, wantTraceID) {
+					t.Errorf("Got root span linked to trace %x span %x, want a new trace", root.TraceId, root.ParentSpanId)
+				}
+				return
+			}
+			if !bytes.Equal(root.ParentSpanId, wantParentSpanID) {
+				t.Errorf("Got root parent %x, want %x", root.ParentSpanId, wantParentSpanID)
+			}
+			for _, span := range spans {
+				if !bytes.Equal(span.TraceId, wantTraceID) {
+					t.Errorf("Got %q trace %x, want %x", span.Name.GetValue(), span.TraceId, wantTraceID)
+				}
+			}
+		})
+	}
+}
+
+func TestLinkedPlanGetsCollectorInfo(t *testing.T) {
+	spans, err := ParseExecutionPlan([]byte(`{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres",
+		"parent_trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "parent_span_id": "00f067aa0ba902b7",
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to parse plan: %v", err)
+	}
+
+	rp := &recordingProcessor{}
+	cip := processor.NewCollectorInfoProcessor(rp)
+	if err := cip.ProcessTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("ProcessTraceData() error = %v", err)
+	}
+	root, node := spanByName(spans, "CloudSQLQuery"), spanByName(spans, "Result")
+	if _, ok := root.Attributes.AttributeMap["collector.version"]; !ok {
+		t.Error("Missing collector.version on the root span of a linked plan")
+	}
+	if _, ok := node.Attributes.AttributeMap["collector.version"]; ok {
+		t.Error("Got collector.version on a plan node span")
+	}
+}
+
+func TestParseChildPlanMaxPlanDepth(t *testing.T) {
+	// A chain of 5 nested nodes.
+	plan := `{"Node Type": "Seq Scan", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}`
+	for i := 0; i < 4; i++ {
+		plan = fmt.Sprintf(`{"Node Type": "Level %d", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1, "Plans": [%s]}`, 4-i, plan)
+	}
+
+	pp := newPlanParser(&Config{MaxPlanDepth: 3})
+	_, spans := pp.parseChildPlan(unmarshalPlan(t, plan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+	if len(spans) != 3 {
+		t.Fatalf("Got %d spans, want 3", len(spans))
+	}
+	for _, span := range spans {
+		_, truncated := span.Attributes.AttributeMap["plan_truncated"]
+		if want := span.Name.GetValue() == "Level 3"; truncated != want {
+			t.Errorf("Got plan_truncated %v on %q, want %v", truncated, span.Name.GetValue(), want)
+		}
+	}
+
+	// The whole plan fits within the default depth.
+	_, spans = defaultPlanParser.parseChildPlan(unmarshalPlan(t, plan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+	if len(spans) != 5 {
+		t.Errorf("Got %d spans with the default depth, want 5", len(spans))
+	}
+}
+
+func TestParseValidatePlans(t *testing.T) {
+	pp := newPlanParser(&Config{ValidatePlans: true})
+	_, err := pp.Parse([]byte(`{"duration": 0.5, "Query Text": "select 1"}`))
+	want := "invalid plan, missing keys: start timestamp, Plan"
+	if _, ok := err.(*invalidPlanError); !ok || err.Error() != want {
+		t.Errorf("Got error %v, want %q", err, want)
+	}
+	if _, err := pp.Parse([]byte(`[1, 2]`)); err == nil {
+		t.Error("Got no error for a plan that is not an object")
+	}
+
+	// Without validation, the same plan is reported as malformed.
+	if _, err := defaultPlanParser.Parse([]byte(`{"duration": 0.5, "Query Text": "select 1"}`)); err == nil {
+		t.Error("Got no error for a plan without start timestamp")
+	} else if _, ok := err.(*invalidPlanError); ok {
+		t.Errorf("Got invalid plan error %v without validation", err)
+	}
+}
+
+func TestParseExecutionPlanSampled(t *testing.T) {
+	const planFormat = `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42, "database_name": "postgres",
+		%s
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+
+	for fields, wantSpans := range map[string]int{
+		``:                  2,
+		`"sampled": true,`:  2,
+		`"sampled": false,`: 0,
+	} {
+		spans, err := ParseExecutionPlan([]byte(fmt.Sprintf(planFormat, fields)))
+		if err != nil {
+			t.Fatalf("Failed to parse plan with %q: %v", fields, err)
+		}
+		if len(spans) != wantSpans {
+			t.Errorf("Got %d spans with %q, want %d", len(spans), fields, wantSpans)
+		}
+	}
+}
+
+func TestParseExecutionPlanShape(t *testing.T) {
+	// A chain of 3 nested nodes above the partition pruned plan.
+	plan := partitionPrunedPlan
+	for i := 0; i < 3; i++ {
+		plan = fmt.Sprintf(`{"Node Type": "Level %d", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1, "Plans": [%s]}`, 3-i, plan)
+	}
+	planJSON := fmt.Sprintf(`{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": %s
+	}`, plan)
+
+	// The nodes beyond the maximum depth are still counted.
+	for _, maxPlanDepth := range []int{0, 2} {
+		spans, err := newPlanParser(&Config{MaxPlanDepth: maxPlanDepth}).Parse([]byte(planJSON))
+		if err != nil {
+			t.Fatalf("Failed to parse plan: %v", err)
+		}
+		attributes := spanByName(spans, "CloudSQLQuery").Attributes.AttributeMap
+		if got := attributes["plan_node_count"].GetIntValue(); got != 6 {
+			t.Errorf("maxPlanDepth=%d: got plan_node_count %d, want 6", maxPlanDepth, got)
+		}
+		if got := attributes["plan_max_depth"].GetIntValue(); got != 5 {
+			t.Errorf("maxPlanDepth=%d: got plan_max_depth %d, want 5", maxPlanDepth, got)
+		}
+	}
+}
+
+func TestParseChildPlanNodeKindMap(t *testing.T) {
+	plan := `{
+		"Node Type": "Nested Loop", "Actual Startup Time": 0.1, "Actual Total Time": 0.5, "Actual Rows": 1,
+		"Plans": [
+			{"Node Type": "Seq Scan", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1},
+			{"Node Type": "Foreign Scan", "Actual Startup Time": 0.2, "Actual Total Time": 0.4, "Actual Rows": 1}
+		]
+	}`
+
+	tests := []struct {
+		name        string
+		nodeKindMap map[string]string
+		want        map[string]tracepb.Span_SpanKind
+	}{
+		{
+			name: "defaults",
+			want: map[string]tracepb.Span_SpanKind{
+				"Nested Loop":  tracepb.Span_SPAN_KIND_UNSPECIFIED,
+				"Seq Scan":     tracepb.Span_SPAN_KIND_UNSPECIFIED,
+				"Foreign Scan": tracepb.Span_CLIENT,
+			},
+		},
+		{
+			name:        "overrides",
+			nodeKindMap: map[string]string{"Seq Scan": "client", "Foreign Scan": "INTERNAL"},
+			want: map[string]tracepb.Span_SpanKind{
+				"Nested Loop":  tracepb.Span_SPAN_KIND_UNSPECIFIED,
+				"Seq Scan":     tracepb.Span_CLIENT,
+				"Foreign Scan": tracepb.Span_SPAN_KIND_UNSPECIFIED,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pp := newPlanParser(&Config{NodeKindMap: tt.nodeKindMap})
+			_, spans := pp.parseChildPlan(unmarshalPlan(t, plan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+			for nodeType, want := range tt.want {
+				if got := spanByName(spans, nodeType).Kind; got != want {
+					t.Errorf("Got kind %v for %q, want %v", got, nodeType, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateConfigNodeKindMap(t *testing.T) {
+	config := &Config{
+		PullCommand:  "select 1",
+		PullInterval: time.Second,
+		NodeKindMap:  map[string]string{"Seq Scan": "PRODUCER"},
+	}
+	if err := validateConfig(config); err == nil {
+		t.Error("Got no error for an unknown span kind")
+	}
+}
+
+func TestParseChildPlanSubplanName(t *testing.T) {
+	plan := `{
+		"Node Type": "Seq Scan", "Relation Name": "orders", "Actual Startup Time": 0.1, "Actual Total Time": 0.9, "Actual Rows": 1,
+		"Plans": [
+			{"Node Type": "Index Scan", "Subplan Name": "SubPlan 1", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1},
+			{"Node Type": "Index Scan", "Subplan Name": "SubPlan 2", "Actual Startup Time": 0.3, "Actual Total Time": 0.4, "Actual Rows": 1}
+		]
+	}`
+	_, spans := defaultPlanParser.parseChildPlan(unmarshalPlan(t, plan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+
+	var subplanNames []string
+	for _, span := range spans {
+		if attr, ok := span.Attributes.AttributeMap["subplan_name"]; ok {
+			subplanNames = append(subplanNames, attr.GetStringValue().GetValue())
+		}
+	}
+	if want := []string{"SubPlan 1", "SubPlan 2"}; !reflect.DeepEqual(subplanNames, want) {
+		t.Errorf("Got subplan names %q, want %q", subplanNames, want)
+	}
+}
+
+func TestParseExecutionPlanDurationUnit(t *testing.T) {
+	plan := []byte(`{
+		"start timestamp": 1546300800, "duration": 250, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`)
+
+	for unit, want := range map[string]time.Duration{
+		"":             250 * time.Second,
+		"seconds":      250 * time.Second,
+		"milliseconds": 250 * time.Millisecond,
+	} {
+		spans, err := newPlanParser(&Config{DurationUnit: unit}).Parse(plan)
+		if err != nil {
+			t.Fatalf("Failed to parse plan: %v", err)
+		}
+		root := spanByName(spans, "CloudSQLQuery")
+		if got := internal.TimestampToTime(root.EndTime).Sub(internal.TimestampToTime(root.StartTime)); got != want {
+			t.Errorf("Got duration %v with unit %q, want %v", got, unit, want)
+		}
+	}
+
+	config := &Config{PullCommand: "select 1", PullInterval: time.Second, DurationUnit: "minutes"}
+	if err := validateConfig(config); err == nil {
+		t.Error("Got no error for an unknown duration unit")
+	}
+}
+
+func TestParseExecutionPlanStartTimestampNanos(t *testing.T) {
+	const planFormat = `{
+		%s "duration": 0.5, "Query Text": "select 1",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	want := time.Unix(1546300800, 123456789)
+
+	// The nanoseconds win over the float timestamp, and replace it.
+	for _, fields := range []string{
+		`"start_timestamp_nanos": 1546300800123456789, "start timestamp": 1546300000,`,
+		`"start_timestamp_nanos": 1546300800123456789,`,
+	} {
+		spans, err := newPlanParser(&Config{ValidatePlans: true}).Parse([]byte(fmt.Sprintf(planFormat, fields)))
+		if err != nil {
+			t.Fatalf("Failed to parse plan with %q: %v", fields, err)
+		}
+		root := spanByName(spans, "CloudSQLQuery")
+		if got := internal.TimestampToTime(root.StartTime); !got.Equal(want) {
+			t.Errorf("Got start time %v with %q, want %v", got, fields, want)
+		}
+	}
+
+	if _, err := ParseExecutionPlan([]byte(fmt.Sprintf(planFormat, `"start_timestamp_nanos": 1.5,`))); err == nil {
+		t.Error("Got no error for a start_timestamp_nanos that is not an integer")
+	}
+}
+
+func TestParseChildPlanModifyTable(t *testing.T) {
+	tests := []struct {
+		name          string
+		plan          string
+		wantOperation string
+		wantRows      int64
+	}{
+		{
+			name: "update",
+			plan: `{"Node Type": "ModifyTable", "Operation": "Update", "Relation Name": "orders", "Actual Startup Time": 0.5, "Actual Total Time": 0.9, "Actual Rows": 0,
+				"Plans": [{"Node Type": "Seq Scan", "Relation Name": "orders", "Actual Startup Time": 0.1, "Actual Total Time": 0.4, "Actual Rows": 42}]}`,
+			wantOperation: "UPDATE",
+			wantRows:      42,
+		},
+		{
+			name: "insert on conflict",
+			plan: `{"Node Type": "ModifyTable", "Operation": "Insert", "Relation Name": "orders", "Actual Startup Time": 0.5, "Actual Total Time": 0.9, "Actual Rows": 0,
+				"Tuples Inserted": 3, "Conflicting Tuples": 2,
+				"Plans": [{"Node Type": "Values Scan", "Actual Startup Time": 0.1, "Actual Total Time": 0.4, "Actual Rows": 5}]}`,
+			wantOperation: "INSERT",
+			wantRows:      3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, spans := defaultPlanParser.parseChildPlan(unmarshalPlan(t, tt.plan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+			attributes := spanByName(spans, "ModifyTable").Attributes.AttributeMap
+			if got := attributes["modify_operation"].GetStringValue().GetValue(); got != tt.wantOperation {
+				t.Errorf("Got modify_operation %q, want %q", got, tt.wantOperation)
+			}
+			if got := attributes["affected_rows"].GetIntValue(); got != tt.wantRows {
+				t.Errorf("Got affected_rows %d, want %d", got, tt.wantRows)
+			}
+			for _, span := range spans {
+				if span.Name.Value == "ModifyTable" {
+					continue
+				}
+				if _, ok := span.Attributes.AttributeMap["affected_rows"]; ok {
+					t.Errorf("Got affected_rows on the %q span", span.Name.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestParseChildPlanClampsSpanDurations(t *testing.T) {
+	plan := `{
+		"Node Type": "Hash Join", "Actual Startup Time": 0.1, "Actual Total Time": 7200000, "Actual Rows": 1,
+		"Plans": [
+			{"Node Type": "Seq Scan", "Actual Startup Time": 0.1, "Actual Total Time": 0.1, "Actual Rows": 1},
+			{"Node Type": "Index Scan", "Actual Startup Time": 0.25, "Actual Total Time": 0.75, "Actual Rows": 1},
+			{"Node Type": "Hash", "Never Executed": true, "Actual Startup Time": 0, "Actual Total Time": 0, "Actual Rows": 0}
+		]
+	}`
+	pp := newPlanParser(&Config{MinSpanDuration: time.Microsecond, MaxSpanDuration: time.Hour})
+	_, spans := pp.parseChildPlan(unmarshalPlan(t, plan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+
+	tests := []struct {
+		name         string
+		wantDuration time.Duration
+		wantClamped  bool
+	}{
+		{"Hash Join", time.Hour, true},
+		{"Seq Scan", time.Microsecond, true},
+		{"Index Scan", 500 * time.Microsecond, false},
+		// Never executed.
+		{"Hash", 0, false},
+	}
+	for _, tt := range tests {
+		span := spanByName(spans, tt.name)
+		duration := internal.TimestampToTime(span.EndTime).Sub(internal.TimestampToTime(span.StartTime))
+		if duration != tt.wantDuration {
+			t.Errorf("Got duration %v for %q, want %v", duration, tt.name, tt.wantDuration)
+		}
+		if got := span.Attributes.AttributeMap["duration_clamped"].GetBoolValue(); got != tt.wantClamped {
+			t.Errorf("Got duration_clamped %v for %q, want %v", got, tt.name, tt.wantClamped)
+		}
+	}
+}
+
+func TestValidateConfigSpanDurationBounds(t *testing.T) {
+	config := &Config{
+		PullCommand:     "select 1",
+		PullInterval:    time.Second,
+		MinSpanDuration: time.Second,
+		MaxSpanDuration: time.Millisecond,
+	}
+	if err := validateConfig(config); err == nil {
+		t.Error("Got no error for a maximum span duration less than the minimum")
+	}
+}
+
+func TestParseChildPlanNodeTypeAllowlist(t *testing.T) {
+	plan := unmarshalPlan(t, `{
+		"Node Type": "Hash Join", "Actual Startup Time": 5.0, "Actual Total Time": 20.0, "Actual Rows": 10,
+		"Plans": [
+			{"Node Type": "Seq Scan", "Relation Name": "orders", "Actual Startup Time": 1.0, "Actual Total Time": 4.0, "Actual Rows": 10},
+			{"Node Type": "Hash", "Actual Startup Time": 9.0, "Actual Total Time": 10.0, "Actual Rows": 10,
+				"Plans": [
+					{"Node Type": "Seq Scan", "Relation Name": "users", "Actual Startup Time": 2.0, "Actual Total Time": 8.0, "Actual Rows": 10}
+				]
+			}
+		]
+	}`)
+	pp := newPlanParser(&Config{NodeTypeAllowlist: []string{"Seq Scan"}})
+	traceStart := time.Unix(1546300800, 0)
+	parentSpanID := generateSpanId()
+	start, spans := pp.parseChildPlan(plan, traceStart, generateTraceId(), parentSpanID, 1)
+
+	if len(spans) != 2 {
+		t.Fatalf("Got %d spans, want the 2 Seq Scan spans", len(spans))
+	}
+	for _, span := range spans {
+		if span.Name.Value != "Seq Scan" {
+			t.Errorf("Got a span for the %q node", span.Name.Value)
+		}
+		// Both are attached to the nearest emitted ancestor.
+		if !bytes.Equal(span.ParentSpanId, parentSpanID) {
+			t.Errorf("Got parent %x, want %x", span.ParentSpanId, parentSpanID)
+		}
+	}
+	// The omitted nodes keep their timing for their ancestors.
+	if want := traceStart.Add(time.Millisecond); !start.Equal(want) {
+		t.Errorf("Got start %v, want %v", start, want)
+	}
+}
+
+func TestParseExecutionPlanRawPlan(t *testing.T) {
+	planJSON := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select 'é'",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	// Cut in the middle of the two bytes of the é.
+	cut := strings.Index(planJSON, "é") + 1
+
+	tests := []struct {
+		name          string
+		config        Config
+		want          string
+		wantTruncated int32
+	}{
+		{"excluded", Config{}, "", 0},
+		{"included", Config{IncludeRawPlan: true}, planJSON, 0},
+		{"truncated", Config{IncludeRawPlan: true, RawPlanMaxBytes: cut}, planJSON[:cut-1], int32(len(planJSON) - cut + 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans, err := newPlanParser(&tt.config).Parse([]byte(planJSON))
+			if err != nil {
+				t.Fatalf("Failed to parse plan: %v", err)
+			}
+			rawPlan := spanByName(spans, "CloudSQLQuery").Attributes.AttributeMap["raw_plan"].GetStringValue()
+			if got := rawPlan.GetValue(); got != tt.want {
+				t.Errorf("Got raw_plan %q, want %q", got, tt.want)
+			}
+			if got := rawPlan.GetTruncatedByteCount(); got != tt.wantTruncated {
+				t.Errorf("Got %d truncated bytes, want %d", got, tt.wantTruncated)
+			}
+			if spanByName(spans, "Result").Attributes.AttributeMap["raw_plan"] != nil {
+				t.Error("Got raw_plan on a plan node span")
+			}
+		})
+	}
+}
+
+func TestParseChildPlanIndexName(t *testing.T) {
+	plan := `{
+		"Node Type": "Nested Loop", "Actual Startup Time": 0.1, "Actual Total Time": 0.9, "Actual Rows": 1,
+		"Plans": [
+			{"Node Type": "Seq Scan", "Relation Name": "users", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1},
+			{"Node Type": "Index Only Scan", "Relation Name": "orders", "Index Name": "orders_user_id_idx", "Actual Startup Time": 0.3, "Actual Total Time": 0.4, "Actual Rows": 1}
+		]
+	}`
+	_, spans := defaultPlanParser.parseChildPlan(unmarshalPlan(t, plan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+
+	attributes := spanByName(spans, "Index Only Scan").Attributes.AttributeMap
+	if got := attributes["index_name"].GetStringValue().GetValue(); got != "orders_user_id_idx" {
+		t.Errorf("Got index_name %q, want %q", got, "orders_user_id_idx")
+	}
+	if got := attributes["Table Name"].GetStringValue().GetValue(); got != "orders" {
+		t.Errorf("Got Table Name %q, want %q", got, "orders")
+	}
+	if _, ok := spanByName(spans, "Seq Scan").Attributes.AttributeMap["index_name"]; ok {
+		t.Error("Got index_name on a sequential scan")
+	}
+}
+
+func TestParseChildPlanRelativeTimings(t *testing.T) {
+	// The Hash Join starts with its first child, before its own startup time.
+	plan := `{
+		"Node Type": "Hash Join", "Actual Startup Time": 0.5, "Actual Total Time": 0.9, "Actual Rows": 1,
+		"Plans": [
+			{"Node Type": "Seq Scan", "Relation Name": "users", "Actual Startup Time": 0.1, "Actual Total Time": 0.3, "Actual Rows": 1}
+		]
+	}`
+	pp := newPlanParser(&Config{IncludeRelativeTimings: true})
+	_, spans := pp.parseChildPlan(unmarshalPlan(t, plan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+
+	for name, want := range map[string][2]float64{"Hash Join": {0.5, 0.9}, "Seq Scan": {0.1, 0.3}} {
+		attributes := spanByName(spans, name).Attributes.AttributeMap
+		startup := attributes["startup_offset_ms"].GetDoubleValue()
+		total := attributes["total_time_ms"].GetDoubleValue()
+		if startup != want[0] || total != want[1] {
+			t.Errorf("%s: got startup_offset_ms %v and total_time_ms %v, want %v and %v", name, startup, total, want[0], want[1])
+		}
+	}
+
+	_, spans = defaultPlanParser.parseChildPlan(unmarshalPlan(t, plan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+	if _, ok := spanByName(spans, "Hash Join").Attributes.AttributeMap["startup_offset_ms"]; ok {
+		t.Error("Got startup_offset_ms without include_relative_timings")
+	}
+}
+
+func TestParseChildPlanCacheStatistics(t *testing.T) {
+	plan := `{
+		"Node Type": "Nested Loop", "Actual Startup Time": 0.1, "Actual Total Time": 9.0, "Actual Rows": 1000,
+		"Plans": [
+			{"Node Type": "Hash Join", "Actual Startup Time": 0.1, "Actual Total Time": 4.0, "Actual Rows": 1000,
+				"Plans": [
+					{"Node Type": "Seq Scan", "Relation Name": "orders", "Actual Startup Time": 0.1, "Actual Total Time": 1.0, "Actual Rows": 1000},
+					{"Node Type": "Hash", "Actual Startup Time": 1.0, "Actual Total Time": 1.0, "Actual Rows": 100,
+						"Hash Buckets": 1024, "Original Hash Buckets": 1024, "Hash Batches": 4, "Original Hash Batches": 1, "Peak Memory Usage": 4096,
+						"Plans": [{"Node Type": "Seq Scan", "Relation Name": "users", "Actual Startup Time": 0.1, "Actual Total Time": 0.9, "Actual Rows": 100}]}
+				]},
+			{"Node Type": "Memoize", "Actual Startup Time": 0.0, "Actual Total Time": 0.0, "Actual Rows": 1,
+				"Cache Key": "orders.product_id", "Cache Hits": 980, "Cache Misses": 20, "Cache Evictions": 0, "Cache Overflows": 0, "Peak Memory Usage": 12,
+				"Plans": [{"Node Type": "Index Scan", "Index Name": "products_pkey", "Actual Startup Time": 0.0, "Actual Total Time": 0.0, "Actual Rows": 1}]}
+		]
+	}`
+	_, spans := defaultPlanParser.parseChildPlan(unmarshalPlan(t, plan), time.Unix(1546300800, 0), generateTraceId(), generateSpanId(), 1)
+
+	for name, want := range map[string]map[string]int64{
+		"Hash":    {"hash_buckets": 1024, "original_hash_buckets": 1024, "hash_batches": 4, "original_hash_batches": 1, "peak_memory_kb": 4096},
+		"Memoize": {"cache_hits": 980, "cache_misses": 20, "cache_evictions": 0, "cache_overflows": 0, "peak_memory_kb": 12},
+	} {
+		attributes := spanByName(spans, name).Attributes.AttributeMap
+		for key, value := range want {
+			attribute, ok := attributes[key]
+			if !ok {
+				t.Errorf("%s: got no %s", name, key)
+				continue
+			}
+			if got := attribute.GetIntValue(); got != value {
+				t.Errorf("%s: got %s %d, want %d", name, key, got, value)
+			}
+		}
+	}
+	if _, ok := spanByName(spans, "Hash Join").Attributes.AttributeMap["hash_batches"]; ok {
+		t.Error("Got hash_batches on the Hash Join")
+	}
+}
+
+func TestParseIOTimings(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "select * from orders",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"Plan": {"Node Type": "Seq Scan", "Relation Name": "orders", "Actual Startup Time": 0.1, "Actual Total Time": 0.4, "Actual Rows": 1,
+			%s}
+	}`
+	tests := []struct {
+		name          string
+		timings       string
+		serverVersion int
+	}{
+		{name: "PostgreSQL 16", timings: `"I/O Read Time": 0.25, "I/O Write Time": 0.05`, serverVersion: 160002},
+		{name: "PostgreSQL 17", timings: `"Shared I/O Read Time": 0.25, "Shared I/O Write Time": 0.05`, serverVersion: 170000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans, err := defaultPlanParser.parseVersion([]byte(fmt.Sprintf(plan, tt.timings)), tt.serverVersion)
+			if err != nil {
+				t.Fatalf("parseVersion() error = %v", err)
+			}
+			attributes := spanByName(spans, "Seq Scan").Attributes.AttributeMap
+			if got := attributes["io_read_time_ms"].GetDoubleValue(); got != 0.25 {
+				t.Errorf("Got io_read_time_ms %v, want 0.25", got)
+			}
+			if got := attributes["io_write_time_ms"].GetDoubleValue(); got != 0.05 {
+				t.Errorf("Got io_write_time_ms %v, want 0.05", got)
+			}
+		})
+	}
+
+	spans, err := defaultPlanParser.Parse([]byte(fmt.Sprintf(plan, `"Actual Loops": 1`)))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, ok := spanByName(spans, "Seq Scan").Attributes.AttributeMap["io_read_time_ms"]; ok {
+		t.Error("Got io_read_time_ms without I/O timing")
+	}
+}
+
+func TestParseLockWaits(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "update orders set paid = true",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"warnings": ["sort spilled to disk"],
+		"lock_waits": [
+			{"relation": "orders", "mode": "RowExclusiveLock", "duration": 12.5, "offset": 100},
+			{"relation": "users", "mode": "AccessShareLock", "duration": 0.5},
+			"not a lock wait"
+		],
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	spans, err := defaultPlanParser.Parse([]byte(plan))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	root := spanByName(spans, "CloudSQLQuery")
+	if got := root.Attributes.AttributeMap["total_lock_wait_ms"].GetDoubleValue(); got != 13 {
+		t.Errorf("Got total_lock_wait_ms %v, want 13", got)
+	}
+	// The warning comes first.
+	events := root.TimeEvents.TimeEvent
+	if len(events) != 3 {
+		t.Fatalf("Got %d annotations, want 3", len(events))
+	}
+	annotation := events[1].GetAnnotation()
+	if got, want := annotation.Description.GetValue(), "lock wait on orders (RowExclusiveLock)"; got != want {
+		t.Errorf("Got annotation %q, want %q", got, want)
+	}
+	if got := annotation.Attributes.AttributeMap["duration_ms"].GetDoubleValue(); got != 12.5 {
+		t.Errorf("Got duration_ms %v, want 12.5", got)
+	}
+	start := time.Unix(1546300800, 0)
+	if got := internal.TimestampToTime(events[1].Time); !got.Equal(start.Add(100 * time.Millisecond)) {
+		t.Errorf("Got the lock wait at %v, want 100ms after the start of the query", got)
+	}
+	if got := internal.TimestampToTime(events[2].Time); !got.Equal(start) {
+		t.Errorf("Got the lock wait without offset at %v, want the start of the query", got)
+	}
+}
+
+func TestParseLockWaitsWithoutDurations(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "update orders set paid = true",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"lock_waits": [{"relation": "orders", "mode": "RowExclusiveLock"}],
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	spans, err := defaultPlanParser.Parse([]byte(plan))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	root := spanByName(spans, "CloudSQLQuery")
+	if _, ok := root.Attributes.AttributeMap["total_lock_wait_ms"]; ok {
+		t.Error("Got total_lock_wait_ms without durations")
+	}
+	if got := len(root.TimeEvents.GetTimeEvent()); got != 1 {
+		t.Errorf("Got %d annotations, want 1", got)
+	}
+}
+
+func TestParseWaitEvents(t *testing.T) {
+	plan := `{
+		"start timestamp": 1546300800, "duration": 0.5, "Query Text": "update orders set paid = true",
+		"username": "postgres", "session_username": "postgres", "connection_id": 42,
+		"wait_events": {"IO": 0.25, "Lock": 0.7, "LWLock": 0.7, "CPU": 0.05, "Extension": "unknown"},
+		"Plan": {"Node Type": "Result", "Actual Startup Time": 0.1, "Actual Total Time": 0.2, "Actual Rows": 1}
+	}`
+	spans, err := defaultPlanParser.Parse([]byte(plan))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	attributes := spanByName(spans, "CloudSQLQuery").Attributes.AttributeMap
+	for key, want := range map[string]float64{"wait.IO": 0.25, "wait.Lock": 0.7, "wait.LWLock": 0.7, "wait.CPU": 0.05} {
+		if got := attributes[key].GetDoubleValue(); got != want {
+			t.Errorf("Got %s %v, want %v", key, got, want)
+		}
+	}
+	if _, ok := attributes["wait.Extension"]; ok {
+		t.Error("Got the wait event that is not a number")
+	}
+	// LWLock and Lock are tied, the first in alphabetical order wins.
+	if got := attributes["dominant_wait_event"].GetStringValue().GetValue(); got != "LWLock" {
+		t.Errorf("Got dominant_wait_event %q, want %q", got, "LWLock")
+	}
+
+	spans, err = defaultPlanParser.Parse([]byte(resultPlan))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, ok := spanByName(spans, "CloudSQLQuery").Attributes.AttributeMap["dominant_wait_event"]; ok {
+		t.Error("Got dominant_wait_event without wait events")
+	}
+}
+
+func TestParseSampledByDefault(t *testing.T) {
+	withSampled := strings.Replace(resultPlan, `"connection_id": 42,`, `"connection_id": 42, "sampled": true,`, 1)
+	notSampled, sampled := false, true
+	tests := []struct {
+		name             string
+		sampledByDefault *bool
+		plan             string
+		want             *bool
+	}{
+		{"unset", nil, resultPlan, nil},
+		{"default", &notSampled, resultPlan, &notSampled},
+		{"payload", &notSampled, withSampled, &sampled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans, err := newPlanParser(&Config{SampledByDefault: tt.sampledByDefault}).Parse([]byte(tt.plan))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			for _, span := range spans {
+				got, ok := span.Attributes.AttributeMap["sampled"]
+				if tt.want == nil {
+					if ok {
+						t.Errorf("Got sampled on span %q, want none", span.Name.GetValue())
+					}
+					continue
+				}
+				if !ok || got.GetBoolValue() != *tt.want {
+					t.Errorf("Got sampled %v on span %q, want %v", got, span.Name.GetValue(), *tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// triggersToSpans converts the optional "Triggers" array of the plan of a
+// data modifying query into one span per trigger, child of the root span:
+//
+//   "Triggers": [
+//     {"Trigger Name": "audit_orders", "Relation": "orders", "Time": 1.5, "Calls": 3}
+//   ]
+//
+// The plan only reports the total time spent in every trigger, so their spans
+// are laid out back to back, in order, ending with the query at end.
+func triggersToSpans(triggers interface{}, end time.Time, traceID, parentSpanID []byte) []*tracepb.Span {
+	list, ok := triggers.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil
+	}
+
+	var total time.Duration
+	for _, trigger := range list {
+		total += triggerTime(trigger)
+	}
+
+	spans := make([]*tracepb.Span, 0, len(list))
+	start := end.Add(-total)
+	for _, trigger := range list {
+		triggerMap, _ := trigger.(map[string]interface{})
+		name, _ := triggerMap["Trigger Name"].(string)
+		triggerEnd := start.Add(triggerTime(trigger))
+
+		attributes := make(map[string]*tracepb.AttributeValue)
+		if calls, ok := triggerMap["Calls"].(float64); ok {
+			attributes["calls"] = int64ToAttributeValue(int64(calls))
+		}
+		if table, ok := triggerMap["Relation"].(string); ok {
+			attributes["Table Name"] = stringToAttributeValue(table)
+		}
+
+		spans = append(spans, &tracepb.Span{
+			TraceId:      traceID,
+			SpanId:       generateSpanId(),
+			ParentSpanId: parentSpanID,
+			Name:         &tracepb.TruncatableString{Value: name},
+			StartTime:    internal.TimeToTimestamp(start),
+			EndTime:      internal.TimeToTimestamp(triggerEnd),
+			Attributes:   &tracepb.Span_Attributes{AttributeMap: attributes},
+		})
+		start = triggerEnd
+	}
+	return spans
+}
+
+// triggerTime returns the time spent in a trigger, reported in milliseconds.
+func triggerTime(trigger interface{}) time.Duration {
+	triggerMap, _ := trigger.(map[string]interface{})
+	ms, _ := triggerMap["Time"].(float64)
+	return time.Duration(ms * float64(time.Millisecond))
+}
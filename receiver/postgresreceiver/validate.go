@@ -0,0 +1,57 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// requiredPlanKeys are the keys of a plan without which it cannot be
+// converted into spans.
+var requiredPlanKeys = []string{"start timestamp", "duration", "Query Text", "Plan"}
+
+// invalidPlanError reports a plan that is not a JSON object, or that lacks
+// some of the required keys.
+type invalidPlanError struct {
+	missingKeys []string
+}
+
+func (e *invalidPlanError) Error() string {
+	return fmt.Sprintf("invalid plan, missing keys: %s", strings.Join(e.missingKeys, ", "))
+}
+
+// validatePlan checks that the decoded plan message has all the required keys,
+// reporting all the missing ones at once.
+func validatePlan(message interface{}) error {
+	plan, ok := message.(map[string]interface{})
+	if !ok {
+		return &invalidPlanError{missingKeys: requiredPlanKeys}
+	}
+	var missingKeys []string
+	for _, key := range requiredPlanKeys {
+		if _, ok := plan[key]; !ok {
+			// The integer start timestamp replaces the float one.
+			if _, ok := plan["start_timestamp_nanos"]; ok && key == "start timestamp" {
+				continue
+			}
+			missingKeys = append(missingKeys, key)
+		}
+	}
+	if len(missingKeys) > 0 {
+		return &invalidPlanError{missingKeys: missingKeys}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+// waitEventAttributePrefix prefixes the attributes of the wait events of a
+// query, e.g. wait.IO.
+const waitEventAttributePrefix = "wait."
+
+// addWaitEvents sets the wait events of the optional "wait_events" object of a
+// payload, which some pipelines sample from pg_stat_activity while the query
+// runs, as attributes of the root span of the query. The object maps the wait
+// event types, or events, to their number of samples or their fraction of the
+// samples, e.g.:
+//
+//   "wait_events": {"IO": 12, "Lock": 3, "CPU": 1}
+//
+// Every entry becomes a wait.<type> attribute, and the root span gets a
+// dominant_wait_event attribute with the type of the largest one, the first
+// in alphabetical order among equal ones. The entries that are not numbers
+// are skipped.
+func addWaitEvents(root *tracepb.Span, waitEvents interface{}) {
+	events, ok := waitEvents.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	dominant := ""
+	var dominantValue float64
+	for waitEvent, value := range events {
+		number, ok := value.(float64)
+		if !ok {
+			continue
+		}
+		root.Attributes.AttributeMap[waitEventAttributePrefix+waitEvent] = doubleToAttributeValue(number)
+		if dominant == "" || number > dominantValue || (number == dominantValue && waitEvent < dominant) {
+			dominant, dominantValue = waitEvent, number
+		}
+	}
+	if dominant != "" {
+		root.Attributes.AttributeMap["dominant_wait_event"] = stringToAttributeValue(dominant)
+	}
+}
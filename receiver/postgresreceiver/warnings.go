@@ -0,0 +1,87 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgresreceiver
+
+import (
+	"fmt"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// warningsToTimeEvents converts the optional "warnings" array of a plan, or of
+// a plan node, into annotations at the given time. Every warning is either a
+// string, or an object with a "message" and optional extra fields which are
+// kept as attributes of the annotation, e.g.:
+//
+//   "warnings": [
+//     "sort spilled to disk",
+//     {"message": "external merge", "Sort Space Used": 4096}
+//   ]
+//
+// It returns nil when there are no warnings.
+func warningsToTimeEvents(warnings interface{}, at time.Time) *tracepb.Span_TimeEvents {
+	list, ok := warnings.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil
+	}
+
+	timeEvents := &tracepb.Span_TimeEvents{}
+	for _, warning := range list {
+		annotation := &tracepb.Span_TimeEvent_Annotation{}
+		switch w := warning.(type) {
+		case string:
+			annotation.Description = &tracepb.TruncatableString{Value: w}
+		case map[string]interface{}:
+			message, _ := w["message"].(string)
+			annotation.Description = &tracepb.TruncatableString{Value: message}
+			attributes := make(map[string]*tracepb.AttributeValue)
+			for key, value := range w {
+				if key == "message" {
+					continue
+				}
+				attributes[key] = jsonToAttributeValue(value)
+			}
+			if len(attributes) > 0 {
+				annotation.Attributes = &tracepb.Span_Attributes{AttributeMap: attributes}
+			}
+		default:
+			annotation.Description = &tracepb.TruncatableString{Value: fmt.Sprint(w)}
+		}
+		timeEvents.TimeEvent = append(timeEvents.TimeEvent, &tracepb.Span_TimeEvent{
+			Time:  internal.TimeToTimestamp(at),
+			Value: &tracepb.Span_TimeEvent_Annotation_{Annotation: annotation},
+		})
+	}
+	return timeEvents
+}
+
+// jsonToAttributeValue converts a decoded JSON value to an attribute value.
+func jsonToAttributeValue(value interface{}) *tracepb.AttributeValue {
+	switch v := value.(type) {
+	case string:
+		return stringToAttributeValue(v)
+	case bool:
+		return boolToAttributeValue(v)
+	case float64:
+		if v == float64(int64(v)) {
+			return int64ToAttributeValue(int64(v))
+		}
+		return doubleToAttributeValue(v)
+	default:
+		return stringToAttributeValue(fmt.Sprint(v))
+	}
+}